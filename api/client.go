@@ -11,9 +11,11 @@ import (
 	"github.com/Qitmeer/llama.go/format"
 	"github.com/Qitmeer/llama.go/version"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"runtime"
+	"strconv"
 	"strings"
 )
 
@@ -46,6 +48,15 @@ func checkError(resp *http.Response, body []byte) error {
 	return apiError
 }
 
+// token is the Authorization header of the requests, the first API key of the
+// config if any.
+func (c *Client) token() string {
+	if len(config.Conf.APIKeys) <= 0 {
+		return ""
+	}
+	return "Bearer " + config.Conf.APIKeys[0]
+}
+
 func NewClient(base *url.URL, http *http.Client) *Client {
 	return &Client{
 		base: base,
@@ -55,8 +66,6 @@ func NewClient(base *url.URL, http *http.Client) *Client {
 
 func (c *Client) do(ctx context.Context, method, path string, reqData, respData any) error {
 	var reqBody io.Reader
-	var data []byte
-	var err error
 
 	switch reqData := reqData.(type) {
 	case io.Reader:
@@ -65,24 +74,29 @@ func (c *Client) do(ctx context.Context, method, path string, reqData, respData
 	case nil:
 		// noop
 	default:
-		data, err = json.Marshal(reqData)
+		data, err := json.Marshal(reqData)
 		if err != nil {
 			return err
 		}
 
 		reqBody = bytes.NewReader(data)
 	}
+	return c.doBody(ctx, method, path, "application/json", reqBody, respData)
+}
 
+// doBody sends reqBody of contentType, decoding the JSON response into
+// respData, or copying the response as is into a *[]byte.
+func (c *Client) doBody(ctx context.Context, method, path, contentType string, reqBody io.Reader, respData any) error {
 	requestURL := c.base.JoinPath(path)
 
-	var token string
+	token := c.token()
 
 	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), reqBody)
 	if err != nil {
 		return err
 	}
 
-	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Content-Type", contentType)
 	request.Header.Set("Accept", "application/json")
 	request.Header.Set("User-Agent", fmt.Sprintf("llamago/%s (%s %s) Go/%s", version.String(), runtime.GOARCH, runtime.GOOS, runtime.Version()))
 
@@ -105,6 +119,10 @@ func (c *Client) do(ctx context.Context, method, path string, reqData, respData
 		return err
 	}
 
+	if raw, ok := respData.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
 	if len(respBody) > 0 && respData != nil {
 		if err := json.Unmarshal(respBody, respData); err != nil {
 			return err
@@ -128,7 +146,7 @@ func (c *Client) stream(ctx context.Context, method, path string, data any, fn f
 
 	requestURL := c.base.JoinPath(path)
 
-	var token string
+	token := c.token()
 
 	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), buf)
 	if err != nil {
@@ -265,6 +283,44 @@ func (c *Client) Pull(ctx context.Context, req *PullRequest, fn PullProgressFunc
 	})
 }
 
+// CreateProgressFunc is a function that [Client.Create] invokes every time
+// there is progress with a "create" request sent to the service. If this
+// function returns an error, [Client.Create] will stop the process and return
+// this error.
+type CreateProgressFunc func(ProgressResponse) error
+
+// Create creates a named model from a base model and the settings of a
+// Modelfile. fn is called each time progress is made on the request.
+func (c *Client) Create(ctx context.Context, req *CreateRequest, fn CreateProgressFunc) error {
+	return c.stream(ctx, http.MethodPost, "/api/create", req, func(bts []byte) error {
+		var resp ProgressResponse
+		if err := json.Unmarshal(bts, &resp); err != nil {
+			return err
+		}
+
+		return fn(resp)
+	})
+}
+
+// Delete deletes a model created with [Client.Create] and the blobs no
+// other model uses.
+func (c *Client) Delete(ctx context.Context, req *DeleteRequest) error {
+	if err := c.do(ctx, http.MethodDelete, "/api/delete", req, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Copy copies a model created with [Client.Create] to the name of
+// Destination. Any other model, e.g. a GGUF file of the model directory, gets
+// Destination as an alias.
+func (c *Client) Copy(ctx context.Context, req *CopyRequest) error {
+	if err := c.do(ctx, http.MethodPost, "/api/copy", req, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
 // List lists models that are available locally.
 func (c *Client) List(ctx context.Context) (*ListResponse, error) {
 	var lr ListResponse
@@ -319,6 +375,74 @@ func (c *Client) Embeddings(ctx context.Context, req *EmbeddingRequest) (*Embedd
 	return &resp, nil
 }
 
+// Rerank scores documents by their relevance to a query with a reranking
+// model, the most relevant first.
+func (c *Client) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	var resp RerankResponse
+	if err := c.do(ctx, http.MethodPost, "/api/rerank", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Transcribe transcribes an audio file with a whisper model.
+func (c *Client) Transcribe(ctx context.Context, req *TranscriptionRequest) (*TranscriptionResponse, error) {
+	if req.Audio == nil {
+		return nil, errors.New("no audio")
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fields := [][2]string{
+		{"model", req.Model},
+		{"language", req.Language},
+		{"prompt", req.Prompt},
+		{"response_format", req.ResponseFormat},
+	}
+	if req.Temperature != nil {
+		fields = append(fields, [2]string{"temperature", strconv.FormatFloat(float64(*req.Temperature), 'f', -1, 32)})
+	}
+	for _, g := range req.TimestampGranularities {
+		fields = append(fields, [2]string{"timestamp_granularities[]", g})
+	}
+	for _, f := range fields {
+		if len(f[1]) <= 0 {
+			continue
+		}
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return nil, err
+		}
+	}
+	filename := req.Filename
+	if len(filename) <= 0 {
+		filename = "audio"
+	}
+	fw, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(fw, req.Audio); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var resp TranscriptionResponse
+	switch req.ResponseFormat {
+	case "text", "srt", "vtt":
+		var text []byte
+		if err := c.doBody(ctx, http.MethodPost, "/v1/audio/transcriptions", w.FormDataContentType(), &body, &text); err != nil {
+			return nil, err
+		}
+		resp.Text = string(text)
+	default:
+		if err := c.doBody(ctx, http.MethodPost, "/v1/audio/transcriptions", w.FormDataContentType(), &body, &resp); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, nil
+}
+
 // Version returns the llama.go server version as a string.
 func (c *Client) Version(ctx context.Context) (string, error) {
 	var version struct {
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -222,3 +223,48 @@ func TestClientDo(t *testing.T) {
 		})
 	}
 }
+
+func TestClientTranscribe(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+		audio, _ := io.ReadAll(f)
+		if fh.Filename != "speech.wav" || string(audio) != "RIFF" {
+			t.Errorf("got file %q of %q", fh.Filename, audio)
+		}
+		if got := r.FormValue("temperature"); got != "0.2" {
+			t.Errorf("got temperature %q", got)
+		}
+		if r.FormValue("response_format") == "srt" {
+			w.Write([]byte("1\n00:00:00,000 --> 00:00:01,000\nhello\n\n"))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": "hello"})
+	}))
+	defer ts.Close()
+
+	client := NewClient(&url.URL{Scheme: "http", Host: ts.Listener.Addr().String()}, http.DefaultClient)
+	temperature := float32(0.2)
+	for _, format := range []string{"", "srt"} {
+		resp, err := client.Transcribe(t.Context(), &TranscriptionRequest{
+			Model:          "whisper-1",
+			Filename:       "speech.wav",
+			Audio:          strings.NewReader("RIFF"),
+			ResponseFormat: format,
+			Temperature:    &temperature,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(resp.Text, "hello") {
+			t.Errorf("%q: got text %q", format, resp.Text)
+		}
+	}
+}
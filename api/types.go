@@ -1,14 +1,17 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Qitmeer/llama.go/config"
 	"github.com/Qitmeer/llama.go/model"
-	"github.com/ethereum/go-ethereum/log"
+	"io"
 	"math"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -107,6 +110,10 @@ type GenerateRequest struct {
 	// set through this field, if the model supports it.
 	Options map[string]any `json:"options"`
 
+	// Adapters selects the LoRA adapters loaded by the server which apply to
+	// this request. Adapters left out are disabled for the request.
+	Adapters []Adapter `json:"adapters,omitempty"`
+
 	// Think controls whether thinking/reasoning models will think before
 	// responding. Can be a boolean (true/false) or a string ("high", "medium", "low")
 	// for supported models. Needs to be a pointer so we can distinguish between false
@@ -143,6 +150,16 @@ type ChatRequest struct {
 	// Options lists model-specific options.
 	Options map[string]any `json:"options"`
 
+	// Adapters selects the LoRA adapters, as in [GenerateRequest].
+	Adapters []Adapter `json:"adapters,omitempty"`
+
+	// ToolChoice controls whether the model must call a tool, and which one.
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+
+	// ParallelToolCalls allows the model to call several tools in a single
+	// response.
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+
 	// Think controls whether thinking/reasoning models will think before
 	// responding. Can be a boolean (true/false) or a string ("high", "medium", "low")
 	// for supported models.
@@ -153,6 +170,19 @@ type ChatRequest struct {
 	DebugRenderOnly bool `json:"_debug_render_only,omitempty"`
 }
 
+// Adapter selects a LoRA adapter loaded by the server, by its ID or by its
+// path, and the scale it applies with.
+type Adapter struct {
+	// ID is the index of the adapter as listed by /lora-adapters.
+	ID *int `json:"id,omitempty"`
+
+	// Path is the file the adapter was loaded from, or its file name.
+	Path string `json:"path,omitempty"`
+
+	// Scale defaults to 1, 0 disables the adapter.
+	Scale *float32 `json:"scale,omitempty"`
+}
+
 type Tools []Tool
 
 func (t Tools) String() string {
@@ -315,6 +345,9 @@ type ToolFunctionParameters struct {
 	Items      any                     `json:"items,omitempty"`
 	Required   []string                `json:"required"`
 	Properties map[string]ToolProperty `json:"properties"`
+	// AdditionalProperties is false to reject the arguments not in
+	// Properties, or the schema of those arguments. They are allowed when nil.
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
 }
 
 func (t *ToolFunctionParameters) String() string {
@@ -322,6 +355,126 @@ func (t *ToolFunctionParameters) String() string {
 	return string(bts)
 }
 
+// Validate checks the arguments of a tool call against the parameters of the
+// function: required arguments must be present, each value must match the
+// type and enum of its property and the arguments without a property must be
+// allowed by additionalProperties.
+func (t *ToolFunctionParameters) Validate(args map[string]any) error {
+	for _, name := range t.Required {
+		if _, ok := args[name]; !ok {
+			return fmt.Errorf("missing required argument %q", name)
+		}
+	}
+
+	for name, v := range args {
+		prop, ok := t.Properties[name]
+		if !ok {
+			switch additional := t.AdditionalProperties.(type) {
+			case nil:
+				continue
+			case bool:
+				if !additional {
+					return fmt.Errorf("unknown argument %q", name)
+				}
+				continue
+			default:
+				bts, err := json.Marshal(additional)
+				if err != nil {
+					return err
+				}
+				if err := json.Unmarshal(bts, &prop); err != nil {
+					continue // additionalProperties is not a schema we can check
+				}
+			}
+		}
+		if err := prop.Validate(v); err != nil {
+			return fmt.Errorf("argument %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that v matches the type, enum and items of the property.
+func (tp ToolProperty) Validate(v any) error {
+	if len(tp.AnyOf) > 0 {
+		var errs []string
+		for _, p := range tp.AnyOf {
+			err := p.Validate(v)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("no anyOf alternative matches: %s", strings.Join(errs, "; "))
+	}
+
+	if len(tp.Enum) > 0 && !slices.ContainsFunc(tp.Enum, func(e any) bool {
+		a, _ := json.Marshal(e)
+		b, _ := json.Marshal(v)
+		return bytes.Equal(a, b)
+	}) {
+		return fmt.Errorf("value %v is not one of %v", v, tp.Enum)
+	}
+
+	if len(tp.Type) > 0 && !slices.ContainsFunc(tp.Type, func(typ string) bool { return isJSONType(v, typ) }) {
+		return fmt.Errorf("expected %s, got %T", tp.Type, v)
+	}
+
+	if items, ok := v.([]any); ok && tp.Items != nil {
+		bts, err := json.Marshal(tp.Items)
+		if err != nil {
+			return err
+		}
+		var item ToolProperty
+		if err := json.Unmarshal(bts, &item); err != nil {
+			return nil // items is not a schema we can check
+		}
+		for i, e := range items {
+			if err := item.Validate(e); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isJSONType reports whether v, as decoded by encoding/json, is of the JSON
+// schema type typ. Unknown types are accepted.
+func isJSONType(v any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		switch v.(type) {
+		case float64, json.Number:
+			return true
+		}
+		return false
+	case "integer":
+		switch n := v.(type) {
+		case float64:
+			return n == math.Trunc(n)
+		case json.Number:
+			_, err := n.Int64()
+			return err == nil
+		}
+		return false
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
 type ToolFunction struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
@@ -333,6 +486,57 @@ func (t *ToolFunction) String() string {
 	return string(bts)
 }
 
+// ToolChoice is either one of the modes "auto", "none" and "required", or a
+// specific function the model must call.
+type ToolChoice struct {
+	Mode     string
+	Function string
+}
+
+// Required reports whether the model must call a tool.
+func (t *ToolChoice) Required() bool {
+	return t != nil && (t.Mode == "required" || t.Function != "")
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (t *ToolChoice) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		switch mode {
+		case "auto", "none", "required":
+			*t = ToolChoice{Mode: mode}
+			return nil
+		}
+		return fmt.Errorf("invalid tool_choice %q, must be \"auto\", \"none\", \"required\" or a function", mode)
+	}
+
+	var fn struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &fn); err != nil {
+		return err
+	}
+	if fn.Type != "function" || fn.Function.Name == "" {
+		return errors.New("invalid tool_choice, expected {\"type\": \"function\", \"function\": {\"name\": ...}}")
+	}
+	*t = ToolChoice{Function: fn.Function.Name}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (t ToolChoice) MarshalJSON() ([]byte, error) {
+	if t.Function != "" {
+		return json.Marshal(map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": t.Function},
+		})
+	}
+	return json.Marshal(t.Mode)
+}
+
 // ChatResponse is the response returned by [Client.Chat]. Its fields are
 // similar to [GenerateResponse].
 type ChatResponse struct {
@@ -375,6 +579,11 @@ type Metrics struct {
 	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
 	EvalCount          int           `json:"eval_count,omitempty"`
 	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
+
+	// DraftCount and DraftAcceptedCount are the tokens a draft model
+	// proposed during speculative decoding, and those the model accepted.
+	DraftCount         int `json:"draft_count,omitempty"`
+	DraftAcceptedCount int `json:"draft_accepted_count,omitempty"`
 }
 
 // Options specified in [GenerateRequest].  If you add a new option here, also
@@ -461,6 +670,128 @@ type EmbeddingResponse struct {
 	Embedding []float64 `json:"embedding"`
 }
 
+// RerankRequest is the request passed to [Client.Rerank].
+type RerankRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Query is the query the documents are scored against.
+	Query string `json:"query"`
+
+	// Documents are the texts to rank.
+	Documents []string `json:"documents"`
+
+	// TopN limits the results to the most relevant documents, all of them
+	// when zero.
+	TopN int `json:"top_n,omitempty"`
+
+	// ReturnDocuments includes the text of the documents in the results.
+	ReturnDocuments bool `json:"return_documents,omitempty"`
+}
+
+// RerankResponse is the response from [Client.Rerank].
+type RerankResponse struct {
+	Model   string         `json:"model"`
+	Results []RerankResult `json:"results"`
+	Usage   RerankUsage    `json:"usage"`
+}
+
+// RerankResult is the score of a document, sorted by relevance in
+// [RerankResponse].
+type RerankResult struct {
+	// Index is the index of the document in the request.
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *RerankDocument `json:"document,omitempty"`
+}
+
+type RerankDocument struct {
+	Text string `json:"text"`
+}
+
+type RerankUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// TranscriptionRequest is the request passed to [Client.Transcribe], sent
+// as a multipart form.
+type TranscriptionRequest struct {
+	// Model is the whisper model, the one of the server for an unknown name
+	// such as whisper-1.
+	Model string
+
+	// Filename is the name of the audio file and Audio its content.
+	Filename string
+	Audio    io.Reader
+
+	// Language is the ISO-639-1 code of the language spoken, detected when
+	// empty.
+	Language string
+
+	// Prompt guides the style of the transcription, or continues the one of
+	// a previous audio segment.
+	Prompt string
+
+	// ResponseFormat is json (the default), text, srt, vtt or verbose_json.
+	ResponseFormat string
+
+	// TimestampGranularities adds the words of the verbose_json response
+	// with word.
+	TimestampGranularities []string
+
+	// Temperature is the sampling temperature, 0 for greedy decoding.
+	Temperature *float32
+}
+
+// TranscriptionResponse is the response from [Client.Transcribe]. Text
+// holds the whole body of the text, srt and vtt response formats.
+type TranscriptionResponse struct {
+	Task     string                 `json:"task,omitempty"`
+	Language string                 `json:"language,omitempty"`
+	Duration float64                `json:"duration,omitempty"`
+	Text     string                 `json:"text"`
+	Segments []TranscriptionSegment `json:"segments,omitempty"`
+	Words    []TranscriptionWord    `json:"words,omitempty"`
+}
+
+// TranscriptionSegment is a segment of a transcription, with its start and
+// end in seconds.
+type TranscriptionSegment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+
+	// Confidence is the mean probability of the tokens of the segment.
+	Confidence float64 `json:"confidence"`
+}
+
+// TranscriptionWord is a word of a transcription, with its start and end in
+// seconds.
+type TranscriptionWord struct {
+	Word        string  `json:"word"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Probability float64 `json:"probability"`
+}
+
+// TranscriptionStreamResponse is a message of the transcription stream of
+// /api/audio/stream.
+type TranscriptionStreamResponse struct {
+	// Type is partial for the transcription so far of a segment still being
+	// spoken, final for a segment which ended, or error.
+	Type string `json:"type"`
+
+	// ID numbers the segments, the partials of a segment sharing the ID of
+	// its final. Start and End are in seconds from the start of the stream.
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
 // ShowRequest is the request passed to [Client.Show].
 type ShowRequest struct {
 	Model  string `json:"model,omitempty"`
@@ -493,6 +824,43 @@ type ShowResponse struct {
 	ModifiedAt    time.Time          `json:"modified_at,omitempty"`
 }
 
+// CreateRequest is the request passed to [Client.Create].
+type CreateRequest struct {
+	// Model is the name of the model to create.
+	Model  string `json:"model"`
+	Stream *bool  `json:"stream,omitempty"`
+
+	// From is the base model, either the path of a GGUF file, a GGUF file in
+	// the model directory or the name of a created model.
+	From string `json:"from"`
+
+	// Adapters lists the paths of the LoRA adapters applied to the model.
+	Adapters []string `json:"adapters,omitempty"`
+
+	Template string   `json:"template,omitempty"`
+	System   string   `json:"system,omitempty"`
+	License  []string `json:"license,omitempty"`
+	Renderer string   `json:"renderer,omitempty"`
+	Parser   string   `json:"parser,omitempty"`
+
+	// Parameters lists the default options of the model.
+	Parameters map[string]any `json:"parameters,omitempty"`
+
+	// Messages seeds the chats with the model.
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// DeleteRequest is the request passed to [Client.Delete].
+type DeleteRequest struct {
+	Model string `json:"model"`
+}
+
+// CopyRequest is the request passed to [Client.Copy].
+type CopyRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
 // PullRequest is the request passed to [Client.Pull].
 type PullRequest struct {
 	Model  string `json:"model"`
@@ -653,8 +1021,15 @@ func (m *Metrics) Summary() {
 		fmt.Fprintf(os.Stderr, "eval duration:        %s\n", m.EvalDuration)
 		fmt.Fprintf(os.Stderr, "eval rate:            %.2f tokens/s\n", float64(m.EvalCount)/m.EvalDuration.Seconds())
 	}
+
+	if m.DraftCount > 0 {
+		fmt.Fprintf(os.Stderr, "draft count:          %d token(s)\n", m.DraftCount)
+		fmt.Fprintf(os.Stderr, "draft acceptance:     %.2f%%\n", 100*float64(m.DraftAcceptedCount)/float64(m.DraftCount))
+	}
 }
 
+// FromMap sets the options found in m, keyed by their JSON names. Unknown
+// options and values of the wrong type are rejected.
 func (opts *Options) FromMap(m map[string]any) error {
 	valueOpts := reflect.ValueOf(opts).Elem() // names of the fields in the options struct
 	typeOpts := reflect.TypeOf(opts).Elem()   // types of the fields in the options struct
@@ -671,8 +1046,7 @@ func (opts *Options) FromMap(m map[string]any) error {
 	for key, val := range m {
 		opt, ok := jsonOpts[key]
 		if !ok {
-			log.Warn("invalid option provided", "option", key)
-			continue
+			return fmt.Errorf("invalid option provided: %q", key)
 		}
 
 		field := valueOpts.FieldByName(opt.Name)
@@ -684,11 +1058,22 @@ func (opts *Options) FromMap(m map[string]any) error {
 			switch field.Kind() {
 			case reflect.Int:
 				switch t := val.(type) {
+				case int:
+					field.SetInt(int64(t))
 				case int64:
 					field.SetInt(t)
 				case float64:
 					// when JSON unmarshals numbers, it uses float64, not int
+					if t != math.Trunc(t) {
+						return fmt.Errorf("option %q must be of type integer", key)
+					}
 					field.SetInt(int64(t))
+				case json.Number:
+					i, err := t.Int64()
+					if err != nil {
+						return fmt.Errorf("option %q must be of type integer", key)
+					}
+					field.SetInt(i)
 				default:
 					return fmt.Errorf("option %q must be of type integer", key)
 				}
@@ -700,11 +1085,20 @@ func (opts *Options) FromMap(m map[string]any) error {
 				field.SetBool(val)
 			case reflect.Float32:
 				// JSON unmarshals to float64
-				val, ok := val.(float64)
-				if !ok {
+				switch t := val.(type) {
+				case float64:
+					field.SetFloat(t)
+				case int:
+					field.SetFloat(float64(t))
+				case json.Number:
+					f, err := t.Float64()
+					if err != nil {
+						return fmt.Errorf("option %q must be of type float32", key)
+					}
+					field.SetFloat(f)
+				default:
 					return fmt.Errorf("option %q must be of type float32", key)
 				}
-				field.SetFloat(val)
 			case reflect.String:
 				val, ok := val.(string)
 				if !ok {
@@ -747,6 +1141,35 @@ func (opts *Options) FromMap(m map[string]any) error {
 	return nil
 }
 
+// Validate checks that the sampling options are within their valid ranges.
+func (opts *Options) Validate() error {
+	switch {
+	case opts.Temperature < 0:
+		return fmt.Errorf("option \"temperature\" must be non-negative, got %v", opts.Temperature)
+	case opts.TopK < 0:
+		return fmt.Errorf("option \"top_k\" must be non-negative, got %d", opts.TopK)
+	case opts.TopP < 0 || opts.TopP > 1:
+		return fmt.Errorf("option \"top_p\" must be between 0 and 1, got %v", opts.TopP)
+	case opts.MinP < 0 || opts.MinP > 1:
+		return fmt.Errorf("option \"min_p\" must be between 0 and 1, got %v", opts.MinP)
+	case opts.TypicalP < 0 || opts.TypicalP > 1:
+		return fmt.Errorf("option \"typical_p\" must be between 0 and 1, got %v", opts.TypicalP)
+	case opts.RepeatPenalty < 0:
+		return fmt.Errorf("option \"repeat_penalty\" must be non-negative, got %v", opts.RepeatPenalty)
+	case opts.PresencePenalty < -2 || opts.PresencePenalty > 2:
+		return fmt.Errorf("option \"presence_penalty\" must be between -2 and 2, got %v", opts.PresencePenalty)
+	case opts.FrequencyPenalty < -2 || opts.FrequencyPenalty > 2:
+		return fmt.Errorf("option \"frequency_penalty\" must be between -2 and 2, got %v", opts.FrequencyPenalty)
+	case opts.RepeatLastN < -1:
+		return fmt.Errorf("option \"repeat_last_n\" must be -1 or greater, got %d", opts.RepeatLastN)
+	case opts.NumPredict < -2:
+		return fmt.Errorf("option \"num_predict\" must be -2 or greater, got %d", opts.NumPredict)
+	case opts.NumKeep < -1:
+		return fmt.Errorf("option \"num_keep\" must be -1 or greater, got %d", opts.NumKeep)
+	}
+	return nil
+}
+
 // DefaultOptions is the default set of options for [GenerateRequest]; these
 // values are used unless the user specifies other values explicitly.
 func DefaultOptions() Options {
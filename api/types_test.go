@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToolChoice(t *testing.T) {
+	cases := []struct {
+		in       string
+		want     ToolChoice
+		required bool
+		err      bool
+	}{
+		{in: `"auto"`, want: ToolChoice{Mode: "auto"}},
+		{in: `"none"`, want: ToolChoice{Mode: "none"}},
+		{in: `"required"`, want: ToolChoice{Mode: "required"}, required: true},
+		{in: `{"type":"function","function":{"name":"get_weather"}}`, want: ToolChoice{Function: "get_weather"}, required: true},
+		{in: `"always"`, err: true},
+		{in: `{"type":"function","function":{}}`, err: true},
+		{in: `{"type":"tool","function":{"name":"get_weather"}}`, err: true},
+		{in: `1`, err: true},
+	}
+
+	for _, tt := range cases {
+		var tc ToolChoice
+		err := json.Unmarshal([]byte(tt.in), &tc)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if tc != tt.want || tc.Required() != tt.required {
+			t.Errorf("%s: got %+v (required %v), want %+v (required %v)", tt.in, tc, tc.Required(), tt.want, tt.required)
+		}
+
+		bts, err := json.Marshal(tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again ToolChoice
+		if err := json.Unmarshal(bts, &again); err != nil || again != tc {
+			t.Errorf("%s: round trip through %s gave %+v, %v", tt.in, bts, again, err)
+		}
+	}
+
+	var req ChatRequest
+	if err := json.Unmarshal([]byte(`{"tool_choice":"required","parallel_tool_calls":false}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if !req.ToolChoice.Required() || req.ParallelToolCalls == nil || *req.ParallelToolCalls {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestToolFunctionParametersValidate(t *testing.T) {
+	var params ToolFunctionParameters
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["location"],
+		"properties": {
+			"location": {"type": "string"},
+			"unit": {"type": "string", "enum": ["celsius", "fahrenheit"]},
+			"days": {"type": "integer"},
+			"hours": {"type": ["number", "null"]},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"extra": {"anyOf": [{"type": "boolean"}, {"type": "object"}]}
+		}
+	}`), &params); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := []string{
+		`{"location":"Paris"}`,
+		`{"location":"Paris","unit":"celsius","days":3,"hours":null}`,
+		`{"location":"Paris","hours":1.5,"tags":["a","b"],"extra":true}`,
+		`{"location":"Paris","extra":{"a":1}}`,
+		`{"location":"Paris","country":"FR"}`,
+	}
+	invalid := []string{
+		`{}`,
+		`{"location":1}`,
+		`{"location":"Paris","unit":"kelvin"}`,
+		`{"location":"Paris","days":1.5}`,
+		`{"location":"Paris","days":"3"}`,
+		`{"location":"Paris","tags":["a",1]}`,
+		`{"location":"Paris","extra":"yes"}`,
+	}
+
+	for _, in := range valid {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(in), &args); err != nil {
+			t.Fatal(err)
+		}
+		if err := params.Validate(args); err != nil {
+			t.Errorf("%s: unexpected error %v", in, err)
+		}
+	}
+	for _, in := range invalid {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(in), &args); err != nil {
+			t.Fatal(err)
+		}
+		if err := params.Validate(args); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestToolFunctionParametersAdditionalProperties(t *testing.T) {
+	cases := []struct {
+		additional string
+		args       string
+		valid      bool
+	}{
+		{`false`, `{"location":"Paris"}`, true},
+		{`false`, `{"location":"Paris","country":"FR"}`, false},
+		{`true`, `{"location":"Paris","country":"FR"}`, true},
+		{`{"type":"string"}`, `{"location":"Paris","country":"FR"}`, true},
+		{`{"type":"string"}`, `{"location":"Paris","zip":75001}`, false},
+		{`{"type":"string"}`, `{"location":1}`, false},
+	}
+
+	for _, c := range cases {
+		var params ToolFunctionParameters
+		schema := `{"type":"object","properties":{"location":{"type":"string"}},"additionalProperties":` + c.additional + `}`
+		if err := json.Unmarshal([]byte(schema), &params); err != nil {
+			t.Fatal(err)
+		}
+		var args map[string]any
+		if err := json.Unmarshal([]byte(c.args), &args); err != nil {
+			t.Fatal(err)
+		}
+		if err := params.Validate(args); (err == nil) != c.valid {
+			t.Errorf("additionalProperties %s, %s: got error %v, want valid %v", c.additional, c.args, err, c.valid)
+		}
+	}
+}
+
+func TestOptionsFromMap(t *testing.T) {
+	var opts Options
+	err := opts.FromMap(map[string]any{
+		"temperature": 0.2,
+		"top_k":       float64(20),
+		"seed":        json.Number("42"),
+		"top_p":       json.Number("0.5"),
+		"stop":        []any{"\n", "###"},
+		"use_mmap":    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Temperature != 0.2 || opts.TopK != 20 || opts.Seed != 42 || opts.TopP != 0.5 ||
+		len(opts.Stop) != 2 || opts.UseMMap == nil || !*opts.UseMMap {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	for name, m := range map[string]map[string]any{
+		"unknown option":    {"temprature": 0.2},
+		"string for float":  {"temperature": "hot"},
+		"fraction for int":  {"top_k": 1.5},
+		"string for int":    {"seed": "1"},
+		"mixed stop":        {"stop": []any{"a", 1}},
+		"stop not an array": {"stop": "a"},
+	} {
+		var opts Options
+		if err := opts.FromMap(m); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := DefaultOptions()
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("default options are invalid: %v", err)
+	}
+
+	for name, m := range map[string]map[string]any{
+		"negative temperature": {"temperature": -1.0},
+		"top_p above one":      {"top_p": 1.5},
+		"negative top_k":       {"top_k": -1.0},
+		"min_p below zero":     {"min_p": -0.1},
+		"large penalty":        {"presence_penalty": 3.0},
+		"num_predict":          {"num_predict": -3.0},
+	} {
+		opts := DefaultOptions()
+		if err := opts.FromMap(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -23,6 +23,9 @@ func Run() error {
 		Flags:                config.AppFlags,
 		EnableBashCompletion: true,
 		Commands:             commands(),
+		Before: func(c *cli.Context) error {
+			return config.Conf.LoadFile(c)
+		},
 		Action: func(c *cli.Context) error {
 			print(version.String())
 			return nil
@@ -5,17 +5,20 @@ import (
 	"fmt"
 
 	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/app/cp"
+	"github.com/Qitmeer/llama.go/app/create"
 	"github.com/Qitmeer/llama.go/app/embedding"
 	econfig "github.com/Qitmeer/llama.go/app/embedding/config"
 	"github.com/Qitmeer/llama.go/app/pull"
+	"github.com/Qitmeer/llama.go/app/rm"
 	"github.com/Qitmeer/llama.go/app/run"
-	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/app/whisper"
+	wconfig "github.com/Qitmeer/llama.go/app/whisper/config"
 	"github.com/Qitmeer/llama.go/config"
 	"github.com/Qitmeer/llama.go/server"
 	"github.com/Qitmeer/llama.go/system"
 	"github.com/Qitmeer/llama.go/system/limits"
 	"github.com/Qitmeer/llama.go/version"
-	"github.com/Qitmeer/llama.go/wrapper"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
@@ -26,8 +29,12 @@ func commands() []*cli.Command {
 	cmds = append(cmds, serveCmd())
 	cmds = append(cmds, runCmd())
 	cmds = append(cmds, pullCmd())
+	cmds = append(cmds, createCmd())
+	cmds = append(cmds, cpCmd())
+	cmds = append(cmds, rmCmd())
 	cmds = append(cmds, embeddingCmd())
 	cmds = append(cmds, whisperCmd())
+	cmds = append(cmds, configCmd())
 	return cmds
 }
 
@@ -94,9 +101,13 @@ func serveCmd() *cli.Command {
 				return err
 			}
 			interrupt := system.InterruptListener()
+			reload := system.ReloadListener()
 			cfg := config.Conf
 
 			ser := server.New(ctx, cfg)
+			ser.OnReload(func(cfg *config.Config) {
+				initLog(cfg)
+			})
 
 			err = ser.Start()
 			defer func() {
@@ -109,9 +120,16 @@ func serveCmd() *cli.Command {
 			if err != nil {
 				return err
 			}
-			<-interrupt
-
-			return nil
+			for {
+				select {
+				case <-interrupt:
+					return nil
+				case <-reload:
+					if err := ser.Reload(); err != nil {
+						log.Error("Failed to reload config", "error", err)
+					}
+				}
+			}
 		},
 	}
 }
@@ -129,12 +147,50 @@ func pullCmd() *cli.Command {
 	}
 }
 
+func createCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "create",
+		Aliases:     []string{"cr"},
+		Category:    "llama",
+		Usage:       "llama.go create MODEL [-f Modelfile]",
+		Description: "Create a named model from a Modelfile",
+		ArgsUsage:   "MODEL",
+		Flags:       create.AppFlags,
+		Before:      OnBefore,
+		Action:      create.CreateHandler,
+	}
+}
+
+func cpCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "cp",
+		Category:    "llama",
+		Usage:       "llama.go cp SOURCE DESTINATION",
+		Description: "Copy a model, or give a GGUF model file an alias",
+		ArgsUsage:   "SOURCE DESTINATION",
+		Before:      OnBefore,
+		Action:      cp.CpHandler,
+	}
+}
+
+func rmCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "rm",
+		Category:    "llama",
+		Usage:       "llama.go rm MODEL [MODEL...]",
+		Description: "Remove models created from a Modelfile",
+		ArgsUsage:   "MODEL [MODEL...]",
+		Before:      OnBefore,
+		Action:      rm.RmHandler,
+	}
+}
+
 func embeddingCmd() *cli.Command {
 	return &cli.Command{
 		Name:        "embedding",
 		Aliases:     []string{"e"},
 		Category:    "llama",
-		Usage:       "llama.go embedding [PROMPT]",
+		Usage:       "llama.go embedding [PROMPT...]",
 		Description: "Generate high-dimensional embedding vector of a given text",
 		Flags:       econfig.AppFlags,
 		Before:      OnBeforeForServe,
@@ -148,33 +204,42 @@ func whisperCmd() *cli.Command {
 		Aliases:     []string{"w"},
 		Category:    "whisper",
 		Usage:       "Generate text by whisper model",
-		Description: "Generate text by whisper model",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:    "input",
-				Aliases: []string{"i"},
-				Usage:   "Input file path for generate.",
+		Description: "Transcribe audio by whisper model, as text, SRT or WebVTT subtitles, or JSON with the segments and their timestamps",
+		Flags:       wconfig.AppFlags,
+		Before:      OnBefore,
+		Action:      whisper.WhisperHandler,
+	}
+}
+
+func configCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "config",
+		Category:    "llama",
+		Usage:       "llama.go config print",
+		Description: "Inspect the configuration",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "print",
+				Usage:       "llama.go config print [--format yaml|toml]",
+				Description: "Print the effective configuration, after the config file, the environment and the command line",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "Output format {yaml, toml}",
+						Value:   "yaml",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					ret, err := config.Conf.Print(ctx.String("format"))
+					if err != nil {
+						return err
+					}
+					fmt.Print(ret)
+					return nil
+				},
 			},
 		},
-		Before: OnBefore,
-		Action: func(ctx *cli.Context) error {
-			cfg := config.Conf
-			log.Info("Start whisper")
-			if !ctx.IsSet("input") {
-				return fmt.Errorf("No input file")
-			}
-			ret, err := wrapper.WhisperGenerate(cfg, ctx.Value("input").(string))
-			if err != nil {
-				return err
-			}
-			if len(cfg.OutputFile) > 0 {
-				return common.SaveOutputToFile(cfg.OutputFile, ret)
-			} else {
-				fmt.Println("result:")
-				fmt.Println(ret)
-			}
-			return nil
-		},
 	}
 }
 
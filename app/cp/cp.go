@@ -0,0 +1,22 @@
+package cp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/urfave/cli/v2"
+)
+
+func CpHandler(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		return errors.New("usage: llama.go cp SOURCE DESTINATION")
+	}
+
+	req := &api.CopyRequest{Source: ctx.Args().Get(0), Destination: ctx.Args().Get(1)}
+	if err := api.DefaultClient().Copy(ctx.Context, req); err != nil {
+		return err
+	}
+	fmt.Printf("copied '%s' to '%s'\n", req.Source, req.Destination)
+	return nil
+}
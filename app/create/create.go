@@ -0,0 +1,70 @@
+package create
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/common/progress"
+	"github.com/Qitmeer/llama.go/model/parser"
+	"github.com/urfave/cli/v2"
+)
+
+var AppFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "file",
+		Aliases: []string{"f"},
+		Usage:   "Path of the Modelfile",
+		Value:   "Modelfile",
+	},
+}
+
+func CreateHandler(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		return errors.New("usage: llama.go create MODEL [-f Modelfile]")
+	}
+	name := ctx.Args().First()
+
+	filename, err := filepath.Abs(ctx.String("file"))
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open Modelfile: %w", err)
+	}
+	defer f.Close()
+
+	modelfile, err := parser.ParseFile(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse Modelfile: %w", err)
+	}
+	req, err := modelfile.CreateRequest(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	req.Model = name
+
+	p := progress.NewProgress(os.Stderr)
+	defer p.Stop()
+
+	var status string
+	var spinner *progress.Spinner
+	fn := func(resp api.ProgressResponse) error {
+		if status == resp.Status {
+			return nil
+		}
+		if spinner != nil {
+			spinner.Stop()
+		}
+		status = resp.Status
+		spinner = progress.NewSpinner(status)
+		p.Add(status, spinner)
+		return nil
+	}
+
+	client := api.DefaultClient()
+	return client.Create(ctx.Context, req, fn)
+}
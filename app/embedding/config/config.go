@@ -7,9 +7,11 @@ import (
 )
 
 const (
-	DefaultEmbdNormalize    = 2
-	DefaultEmbdOutputFormat = "json"
-	DefaultEmbdSeparator    = "<#sep#>"
+	DefaultEmbdNormalize = 2
+	DefaultEmbdSeparator = "<#sep#>"
+	DefaultTopK          = 5
+	DefaultMetric        = "cosine"
+	DefaultEmbdBatch     = 32
 )
 
 var (
@@ -26,23 +28,69 @@ var (
 	EmbdOutputFormat = &cli.StringFlag{
 		Name:        "embd-output-format",
 		Aliases:     []string{"FORMAT"},
-		Usage:       "empty = default, \"array\" = [[],[]...], \"json\" = openai style, \"json+\" = same \"json\" + cosine similarity matrix",
-		Value:       DefaultEmbdOutputFormat,
+		Usage:       "empty = JSONL, or .npy by the extension of --output-file, \"array\" = [[],[]...], \"json\" = openai style, \"json+\" = same \"json\" + cosine similarity matrix",
 		Destination: &Conf.EmbdOutputFormat,
 	}
 
 	EmbdSeparator = &cli.StringFlag{
 		Name:        "embd-separator",
 		Aliases:     []string{"STRING"},
-		Usage:       "separator of embeddings (default \\n) for example \"<#sep#>\\",
+		Usage:       "separator of the documents within a prompt argument",
 		Value:       DefaultEmbdSeparator,
 		Destination: &Conf.EmbdSeparator,
 	}
 
+	Index = &cli.StringFlag{
+		Name:        "index",
+		Usage:       "corpus to embed into a vector store (written to --output-file, or next to the corpus with the .vec extension), in the format of --input-file, or the vector store to search",
+		Destination: &Conf.Index,
+	}
+
+	Search = &cli.StringFlag{
+		Name:        "search",
+		Usage:       "query to search the vector store of --index for",
+		Destination: &Conf.Search,
+	}
+
+	TopK = &cli.IntFlag{
+		Name:        "top-k",
+		Aliases:     []string{"k"},
+		Usage:       "number of matches returned by --search",
+		Value:       DefaultTopK,
+		Destination: &Conf.TopK,
+	}
+
+	Metric = &cli.StringFlag{
+		Name:        "metric",
+		Usage:       "similarity of the matches of --search {cosine,dot,l2}",
+		Value:       DefaultMetric,
+		Destination: &Conf.Metric,
+	}
+
+	EmbdBatch = &cli.IntFlag{
+		Name:        "embd-batch",
+		Aliases:     []string{"index-batch"},
+		Usage:       "number of documents embedded at once",
+		Value:       DefaultEmbdBatch,
+		Destination: &Conf.EmbdBatch,
+	}
+
+	InputFile = &cli.StringFlag{
+		Name:        "input-file",
+		Usage:       "file of the documents to embed, - for stdin: text with a document per line, or JSONL with an id and a text per line",
+		Destination: &Conf.InputFile,
+	}
+
 	AppFlags = []cli.Flag{
 		EmbdNormalize,
 		EmbdOutputFormat,
 		EmbdSeparator,
+		Index,
+		Search,
+		TopK,
+		Metric,
+		EmbdBatch,
+		InputFile,
 	}
 )
 
@@ -50,4 +98,10 @@ type Config struct {
 	EmbdNormalize    int
 	EmbdOutputFormat string
 	EmbdSeparator    string
+	Index            string
+	Search           string
+	TopK             int
+	Metric           string
+	EmbdBatch        int
+	InputFile        string
 }
@@ -1,34 +1,105 @@
 package embedding
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	econfig "github.com/Qitmeer/llama.go/app/embedding/config"
 	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/common/vector"
 	"github.com/Qitmeer/llama.go/config"
-	"github.com/Qitmeer/llama.go/wrapper"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
 
+// EmbeddingHandler embeds the documents of --input-file, or the prompts given
+// as arguments, and writes their vectors to --output-file: a .npy matrix with
+// a row per document, otherwise JSONL or the --embd-output-format. Without
+// output file, they go to stdout.
 func EmbeddingHandler(ctx *cli.Context) error {
-	var prompts string
-	if ctx.Args().Len() > 0 {
-		prompts = ctx.Args().Slice()[0]
+	cfg, econf := config.Conf, econfig.Conf
+	switch {
+	case len(econf.Search) > 0:
+		return search(ctx, cfg, econf)
+	case len(econf.Index) > 0:
+		return index(ctx, cfg, econf)
 	}
-	if len(prompts) <= 0 {
-		return fmt.Errorf("No prompt")
+
+	var docs []vector.Record
+	if len(econf.InputFile) > 0 {
+		var err error
+		if docs, err = readInput(econf.InputFile); err != nil {
+			return err
+		}
+	} else if ctx.Args().Len() == 1 && ctx.Args().First() == "-" {
+		var err error
+		if docs, err = readInput("-"); err != nil {
+			return err
+		}
+	} else {
+		for _, arg := range ctx.Args().Slice() {
+			for _, prompt := range strings.Split(arg, econf.EmbdSeparator) {
+				docs = append(docs, vector.Record{ID: strconv.Itoa(len(docs) + 1), Text: prompt})
+			}
+		}
 	}
-	cfg := config.Conf
-	log.Info("Start embedding")
-	ret, err := wrapper.LlamaEmbedding(cfg, prompts, "")
+	if len(docs) <= 0 {
+		return errors.New("No prompt")
+	}
+
+	npy := strings.EqualFold(filepath.Ext(cfg.OutputFile), ".npy")
+	switch econf.EmbdOutputFormat {
+	case "":
+	case "array", "json", "json+":
+		if npy {
+			return errors.New("--embd-output-format does not apply to a .npy output file")
+		}
+	default:
+		return fmt.Errorf("unknown --embd-output-format %q, expected array, json or json+", econf.EmbdOutputFormat)
+	}
+
+	log.Info("Start embedding", "documents", len(docs))
+	r, err := startRunner(ctx, cfg)
 	if err != nil {
 		return err
 	}
-	if len(cfg.OutputFile) > 0 {
-		return common.SaveOutputToFile(cfg.OutputFile, ret)
+	defer r.Stop()
+
+	var write func(w io.Writer) error
+	if npy || len(econf.EmbdOutputFormat) > 0 {
+		vectors := make([][]float32, 0, len(docs))
+		err := embedBatches(r, docs, econf, func(batch []vector.Record) error {
+			for _, doc := range batch {
+				vectors = append(vectors, doc.Vector)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error {
+			if npy {
+				return vector.WriteNPY(w, vectors)
+			}
+			return writeFormatted(w, econf.EmbdOutputFormat, vectors)
+		}
 	} else {
-		fmt.Println("result:")
-		fmt.Println(ret)
+		write = func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			return embedBatches(r, docs, econf, func(batch []vector.Record) error {
+				for _, doc := range batch {
+					if err := enc.Encode(map[string]any{"id": doc.ID, "embedding": doc.Vector}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
 	}
-	return nil
+	return common.WriteOutput(cfg.OutputFile, write)
 }
@@ -0,0 +1,44 @@
+package embedding
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Qitmeer/llama.go/common/vector"
+)
+
+// writeFormatted writes the vectors in the --embd-output-format of the
+// llama.cpp embedding example: array is a JSON array of the vectors, json
+// an OpenAI style list and json+ the list along with the cosine similarity
+// of each pair of vectors.
+func writeFormatted(w io.Writer, format string, vectors [][]float32) error {
+	enc := json.NewEncoder(w)
+	if format == "array" {
+		return enc.Encode(vectors)
+	}
+
+	type embedding struct {
+		Object    string    `json:"object"`
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	}
+	resp := struct {
+		Object           string      `json:"object"`
+		Data             []embedding `json:"data"`
+		CosineSimilarity [][]float64 `json:"cosineSimilarity,omitempty"`
+	}{Object: "list", Data: make([]embedding, 0, len(vectors))}
+	for i, v := range vectors {
+		resp.Data = append(resp.Data, embedding{Object: "embedding", Index: i, Embedding: v})
+	}
+	if format == "json+" {
+		resp.CosineSimilarity = make([][]float64, len(vectors))
+		for i, a := range vectors {
+			resp.CosineSimilarity[i] = make([]float64, len(vectors))
+			for j, b := range vectors {
+				resp.CosineSimilarity[i][j] = vector.Cosine.Score(a, b)
+			}
+		}
+	}
+	enc.SetIndent("", "  ")
+	return enc.Encode(resp)
+}
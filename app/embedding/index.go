@@ -0,0 +1,162 @@
+package embedding
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	econfig "github.com/Qitmeer/llama.go/app/embedding/config"
+	"github.com/Qitmeer/llama.go/common/progress"
+	"github.com/Qitmeer/llama.go/common/vector"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/runner"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// startRunner starts the core computing the embeddings.
+func startRunner(ctx *cli.Context, cfg *config.Config) (*runner.Service, error) {
+	// the model may not declare a pooling type, e.g. a chat model
+	cfg.Embeddings = true
+	var current atomic.Pointer[config.Config]
+	current.Store(cfg)
+	r := runner.New(ctx, &current)
+	if err := r.Start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// embedBatches embeds the documents by batches of --embd-batch, passing each
+// batch to fn with its vectors. The progress is shown when there is more
+// than one batch.
+func embedBatches(r *runner.Service, docs []vector.Record, econf *econfig.Config, fn func([]vector.Record) error) error {
+	if econf.EmbdBatch <= 0 {
+		return fmt.Errorf("invalid --embd-batch %d", econf.EmbdBatch)
+	}
+	var bar *progress.Bar
+	if len(docs) > econf.EmbdBatch {
+		p := progress.NewProgress(os.Stderr)
+		defer p.Stop()
+		bar = progress.NewBar("embedding", int64(len(docs)), 0)
+		p.Add("embedding", bar)
+	}
+	for start := 0; start < len(docs); start += econf.EmbdBatch {
+		batch := docs[start:min(start+econf.EmbdBatch, len(docs))]
+		input := make([]any, 0, len(batch))
+		for _, doc := range batch {
+			input = append(input, doc.Text)
+		}
+		embeddings, err := r.Embed(input, econf.EmbdNormalize)
+		if err != nil {
+			return err
+		}
+		if len(embeddings) != len(batch) {
+			return fmt.Errorf("got %d embeddings for %d documents", len(embeddings), len(batch))
+		}
+		for i := range batch {
+			batch[i].Vector = embeddings[i]
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if bar != nil {
+			bar.Set(int64(start + len(batch)))
+		}
+	}
+	return nil
+}
+
+// index embeds the corpus of --index into a vector store.
+func index(ctx *cli.Context, cfg *config.Config, econf *econfig.Config) error {
+	docs, err := readInput(econf.Index)
+	if err != nil {
+		return err
+	}
+	if len(docs) <= 0 {
+		return fmt.Errorf("%s has no documents", econf.Index)
+	}
+	out := cfg.OutputFile
+	if len(out) <= 0 {
+		out = strings.TrimSuffix(econf.Index, filepath.Ext(econf.Index)) + ".vec"
+	}
+	if out == econf.Index {
+		return errors.New("the vector store would overwrite the corpus, set --output-file")
+	}
+
+	r, err := startRunner(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer r.Stop()
+
+	var w *vector.Writer
+	defer func() {
+		// left open by an error, the store file is left as it was
+		if w != nil {
+			w.Abort()
+		}
+	}()
+	err = embedBatches(r, docs, econf, func(batch []vector.Record) error {
+		if w == nil {
+			var err error
+			if w, err = vector.Create(out, len(batch[0].Vector)); err != nil {
+				return err
+			}
+		}
+		for _, doc := range batch {
+			if err := w.Write(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	w = nil
+	if err != nil {
+		return err
+	}
+	log.Info("Wrote vector store", "path", out, "documents", len(docs))
+	return nil
+}
+
+// search prints the documents of the vector store of --index closest to the
+// --search query.
+func search(ctx *cli.Context, cfg *config.Config, econf *econfig.Config) error {
+	if len(econf.Index) <= 0 {
+		return errors.New("--search requires the vector store to search with --index")
+	}
+	metric, err := vector.ParseMetric(econf.Metric)
+	if err != nil {
+		return err
+	}
+	store, err := vector.Load(econf.Index)
+	if err != nil {
+		return err
+	}
+
+	r, err := startRunner(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer r.Stop()
+
+	embeddings, err := r.Embed([]any{econf.Search}, econf.EmbdNormalize)
+	if err != nil {
+		return err
+	}
+	matches, err := store.Search(embeddings[0], econf.TopK, metric)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		fmt.Printf("%.4f\t%s\t%s\n", m.Score, m.ID, m.Text)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package embedding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/Qitmeer/llama.go/common/vector"
+)
+
+// document is a line of a JSONL input. Documents without id are given their
+// line number.
+type document struct {
+	ID   any    `json:"id"`
+	Text string `json:"text"`
+}
+
+// readInput reads the documents of the file at path, or of stdin for -.
+func readInput(path string) ([]vector.Record, error) {
+	if path == "-" {
+		return readDocuments(os.Stdin, "stdin")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readDocuments(f, path)
+}
+
+// readDocuments reads a document per line of r. The lines are JSON objects
+// with an id and a text when the first one is, otherwise the texts of the
+// documents, identified by their line number.
+func readDocuments(r io.Reader, name string) ([]vector.Record, error) {
+	var ret []vector.Record
+	jsonl := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) <= 0 {
+			continue
+		}
+		if len(ret) == 0 {
+			jsonl = text[0] == '{'
+		}
+		id := strconv.Itoa(line)
+		if !jsonl {
+			ret = append(ret, vector.Record{ID: id, Text: scanner.Text()})
+			continue
+		}
+		var doc document
+		if err := json.Unmarshal(text, &doc); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		if len(doc.Text) <= 0 {
+			return nil, fmt.Errorf("%s:%d: missing text", name, line)
+		}
+		if doc.ID != nil {
+			id = fmt.Sprint(doc.ID)
+		}
+		ret = append(ret, vector.Record{ID: id, Text: doc.Text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,24 @@
+package rm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/urfave/cli/v2"
+)
+
+func RmHandler(ctx *cli.Context) error {
+	if ctx.Args().Len() == 0 {
+		return errors.New("usage: llama.go rm MODEL [MODEL...]")
+	}
+
+	client := api.DefaultClient()
+	for _, name := range ctx.Args().Slice() {
+		if err := client.Delete(ctx.Context, &api.DeleteRequest{Model: name}); err != nil {
+			return err
+		}
+		fmt.Printf("deleted '%s'\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2017-2025 The qitmeer developers
+
+package config
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	DefaultOutputFormat = "txt"
+	DefaultLanguage     = "auto"
+)
+
+var (
+	Conf = &Config{}
+
+	Input = &cli.StringFlag{
+		Name:        "input",
+		Aliases:     []string{"i"},
+		Usage:       "Input file path for generate.",
+		Destination: &Conf.Input,
+	}
+
+	OutputFormat = &cli.StringFlag{
+		Name:        "output-format",
+		Aliases:     []string{"f"},
+		Usage:       "format of the transcription {txt,srt,vtt,json}",
+		Value:       DefaultOutputFormat,
+		Destination: &Conf.OutputFormat,
+	}
+
+	Language = &cli.StringFlag{
+		Name:        "language",
+		Aliases:     []string{"l"},
+		Usage:       "spoken language, auto to detect it",
+		Value:       DefaultLanguage,
+		Destination: &Conf.Language,
+	}
+
+	Translate = &cli.BoolFlag{
+		Name:        "translate",
+		Usage:       "translate the speech to English",
+		Destination: &Conf.Translate,
+	}
+
+	Threads = &cli.IntFlag{
+		Name:        "threads",
+		Usage:       "number of threads transcribing, 0 for the --threads of llama.go, or the core default",
+		Destination: &Conf.Threads,
+	}
+
+	WordTimestamps = &cli.BoolFlag{
+		Name:        "word-timestamps",
+		Usage:       "add the words with their timestamps to the json format",
+		Destination: &Conf.WordTimestamps,
+	}
+
+	AppFlags = []cli.Flag{
+		Input,
+		OutputFormat,
+		Language,
+		Translate,
+		Threads,
+		WordTimestamps,
+	}
+)
+
+type Config struct {
+	Input          string
+	OutputFormat   string
+	Language       string
+	Translate      bool
+	Threads        int
+	WordTimestamps bool
+}
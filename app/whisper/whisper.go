@@ -0,0 +1,108 @@
+package whisper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Qitmeer/llama.go/api"
+	wconfig "github.com/Qitmeer/llama.go/app/whisper/config"
+	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/common/audio"
+	"github.com/Qitmeer/llama.go/common/transcript"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/wrapper"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// WhisperHandler transcribes the audio of --input with the whisper model of
+// --whisper-model, or --model, and writes it in --output-format to
+// --output-file, or stdout.
+func WhisperHandler(ctx *cli.Context) error {
+	cfg, wconf := config.Conf, wconfig.Conf
+	if len(wconf.Input) <= 0 {
+		return errors.New("No input file")
+	}
+	write, err := writer(wconf.OutputFormat)
+	if err != nil {
+		return err
+	}
+	model := cfg.WhisperModelPath()
+	if len(model) <= 0 {
+		model = cfg.GetWhisperModelPath(cfg.Model)
+	}
+	if len(model) <= 0 {
+		return errors.New("No model")
+	}
+	samples, err := audio.DecodeFile(wconf.Input)
+	if err != nil {
+		return err
+	}
+	threads := wconf.Threads
+	if threads <= 0 {
+		threads = cfg.Threads
+	}
+
+	log.Info("Start whisper", "model", model, "input", wconf.Input, "seconds", len(samples)/audio.SampleRate)
+	bts, err := json.Marshal(map[string]any{
+		"model":           model,
+		"language":        wconf.Language,
+		"translate":       wconf.Translate,
+		"threads":         threads,
+		"word_timestamps": wconf.WordTimestamps && wconf.OutputFormat == "json",
+	})
+	if err != nil {
+		return err
+	}
+	status, jsonStr := wrapper.WhisperTranscribeHTTP(string(bts), samples)
+	if status != http.StatusOK {
+		return errors.New(transcript.ErrorMessage(jsonStr))
+	}
+	var resp api.TranscriptionResponse
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		return err
+	}
+	resp.Task = "transcribe"
+	if wconf.Translate {
+		resp.Task = "translate"
+	}
+	resp.Text = strings.TrimSpace(resp.Text)
+
+	return common.WriteOutput(cfg.OutputFile, func(w io.Writer) error {
+		return write(w, &resp)
+	})
+}
+
+// writer returns the function writing a transcription in format.
+func writer(format string) (func(io.Writer, *api.TranscriptionResponse) error, error) {
+	switch format {
+	case "txt":
+		return func(w io.Writer, resp *api.TranscriptionResponse) error {
+			for _, s := range resp.Segments {
+				if _, err := fmt.Fprintln(w, strings.TrimSpace(s.Text)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case "srt":
+		return func(w io.Writer, resp *api.TranscriptionResponse) error {
+			return transcript.WriteSRT(w, resp.Segments)
+		}, nil
+	case "vtt":
+		return func(w io.Writer, resp *api.TranscriptionResponse) error {
+			return transcript.WriteVTT(w, resp.Segments)
+		}, nil
+	case "json":
+		return func(w io.Writer, resp *api.TranscriptionResponse) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(resp)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected txt, srt, vtt or json", format)
+}
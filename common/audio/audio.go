@@ -0,0 +1,104 @@
+// Package audio decodes WAV, FLAC and MP3 into the 16 kHz mono samples
+// whisper transcribes.
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SampleRate is the sample rate of whisper.
+const SampleRate = 16000
+
+var (
+	// ErrUnknownFormat is returned for audio which is not WAV, FLAC or MP3.
+	ErrUnknownFormat = errors.New("unknown audio format, expected WAV, FLAC or MP3")
+	// ErrTooLong is returned by Decode for audio longer than its limit.
+	ErrTooLong = errors.New("the audio is too long")
+)
+
+// Audio is decoded audio, with its samples in [-1, 1] interleaved by
+// channel.
+type Audio struct {
+	Rate     int
+	Channels int
+	Samples  []float32
+}
+
+// Decode decodes the audio of r and returns its samples downmixed to mono
+// and resampled to SampleRate. It stops with ErrTooLong once the audio is
+// longer than limit, unless limit is 0.
+func Decode(r io.Reader, limit time.Duration) ([]float32, error) {
+	a, err := decode(r, limit)
+	if err != nil {
+		return nil, err
+	}
+	return Resample(Downmix(a.Samples, a.Channels), a.Rate, SampleRate), nil
+}
+
+// DecodeFile decodes the audio file at path as Decode, whatever its length.
+func DecodeFile(path string) ([]float32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f, 0)
+}
+
+// decode decodes r in the format given by its first bytes.
+func decode(r io.Reader, limit time.Duration) (*Audio, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(12)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	var a *Audio
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		a, err = decodeWAV(br, limit)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		a, err = decodeFLAC(br, limit)
+	case bytes.HasPrefix(head, []byte("ID3")) || (len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0):
+		a, err = decodeMP3(br, limit)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Rate <= 0 || a.Channels <= 0 {
+		return nil, fmt.Errorf("invalid audio of %d Hz and %d channels", a.Rate, a.Channels)
+	}
+	return a, nil
+}
+
+// maxFrames returns the number of frames of limit at rate, or -1 if limit
+// is 0.
+func maxFrames(rate int, limit time.Duration) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	return int64(rate) * int64(limit) / int64(time.Second)
+}
+
+// Downmix averages the channels of the interleaved samples.
+func Downmix(samples []float32, channels int) []float32 {
+	if channels <= 1 {
+		return samples
+	}
+	ret := make([]float32, len(samples)/channels)
+	for i := range ret {
+		var sum float32
+		for _, s := range samples[i*channels : (i+1)*channels] {
+			sum += s
+		}
+		ret[i] = sum / float32(channels)
+	}
+	return ret
+}
@@ -0,0 +1,244 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/llama.go/internal/testutil"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+func TestDecodeWAV(t *testing.T) {
+	frames := [][]float64{{0.5, -0.5}, {0.25, 0.25}, {-1, 0}}
+	want := []float32{0, 0.25, -0.5}
+
+	cases := []struct {
+		name          string
+		format, width int
+		put           func([]byte, float64)
+	}{
+		{"u8", wavePCM, 1, func(b []byte, v float64) { b[0] = byte(v*128 + 128) }},
+		{"s16", wavePCM, 2, testutil.PutS16},
+		{"s24", wavePCM, 3, func(b []byte, v float64) {
+			u := uint32(int32(v * (1 << 23)))
+			b[0], b[1], b[2] = byte(u), byte(u>>8), byte(u>>16)
+		}},
+		{"s32", wavePCM, 4, func(b []byte, v float64) { binary.LittleEndian.PutUint32(b, uint32(int32(v*(1<<31)))) }},
+		{"f32", waveFloat, 4, func(b []byte, v float64) { binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v))) }},
+		{"f64", waveFloat, 8, func(b []byte, v float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(v)) }},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := Decode(bytes.NewReader(testutil.WAV(tt.format, 2, SampleRate, tt.width, frames, tt.put)), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(samples) != len(want) {
+				t.Fatalf("got %d samples, want %d", len(samples), len(want))
+			}
+			for i := range want {
+				if math.Abs(float64(samples[i]-want[i])) > 1e-2 {
+					t.Errorf("sample %d: got %v, want %v", i, samples[i], want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := Decode(bytes.NewReader(testutil.WAV(2, 1, SampleRate, 2, frames[:1], func([]byte, float64) {})), 0); err == nil {
+		t.Error("expected an error for ADPCM")
+	}
+}
+
+func TestDecodeFLAC(t *testing.T) {
+	const rate, n = 32000, 3200
+	left, right := make([]int32, n), make([]int32, n)
+	for i := range n {
+		left[i], right[i] = 8192, -8192+int32(i%2)*16384
+	}
+	var buf bytes.Buffer
+	enc, err := flac.NewEncoder(&buf, &meta.StreamInfo{
+		BlockSizeMin:  n,
+		BlockSizeMax:  n,
+		SampleRate:    rate,
+		NChannels:     2,
+		BitsPerSample: 16,
+		NSamples:      n,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         n,
+			SampleRate:        rate,
+			Channels:          frame.ChannelsLR,
+			BitsPerSample:     16,
+		},
+		Subframes: []*frame.Subframe{
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: n},
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: n},
+		},
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := Decode(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != n/2 {
+		t.Fatalf("got %d samples, want %d", len(samples), n/2)
+	}
+	// the right channel alternates around 0, which the resampling filters
+	// out, the left one is a constant 0.25
+	if v := samples[n/4]; math.Abs(float64(v)-0.125) > 1e-3 {
+		t.Errorf("got %v, want 0.125", v)
+	}
+}
+
+// silentMP3 returns n MPEG-1 Layer III frames of mono 128 kbps at 44.1 kHz,
+// whose side information left to zero codes no spectrum, so silence.
+func silentMP3(n int) []byte {
+	const size = 144 * 128000 / 44100
+	var ret []byte
+	for range n {
+		f := make([]byte, size)
+		copy(f, []byte{0xff, 0xfb, 0x90, 0xc0})
+		ret = append(ret, f...)
+	}
+	return ret
+}
+
+func TestDecodeMP3(t *testing.T) {
+	const frames = 20
+	// an ID3v2 tag of no frame before the audio
+	mp3 := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x00"), silentMP3(frames)...)
+	samples, err := Decode(bytes.NewReader(mp3), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := frames * 1152 * SampleRate / 44100
+	if len(samples) < want-2 || len(samples) > want+2 {
+		t.Errorf("got %d samples, want %d", len(samples), want)
+	}
+	for i, v := range samples {
+		if v != 0 {
+			t.Fatalf("sample %d: got %v, want silence", i, v)
+		}
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte{0xff, 0xfb, 0x90}), 0); err == nil {
+		t.Error("expected an error for a truncated frame")
+	}
+}
+
+func TestDecodeTooLong(t *testing.T) {
+	second := make([][]float64, SampleRate)
+	for i := range second {
+		second[i] = []float64{0}
+	}
+	long := testutil.WAV(wavePCM, 1, SampleRate, 2, second, testutil.PutS16)
+	if _, err := Decode(bytes.NewReader(long), time.Second); err != nil {
+		t.Errorf("got %v for audio of the limit", err)
+	}
+	if _, err := Decode(bytes.NewReader(long), time.Second/2); !errors.Is(err, ErrTooLong) {
+		t.Errorf("wav: got %v, want %v", err, ErrTooLong)
+	}
+	if _, err := Decode(bytes.NewReader(silentMP3(20)), 100*time.Millisecond); !errors.Is(err, ErrTooLong) {
+		t.Errorf("mp3: got %v, want %v", err, ErrTooLong)
+	}
+
+	// a fmt chunk claiming 2 GB is not allocated
+	huge := testutil.WAV(wavePCM, 1, SampleRate, 2, second[:1], testutil.PutS16)
+	binary.LittleEndian.PutUint32(huge[16:], 1<<31)
+	if _, err := Decode(bytes.NewReader(huge), 0); err == nil {
+		t.Error("expected an error for a truncated fmt chunk")
+	}
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("OggS\x00\x02")), 0); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestResample(t *testing.T) {
+	// 1 s of a 440 Hz sine and a 20 kHz tone above the Nyquist frequency
+	// of the output
+	const from = 48000
+	in := make([]float32, from)
+	for i := range in {
+		x := float64(i) / from
+		in[i] = float32(0.5*math.Sin(2*math.Pi*440*x) + 0.25*math.Sin(2*math.Pi*20000*x))
+	}
+	out := Resample(in, from, SampleRate)
+	if len(out) != SampleRate {
+		t.Fatalf("got %d samples, want %d", len(out), SampleRate)
+	}
+	// away from the edges, only the sine is left
+	var maxErr float64
+	for i := 1000; i < len(out)-1000; i++ {
+		want := 0.5 * math.Sin(2*math.Pi*440*float64(i)/SampleRate)
+		maxErr = max(maxErr, math.Abs(float64(out[i])-want))
+	}
+	if maxErr > 0.01 {
+		t.Errorf("got an error of %v", maxErr)
+	}
+
+	up := Resample([]float32{1, 1, 1, 1}, 8000, SampleRate)
+	if len(up) != 8 {
+		t.Fatalf("got %d samples, want 8", len(up))
+	}
+	for i, v := range up {
+		if math.Abs(float64(v)-1) > 1e-6 {
+			t.Errorf("sample %d: got %v, want 1", i, v)
+		}
+	}
+}
+
+func TestResampler(t *testing.T) {
+	// 2 s of a 440 Hz sine at 44.1 kHz, written in chunks of odd sizes
+	const from = 44100
+	in := make([]float32, 2*from)
+	for i := range in {
+		in[i] = float32(0.5 * math.Sin(2*math.Pi*440*float64(i)/from))
+	}
+	want := Resample(in, from, SampleRate)
+
+	r := NewResampler(from, SampleRate)
+	var got []float32
+	sizes := []int{1, 7, 333, 4099, 17, 2205}
+	for i, rest := 0, in; len(rest) > 0; i++ {
+		n := min(sizes[i%len(sizes)], len(rest))
+		got = append(got, r.Write(rest[:n])...)
+		rest = rest[n:]
+		// only the span of a filter is kept along with the chunk
+		if len(r.buf) > n+2*int(r.width)+2 {
+			t.Fatalf("kept %d samples after a chunk of %d", len(r.buf), n)
+		}
+	}
+	got = append(got, r.Flush()...)
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+			t.Fatalf("sample %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+	if len(r.buf) != 0 {
+		t.Errorf("got %d samples left after the flush", len(r.buf))
+	}
+}
@@ -0,0 +1,45 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mewkiz/flac"
+)
+
+func decodeFLAC(r io.Reader, limit time.Duration) (*Audio, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("flac: %w", err)
+	}
+	defer stream.Close()
+
+	a := &Audio{Rate: int(stream.Info.SampleRate), Channels: int(stream.Info.NChannels)}
+	frames := maxFrames(a.Rate, limit)
+	// the number of frames is 0 if unknown
+	if frames >= 0 && int64(stream.Info.NSamples) > frames {
+		return nil, ErrTooLong
+	}
+	for {
+		f, err := stream.ParseNext()
+		if errors.Is(err, io.EOF) {
+			return a, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("flac: %w", err)
+		}
+		if len(f.Subframes) != a.Channels {
+			return nil, fmt.Errorf("flac: frame of %d channels in a stream of %d", len(f.Subframes), a.Channels)
+		}
+		if frames >= 0 && int64(len(a.Samples)/a.Channels+int(f.BlockSize)) > frames {
+			return nil, ErrTooLong
+		}
+		scale := float32(int64(1) << (f.BitsPerSample - 1))
+		for i := range int(f.BlockSize) {
+			for _, sub := range f.Subframes {
+				a.Samples = append(a.Samples, float32(sub.Samples[i])/scale)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+func decodeMP3(r io.Reader, limit time.Duration) (*Audio, error) {
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("mp3: %w", err)
+	}
+	frames := maxFrames(d.SampleRate(), limit)
+	a := &Audio{Rate: d.SampleRate(), Channels: 2}
+	// the decoder outputs 16 bits stereo, converted a buffer at a time so
+	// that the decoded bytes are not held along with the samples
+	bts := make([]byte, 1<<16)
+	for {
+		n, err := io.ReadFull(d, bts)
+		// drops an incomplete frame at the end
+		for i := range n / 4 * 2 {
+			a.Samples = append(a.Samples, float32(int16(binary.LittleEndian.Uint16(bts[i*2:])))/(1<<15))
+		}
+		if frames >= 0 && int64(len(a.Samples)/2) > frames {
+			return nil, ErrTooLong
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return a, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("mp3: %w", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package audio
+
+import "math"
+
+// sincZeros is the number of zero crossings of the sinc on each side of a
+// sample, which trades the sharpness of the low-pass filter for speed.
+const sincZeros = 16
+
+// Resample resamples mono samples from rate from to rate to with a
+// Hann-windowed sinc, low-pass filtered below the lower Nyquist frequency.
+func Resample(samples []float32, from, to int) []float32 {
+	if from == to || len(samples) == 0 {
+		return samples
+	}
+	r := NewResampler(from, to)
+	return append(r.Write(samples), r.Flush()...)
+}
+
+// Resampler resamples a stream of mono samples as Resample, written in
+// chunks of any size. It keeps the input the filter of the next output
+// sample spans, so that the output is the same as resampling the whole
+// stream at once, without discontinuities between the chunks.
+type Resampler struct {
+	from, to int
+	ratio    float64
+	// the cutoff relative to the input Nyquist frequency
+	cutoff float64
+	// the half width of the filter in input samples
+	width float64
+
+	// buf holds the input from the sample offset of the stream
+	buf    []float32
+	offset int
+	// next is the index of the next output sample
+	next int
+}
+
+// NewResampler returns a Resampler from rate from to rate to.
+func NewResampler(from, to int) *Resampler {
+	ratio := float64(to) / float64(from)
+	cutoff := min(1, ratio)
+	return &Resampler{from: from, to: to, ratio: ratio, cutoff: cutoff, width: float64(sincZeros) / cutoff}
+}
+
+// Write adds samples to the stream and returns the output samples whose
+// filter they complete.
+func (r *Resampler) Write(samples []float32) []float32 {
+	if r.from == r.to {
+		return samples
+	}
+	r.buf = append(r.buf, samples...)
+	end := r.offset + len(r.buf)
+
+	var ret []float32
+	for {
+		t := float64(r.next) / r.ratio
+		if int(math.Floor(t+r.width)) >= end {
+			break
+		}
+		ret = append(ret, r.sample(t, end))
+		r.next++
+	}
+	// the input before the filter of the next output sample is done with
+	if drop := min(int(math.Ceil(float64(r.next)/r.ratio-r.width))-r.offset, len(r.buf)); drop > 0 {
+		r.buf = r.buf[drop:]
+		r.offset += drop
+	}
+	return ret
+}
+
+// Flush returns the last output samples, whose filter the end of the stream
+// cuts short.
+func (r *Resampler) Flush() []float32 {
+	if r.from == r.to {
+		return nil
+	}
+	end := r.offset + len(r.buf)
+	n := int(math.Ceil(float64(end) * r.ratio))
+	var ret []float32
+	for ; r.next < n; r.next++ {
+		ret = append(ret, r.sample(float64(r.next)/r.ratio, end))
+	}
+	r.buf = nil
+	r.offset = end
+	return ret
+}
+
+// sample returns the output sample at time t in input samples, the stream
+// ending at the sample end.
+func (r *Resampler) sample(t float64, end int) float32 {
+	first := max(0, int(math.Ceil(t-r.width)))
+	last := min(end-1, int(math.Floor(t+r.width)))
+	var sum, norm float64
+	for j := first; j <= last; j++ {
+		x := float64(j) - t
+		w := r.cutoff * sinc(r.cutoff*x) * 0.5 * (1 + math.Cos(math.Pi*x/r.width))
+		sum += w * float64(r.buf[j-r.offset])
+		norm += w
+	}
+	// normalized to a unit gain, also at the edges where the filter is cut
+	// short
+	if norm != 0 {
+		sum /= norm
+	}
+	return float32(sum)
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
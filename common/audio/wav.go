@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// the WAV formats of the fmt chunk
+const (
+	wavePCM        = 1
+	waveFloat      = 3
+	waveExtensible = 0xfffe
+)
+
+// wavFmtSize is the size of the fmt chunk of WAVE_FORMAT_EXTENSIBLE, the
+// largest the decoder reads.
+const wavFmtSize = 40
+
+// decodeWAV decodes integer PCM of 8 to 32 bits and float PCM of 32 or 64
+// bits, at any sample rate.
+func decodeWAV(r io.Reader, limit time.Duration) (*Audio, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("wav: %w", err)
+	}
+
+	var format, channels, rate, width int
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); errors.Is(err, io.EOF) {
+			return nil, errors.New("wav: no data chunk")
+		} else if err != nil {
+			return nil, fmt.Errorf("wav: %w", err)
+		}
+		size := binary.LittleEndian.Uint32(hdr[4:])
+
+		switch string(hdr[:4]) {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("wav: invalid fmt chunk")
+			}
+			bts := make([]byte, min(size, wavFmtSize))
+			if _, err := io.ReadFull(r, bts); err != nil {
+				return nil, fmt.Errorf("wav: %w", err)
+			}
+			// skips the extension of the format and the padding
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)-int64(len(bts))); err != nil {
+				return nil, fmt.Errorf("wav: %w", err)
+			}
+			format = int(binary.LittleEndian.Uint16(bts))
+			channels = int(binary.LittleEndian.Uint16(bts[2:]))
+			rate = int(binary.LittleEndian.Uint32(bts[4:]))
+			if channels > 0 {
+				// the size of the container of a sample, which holds
+				// the bits per sample
+				width = int(binary.LittleEndian.Uint16(bts[12:])) / channels
+			}
+			if format == waveExtensible && size >= 26 {
+				// the format is the start of the GUID of the sub format
+				format = int(binary.LittleEndian.Uint16(bts[24:]))
+			}
+		case "data":
+			if channels <= 0 {
+				return nil, errors.New("wav: no fmt chunk before the data chunk")
+			}
+			if width <= 0 {
+				return nil, errors.New("wav: invalid block alignment")
+			}
+			var data io.Reader = r
+			// streams of unknown length leave the size to 0 or the maximum
+			if size != 0 && size != math.MaxUint32 {
+				data = io.LimitReader(r, int64(size))
+			}
+			var maxSize int64 = -1
+			if frames := maxFrames(rate, limit); frames >= 0 {
+				maxSize = frames * int64(channels*width)
+				data = io.LimitReader(data, maxSize+1)
+			}
+			bts, err := io.ReadAll(data)
+			if err != nil {
+				return nil, fmt.Errorf("wav: %w", err)
+			}
+			if maxSize >= 0 && int64(len(bts)) > maxSize {
+				return nil, ErrTooLong
+			}
+			samples, err := wavSamples(bts, format, width)
+			if err != nil {
+				return nil, err
+			}
+			// drops an incomplete frame at the end
+			samples = samples[:len(samples)/channels*channels]
+			return &Audio{Rate: rate, Channels: channels, Samples: samples}, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)); err != nil {
+				return nil, fmt.Errorf("wav: %w", err)
+			}
+		}
+	}
+}
+
+// wavSamples converts the little-endian samples of width bytes to floats.
+func wavSamples(bts []byte, format, width int) ([]float32, error) {
+	if width <= 0 {
+		return nil, errors.New("wav: invalid block alignment")
+	}
+	ret := make([]float32, len(bts)/width)
+	switch {
+	case format == wavePCM && width == 1:
+		// 8 bits are unsigned
+		for i := range ret {
+			ret[i] = (float32(bts[i]) - 128) / 128
+		}
+	case format == wavePCM && width <= 4:
+		// left-justifies the sample in an int32 to sign-extend it
+		shift := 32 - 8*width
+		for i := range ret {
+			var v uint32
+			for b := range width {
+				v |= uint32(bts[i*width+b]) << (8 * b)
+			}
+			ret[i] = float32(int32(v<<shift)) / (1 << 31)
+		}
+	case format == waveFloat && width == 4:
+		for i := range ret {
+			ret[i] = math.Float32frombits(binary.LittleEndian.Uint32(bts[i*4:]))
+		}
+	case format == waveFloat && width == 8:
+		for i := range ret {
+			ret[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(bts[i*8:])))
+		}
+	default:
+		return nil, fmt.Errorf("wav: unsupported format %d of %d bits", format, 8*width)
+	}
+	return ret, nil
+}
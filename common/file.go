@@ -1,6 +1,8 @@
 package common
 
 import (
+	"bufio"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -50,3 +52,29 @@ func SaveOutputToFile(outFilePath string, content string) error {
 	_, err = outFile.WriteString(content)
 	return err
 }
+
+// WriteOutput writes the output of write, buffered, to the file at path,
+// created or truncated, or to stdout when path is empty.
+func WriteOutput(path string, write func(io.Writer) error) error {
+	if len(path) <= 0 {
+		w := bufio.NewWriter(os.Stdout)
+		if err := write(w); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,170 @@
+package transcript
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/common/audio"
+)
+
+const (
+	// frameSize is the size of the frames the speech is detected on, 30 ms.
+	frameSize = audio.SampleRate * 30 / 1000
+	// prerollSize is the silence kept before the speech, 200 ms, so that its
+	// start is not cut.
+	prerollSize = audio.SampleRate * 200 / 1000
+)
+
+// StreamOptions tunes the segmentation of a Stream.
+type StreamOptions struct {
+	// Threshold is the RMS from which a frame holds speech.
+	Threshold float64
+	// Silence is the pause ending a segment.
+	Silence time.Duration
+	// Step is the audio between two partial transcriptions of a segment.
+	Step time.Duration
+	// MaxSegment is the length at which a segment ends without pause.
+	MaxSegment time.Duration
+}
+
+// DefaultStreamOptions returns the options of live captions.
+func DefaultStreamOptions() StreamOptions {
+	return StreamOptions{
+		Threshold:  0.01,
+		Silence:    600 * time.Millisecond,
+		Step:       time.Second,
+		MaxSegment: 15 * time.Second,
+	}
+}
+
+// Stream segments a stream of samples at audio.SampleRate on the pauses of
+// the speech, detected by the energy of its frames. It transcribes the
+// segment being spoken each Step as partial, and once it ended as final.
+type Stream struct {
+	opts       StreamOptions
+	transcribe func([]float32) (string, error)
+	fn         func(api.TranscriptionStreamResponse) error
+
+	// pending are the samples short of a frame
+	pending []float32
+	preroll []float32
+	// segment is nil out of the speech
+	segment []float32
+	id      int
+	// start is the offset of the segment and offset the one of pending
+	start, offset int
+	// silent are the samples of silence ending the segment, and partial
+	// those of the segment at its last partial transcription
+	silent, partial int
+}
+
+// NewStream returns a Stream transcribing its segments with transcribe and
+// passing the transcriptions to fn.
+func NewStream(opts StreamOptions, transcribe func([]float32) (string, error), fn func(api.TranscriptionStreamResponse) error) *Stream {
+	return &Stream{opts: opts, transcribe: transcribe, fn: fn}
+}
+
+// Write adds samples to the stream, which can end or transcribe a segment.
+func (s *Stream) Write(samples []float32) error {
+	s.pending = append(s.pending, samples...)
+	n := 0
+	for ; n+frameSize <= len(s.pending); n += frameSize {
+		if err := s.frame(s.pending[n : n+frameSize]); err != nil {
+			return err
+		}
+	}
+	s.pending = slices.Clone(s.pending[n:])
+	return nil
+}
+
+// Flush ends the segment being spoken, which the end of the stream cuts.
+func (s *Stream) Flush() error {
+	if len(s.pending) > 0 {
+		if err := s.frame(s.pending); err != nil {
+			return err
+		}
+		s.pending = nil
+	}
+	if s.segment == nil {
+		return nil
+	}
+	return s.emit("final")
+}
+
+func (s *Stream) frame(f []float32) error {
+	speech := rms(f) >= s.opts.Threshold
+	pos := s.offset
+	s.offset += len(f)
+
+	if s.segment == nil {
+		if !speech {
+			s.preroll = append(s.preroll, f...)
+			if len(s.preroll) > prerollSize {
+				s.preroll = slices.Clone(s.preroll[len(s.preroll)-prerollSize:])
+			}
+			return nil
+		}
+		s.start = pos - len(s.preroll)
+		s.segment = s.preroll
+		s.preroll = nil
+		s.silent, s.partial = 0, 0
+	}
+	s.segment = append(s.segment, f...)
+	if speech {
+		s.silent = 0
+	} else {
+		s.silent += len(f)
+	}
+
+	switch {
+	case s.silent >= samples(s.opts.Silence), len(s.segment) >= samples(s.opts.MaxSegment):
+		return s.emit("final")
+	case len(s.segment)-s.partial >= samples(s.opts.Step):
+		s.partial = len(s.segment)
+		return s.emit("partial")
+	}
+	return nil
+}
+
+// emit transcribes the segment, which a final ends.
+func (s *Stream) emit(typ string) error {
+	resp := api.TranscriptionStreamResponse{
+		Type:  typ,
+		ID:    s.id,
+		Start: float64(s.start) / audio.SampleRate,
+		End:   float64(s.start+len(s.segment)-s.silent) / audio.SampleRate,
+	}
+	segment := s.segment
+	if typ == "final" {
+		s.segment = nil
+		s.id++
+	}
+	text, err := s.transcribe(segment)
+	if err != nil {
+		return err
+	}
+	resp.Text = strings.TrimSpace(text)
+	// a final is sent even without text, to end the partials of its segment
+	if len(resp.Text) <= 0 && typ == "partial" {
+		return nil
+	}
+	return s.fn(resp)
+}
+
+func samples(d time.Duration) int {
+	return int(d.Seconds() * audio.SampleRate)
+}
+
+func rms(f []float32) float64 {
+	if len(f) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range f {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(f)))
+}
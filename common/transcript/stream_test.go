@@ -0,0 +1,103 @@
+package transcript
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/common/audio"
+	"github.com/Qitmeer/llama.go/internal/testutil"
+)
+
+// speechWAV returns a 16 kHz WAV of 16 bits alternating silence and tones
+// standing for speech, of the given seconds.
+func speechWAV(parts ...float64) []byte {
+	var frames [][]float64
+	for i, seconds := range parts {
+		for j := range int(seconds * audio.SampleRate) {
+			var v float64
+			if i%2 == 1 {
+				v = 0.3 * math.Sin(2*math.Pi*300*float64(j)/audio.SampleRate)
+			}
+			frames = append(frames, []float64{v})
+		}
+	}
+	return testutil.WAV(1, 1, audio.SampleRate, 2, frames, testutil.PutS16)
+}
+
+func TestStream(t *testing.T) {
+	samples, err := audio.Decode(bytes.NewReader(speechWAV(0.5, 2.5, 1, 0.8, 0.3)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []api.TranscriptionStreamResponse
+	s := NewStream(DefaultStreamOptions(), func(segment []float32) (string, error) {
+		return fmt.Sprintf(" %.2fs", float64(len(segment))/audio.SampleRate), nil
+	}, func(resp api.TranscriptionStreamResponse) error {
+		got = append(got, resp)
+		return nil
+	})
+	// fed by chunks of 200 ms, as a live client
+	for start := 0; start < len(samples); start += 3200 {
+		if err := s.Write(samples[start:min(start+3200, len(samples))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var finals []api.TranscriptionStreamResponse
+	partials := map[int]int{}
+	for _, resp := range got {
+		switch resp.Type {
+		case "partial":
+			if len(finals) > resp.ID {
+				t.Errorf("partial of segment %d after its final", resp.ID)
+			}
+			partials[resp.ID]++
+		case "final":
+			finals = append(finals, resp)
+		}
+	}
+	if len(finals) != 2 {
+		t.Fatalf("got %d finals, want 2: %v", len(finals), got)
+	}
+	want := [][2]float64{{0.3, 3}, {3.8, 4.8}}
+	for i, f := range finals {
+		if f.ID != i || math.Abs(f.Start-want[i][0]) > 0.05 || math.Abs(f.End-want[i][1]) > 0.05 {
+			t.Errorf("final %d: got %d from %.2f to %.2f, want from %.2f to %.2f", i, f.ID, f.Start, f.End, want[i][0], want[i][1])
+		}
+		if f.Text == "" || f.Text[0] == ' ' {
+			t.Errorf("final %d: got text %q", i, f.Text)
+		}
+	}
+	if partials[0] < 2 {
+		t.Errorf("got %d partials of the first segment, want at least 2", partials[0])
+	}
+}
+
+func TestStreamMaxSegment(t *testing.T) {
+	opts := DefaultStreamOptions()
+	var finals int
+	s := NewStream(opts, func([]float32) (string, error) { return "speech", nil }, func(resp api.TranscriptionStreamResponse) error {
+		if resp.Type == "final" {
+			finals++
+		}
+		return nil
+	})
+	// a tone without pause of twice the maximum length
+	tone := make([]float32, 2*samples(opts.MaxSegment))
+	for i := range tone {
+		tone[i] = float32(0.3 * math.Sin(2*math.Pi*300*float64(i)/audio.SampleRate))
+	}
+	if err := s.Write(tone); err != nil {
+		t.Fatal(err)
+	}
+	if finals != 2 {
+		t.Errorf("got %d finals, want 2", finals)
+	}
+}
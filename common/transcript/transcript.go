@@ -0,0 +1,56 @@
+// Package transcript writes the segments of a transcription as subtitles,
+// and segments streamed audio for live transcription.
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/Qitmeer/llama.go/api"
+)
+
+// timestamp formats seconds as hh:mm:ss followed by sep and the
+// milliseconds, a comma for SRT and a dot for WebVTT.
+func timestamp(seconds float64, sep string) string {
+	ms := int64(math.Round(max(seconds, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// WriteSRT writes the segments as SubRip subtitles.
+func WriteSRT(w io.Writer, segments []api.TranscriptionSegment) error {
+	for i, s := range segments {
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(s.Start, ","), timestamp(s.End, ","), strings.TrimSpace(s.Text))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVTT writes the segments as WebVTT subtitles.
+func WriteVTT(w io.Writer, segments []api.TranscriptionSegment) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, s := range segments {
+		_, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", timestamp(s.Start, "."), timestamp(s.End, "."), strings.TrimSpace(s.Text))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrorMessage returns the message of an error response of the whisper core.
+func ErrorMessage(jsonStr string) string {
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil || len(resp.Error) <= 0 {
+		return "whisper: " + jsonStr
+	}
+	return resp.Error
+}
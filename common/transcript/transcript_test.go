@@ -0,0 +1,47 @@
+package transcript
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/api"
+)
+
+var segments = []api.TranscriptionSegment{
+	{ID: 0, Start: 0, End: 2.5, Text: " Hello there."},
+	{ID: 1, Start: 2.5, End: 3723.004, Text: " General Kenobi."},
+}
+
+func TestWriteSRT(t *testing.T) {
+	var sb strings.Builder
+	if err := WriteSRT(&sb, segments); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:00,000 --> 00:00:02,500\nHello there.\n\n" +
+		"2\n00:00:02,500 --> 01:02:03,004\nGeneral Kenobi.\n\n"
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	var sb strings.Builder
+	if err := WriteVTT(&sb, segments); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n" +
+		"00:00:00.000 --> 00:00:02.500\nHello there.\n\n" +
+		"00:00:02.500 --> 01:02:03.004\nGeneral Kenobi.\n\n"
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	if got := ErrorMessage(`{"error":"no audio"}`); got != "no audio" {
+		t.Errorf("got %q", got)
+	}
+	if got := ErrorMessage("oops"); got != "whisper: oops" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2017-2025 The qitmeer developers
+
+package vector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteNPY writes the vectors to w as a NumPy .npy float32 matrix with a row
+// per vector.
+func WriteNPY(w io.Writer, vectors [][]float32) error {
+	dim := 0
+	if len(vectors) > 0 {
+		dim = len(vectors[0])
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", len(vectors), dim)
+	// the magic, version and header length take 10 bytes and the header
+	// ends with a newline, padded so that the data is 64-byte aligned
+	header += strings.Repeat(" ", 63-(10+len(header))%64) + "\n"
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x93NUMPY\x01\x00")
+	if err := binary.Write(bw, binary.LittleEndian, uint16(len(header))); err != nil {
+		return err
+	}
+	bw.WriteString(header)
+	for i, v := range vectors {
+		if len(v) != dim {
+			return fmt.Errorf("vector %d has %d dimensions, expected %d", i, len(v), dim)
+		}
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2017-2025 The qitmeer developers
+
+// Package vector is a flat-file store of embeddings searched by brute force,
+// which suits the corpora embedded on a single machine.
+//
+// A store file starts with the magic "LGVEC", a version byte and the number
+// of dimensions as a little-endian uint32. Each record follows as the length
+// prefixed id and text, then the vector as little-endian float32 values.
+package vector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	magic   = "LGVEC"
+	version = 1
+
+	// maxField bounds the length of the id and text of a record.
+	maxField = 1 << 24
+	// maxDim bounds the number of dimensions of a store.
+	maxDim = 1 << 16
+)
+
+// Metric scores the similarity of two vectors.
+type Metric string
+
+const (
+	Cosine = Metric("cosine")
+	Dot    = Metric("dot")
+	// L2 scores by the negated euclidean distance, so that a higher score
+	// is a closer match as for the other metrics.
+	L2 = Metric("l2")
+)
+
+// ParseMetric returns the metric named s.
+func ParseMetric(s string) (Metric, error) {
+	switch m := Metric(s); m {
+	case Cosine, Dot, L2:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown metric %q, expected cosine, dot or l2", s)
+}
+
+// Score returns the similarity of a and b, which have the same length.
+func (m Metric) Score(a, b []float32) float64 {
+	var dot, na, nb, dist float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		na += x * x
+		nb += y * y
+		dist += (x - y) * (x - y)
+	}
+	switch m {
+	case Dot:
+		return dot
+	case L2:
+		return -math.Sqrt(dist)
+	default:
+		if na == 0 || nb == 0 {
+			return 0
+		}
+		return dot / math.Sqrt(na*nb)
+	}
+}
+
+// Record is a document of the store along with its embedding.
+type Record struct {
+	ID     string    `json:"id"`
+	Text   string    `json:"text"`
+	Vector []float32 `json:"-"`
+}
+
+// Writer writes records to a store file. The records go to a temporary
+// file next to it, which replaces the store file once closed, so that a
+// store is never left with only part of its records.
+type Writer struct {
+	f    *os.File
+	w    *bufio.Writer
+	path string
+	dim  int
+}
+
+// Create creates the store file at path for vectors of dim dimensions,
+// replacing it on Close if it exists.
+func Create(path string, dim int) (*Writer, error) {
+	if dim <= 0 || dim > maxDim {
+		return nil, fmt.Errorf("invalid number of dimensions %d", dim)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.partial")
+	if err != nil {
+		return nil, err
+	}
+	// as os.Create would, instead of the 0600 of os.CreateTemp
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	w := &Writer{f: f, w: bufio.NewWriter(f), path: path, dim: dim}
+	w.w.WriteString(magic)
+	w.w.WriteByte(version)
+	if err := binary.Write(w.w, binary.LittleEndian, uint32(dim)); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends r to the store.
+func (w *Writer) Write(r Record) error {
+	if len(r.Vector) != w.dim {
+		return fmt.Errorf("record %q has %d dimensions, expected %d", r.ID, len(r.Vector), w.dim)
+	}
+	for _, s := range []string{r.ID, r.Text} {
+		if len(s) > maxField {
+			return fmt.Errorf("record %q is too long", r.ID)
+		}
+		if err := binary.Write(w.w, binary.LittleEndian, uint32(len(s))); err != nil {
+			return err
+		}
+		if _, err := w.w.WriteString(s); err != nil {
+			return err
+		}
+	}
+	return binary.Write(w.w, binary.LittleEndian, r.Vector)
+}
+
+// Close flushes the records and moves them to the store file.
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	if err := os.Rename(w.f.Name(), w.path); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the records written, leaving the store file as it was.
+func (w *Writer) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// Store holds the records of a store file in memory.
+type Store struct {
+	Dim     int
+	Records []Record
+}
+
+// Load reads the store file at path.
+func Load(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	head := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r, head); err != nil || !bytes.Equal(head[:len(magic)], []byte(magic)) {
+		return nil, fmt.Errorf("%s is not a vector store", path)
+	}
+	if head[len(magic)] != version {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, head[len(magic)])
+	}
+	var dim uint32
+	if err := binary.Read(r, binary.LittleEndian, &dim); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if dim == 0 || dim > maxDim {
+		return nil, fmt.Errorf("%s: invalid number of dimensions %d", path, dim)
+	}
+
+	s := &Store{Dim: int(dim)}
+	for {
+		rec, err := readRecord(r, s.Dim)
+		if errors.Is(err, io.EOF) {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w", path, len(s.Records), err)
+		}
+		s.Records = append(s.Records, rec)
+	}
+}
+
+func readRecord(r io.Reader, dim int) (Record, error) {
+	var fields [2]string
+	for i := range fields {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			if i > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return Record{}, err
+		}
+		if n > maxField {
+			return Record{}, errors.New("corrupt record")
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return Record{}, unexpected(err)
+		}
+		fields[i] = string(b)
+	}
+	v := make([]float32, dim)
+	if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+		return Record{}, unexpected(err)
+	}
+	return Record{ID: fields[0], Text: fields[1], Vector: v}, nil
+}
+
+// unexpected reports the end of the file within a record as an error.
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Match is a record found by [Store.Search] with its score.
+type Match struct {
+	Record
+	Score float64 `json:"score"`
+}
+
+// Search returns the k records most similar to q by the metric m, the best
+// first.
+func (s *Store) Search(q []float32, k int, m Metric) ([]Match, error) {
+	if len(q) != s.Dim {
+		return nil, fmt.Errorf("query has %d dimensions, expected %d", len(q), s.Dim)
+	}
+	matches := make([]Match, 0, len(s.Records))
+	for _, r := range s.Records {
+		matches = append(matches, Match{Record: r, Score: m.Score(q, r.Vector)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if k > 0 && k < len(matches) {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
@@ -0,0 +1,192 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.vec")
+	w, err := Create(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []Record{
+		{ID: "east", Text: "to the east", Vector: []float32{1, 0}},
+		{ID: "north", Text: "to the north", Vector: []float32{0, 1}},
+		{ID: "north-east", Text: "", Vector: []float32{0.6, 0.8}},
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(Record{ID: "bad", Vector: []float32{1}}); err == nil {
+		t.Error("expected an error for a vector of the wrong size")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Dim != 2 || len(s.Records) != len(records) {
+		t.Fatalf("got %d records of %d dimensions", len(s.Records), s.Dim)
+	}
+	if s.Records[0].Text != "to the east" {
+		t.Errorf("got text %q", s.Records[0].Text)
+	}
+
+	cases := []struct {
+		metric Metric
+		want   []string
+	}{
+		{Cosine, []string{"north", "north-east"}},
+		{Dot, []string{"north", "north-east"}},
+		{L2, []string{"north", "north-east"}},
+	}
+	for _, tt := range cases {
+		t.Run(string(tt.metric), func(t *testing.T) {
+			matches, err := s.Search([]float32{0, 1}, 2, tt.metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) != len(tt.want) {
+				t.Fatalf("got %d matches, want %d", len(matches), len(tt.want))
+			}
+			for i, id := range tt.want {
+				if matches[i].ID != id {
+					t.Errorf("match %d: got %q, want %q", i, matches[i].ID, id)
+				}
+			}
+		})
+	}
+
+	if _, err := s.Search([]float32{1}, 1, Cosine); err == nil {
+		t.Error("expected an error for a query of the wrong size")
+	}
+}
+
+func TestScore(t *testing.T) {
+	a, b := []float32{3, 4}, []float32{4, 3}
+	if got := Cosine.Score(a, b); math.Abs(got-24.0/25) > 1e-9 {
+		t.Errorf("cosine: got %v", got)
+	}
+	if got := Dot.Score(a, b); got != 24 {
+		t.Errorf("dot: got %v", got)
+	}
+	if got := L2.Score(a, b); math.Abs(got+math.Sqrt2) > 1e-9 {
+		t.Errorf("l2: got %v", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	notStore := filepath.Join(dir, "corpus.jsonl")
+	if err := os.WriteFile(notStore, []byte(`{"text":"hi"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(notStore); err == nil {
+		t.Error("expected an error for a file which is not a store")
+	}
+
+	truncated := filepath.Join(dir, "truncated.vec")
+	w, err := Create(truncated, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Record{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bts, err := os.ReadFile(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(truncated, bts[:len(bts)-2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(truncated); err == nil {
+		t.Error("expected an error for a truncated store")
+	}
+
+	huge := filepath.Join(dir, "huge.vec")
+	head := binary.LittleEndian.AppendUint32([]byte(magic+"\x01"), 1<<30)
+	if err := os.WriteFile(huge, head, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(huge); err == nil {
+		t.Error("expected an error for a store of too many dimensions")
+	}
+}
+
+func TestWriterAbort(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "corpus.vec")
+	if err := os.WriteFile(path, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := Create(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Record{ID: "a", Vector: []float32{1}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Abort()
+
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bts) != "previous" {
+		t.Errorf("got %q, expected the store file to be left as it was", bts)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, expected the partial store to be removed", len(entries))
+	}
+}
+
+func TestWriteNPY(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteNPY(&buf, [][]float32{{1, 2, 3}, {4, 5, 6}}); err != nil {
+		t.Fatal(err)
+	}
+	bts := buf.Bytes()
+	if !bytes.HasPrefix(bts, []byte("\x93NUMPY\x01\x00")) {
+		t.Fatalf("missing magic: %q", bts[:8])
+	}
+	n := int(binary.LittleEndian.Uint16(bts[8:10]))
+	if (10+n)%64 != 0 {
+		t.Errorf("data at offset %d is not 64-byte aligned", 10+n)
+	}
+	header := string(bts[10 : 10+n])
+	if !strings.Contains(header, "'shape': (2, 3)") || !strings.HasSuffix(header, "\n") {
+		t.Errorf("unexpected header %q", header)
+	}
+	data := bts[10+n:]
+	if len(data) != 6*4 {
+		t.Fatalf("got %d bytes of data", len(data))
+	}
+	if v := math.Float32frombits(binary.LittleEndian.Uint32(data[20:])); v != 6 {
+		t.Errorf("got last value %v", v)
+	}
+
+	if err := WriteNPY(&buf, [][]float32{{1}, {1, 2}}); err == nil {
+		t.Error("expected an error for vectors of different sizes")
+	}
+}
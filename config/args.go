@@ -0,0 +1,125 @@
+// Copyright (c) 2017-2025 The qitmeer developers
+
+package config
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/Qitmeer/llama.go/model"
+)
+
+// Args returns the command line the core is started with, starting with the
+// program name. Every value is an argument of its own, so that paths and JSON
+// values containing spaces or quotes reach the core unchanged.
+func (c *Config) Args() []string {
+	args := []string{"llama"}
+	if len(c.ModelPath()) > 0 {
+		args = append(args, "--model", c.ModelPath())
+	}
+	if c.CtxSize != DefaultContextSize {
+		args = append(args, "--ctx-size", strconv.Itoa(c.CtxSize))
+	}
+	if c.NGpuLayers != DefaultNGpuLayers {
+		args = append(args, "--n-gpu-layers", strconv.Itoa(c.NGpuLayers))
+	}
+	if c.Seed != math.MaxUint32 {
+		args = append(args, "--seed", strconv.FormatUint(uint64(c.Seed), 10))
+	}
+	if c.BatchSize != 2048 {
+		args = append(args, "--batch-size", strconv.Itoa(c.BatchSize))
+	}
+	if c.UBatchSize != 512 {
+		args = append(args, "--ubatch-size", strconv.Itoa(c.UBatchSize))
+	}
+	if c.Jinja {
+		args = append(args, "--jinja")
+	}
+	if len(c.ChatTemplate) > 0 {
+		args = append(args, "--chat-template", c.ChatTemplate)
+	}
+	if len(c.ChatTemplateFile) > 0 {
+		args = append(args, "--chat-template-file", c.ChatTemplateFile)
+	}
+	if len(c.ChatTemplateKwargs) > 0 {
+		args = append(args, "--chat-template-kwargs", c.ChatTemplateKwargs)
+	}
+	// a reranking model pools into a rank whatever --pooling says
+	rerank := model.SupportsRerank(c.ModelPath())
+	if len(c.Pooling) > 0 && !rerank {
+		args = append(args, "--pooling", c.Pooling)
+	}
+	// the embedding mode, which --reranking implies, caps the logical batch at
+	// the physical one, so it is only enabled for the models pooling their
+	// outputs or when asked for
+	switch {
+	case rerank:
+		args = append(args, "--reranking")
+	case c.Embeddings || model.SupportsEmbedding(c.ModelPath()):
+		args = append(args, "--embeddings")
+	}
+	if c.Threads > 0 {
+		args = append(args, "--threads", strconv.Itoa(c.Threads))
+	}
+	if c.Parallel > 0 {
+		args = append(args, "--parallel", strconv.Itoa(c.Parallel))
+	}
+	if len(c.FlashAttention) > 0 && c.FlashAttention != DefaultFlashAttention {
+		args = append(args, "--flash-attn", c.FlashAttention)
+	}
+	if len(c.CacheTypeK) > 0 && c.CacheTypeK != DefaultCacheType {
+		args = append(args, "--cache-type-k", c.CacheTypeK)
+	}
+	if len(c.CacheTypeV) > 0 && c.CacheTypeV != DefaultCacheType {
+		args = append(args, "--cache-type-v", c.CacheTypeV)
+	}
+	if c.Mlock {
+		args = append(args, "--mlock")
+	}
+	if c.NoMmap {
+		args = append(args, "--no-mmap")
+	}
+	if !c.ContBatching {
+		args = append(args, "--no-cont-batching")
+	}
+	if len(c.RopeScaling) > 0 {
+		args = append(args, "--rope-scaling", c.RopeScaling)
+	}
+	if c.RopeScale > 0 {
+		args = append(args, "--rope-scale", strconv.FormatFloat(c.RopeScale, 'g', -1, 64))
+	}
+	if c.YarnOrigCtx > 0 {
+		args = append(args, "--yarn-orig-ctx", strconv.Itoa(c.YarnOrigCtx))
+	}
+	if c.YarnExtFactor != DefaultYarn {
+		args = append(args, "--yarn-ext-factor", strconv.FormatFloat(c.YarnExtFactor, 'g', -1, 64))
+	}
+	if c.YarnAttnFactor != DefaultYarn {
+		args = append(args, "--yarn-attn-factor", strconv.FormatFloat(c.YarnAttnFactor, 'g', -1, 64))
+	}
+	if c.YarnBetaSlow != DefaultYarn {
+		args = append(args, "--yarn-beta-slow", strconv.FormatFloat(c.YarnBetaSlow, 'g', -1, 64))
+	}
+	if c.YarnBetaFast != DefaultYarn {
+		args = append(args, "--yarn-beta-fast", strconv.FormatFloat(c.YarnBetaFast, 'g', -1, 64))
+	}
+	if len(c.DraftModelPath()) > 0 {
+		args = append(args, "--model-draft", c.DraftModelPath())
+		if c.DraftMax != DefaultDraftMax {
+			args = append(args, "--draft-max", strconv.Itoa(c.DraftMax))
+		}
+		if c.DraftMin != DefaultDraftMin {
+			args = append(args, "--draft-min", strconv.Itoa(c.DraftMin))
+		}
+	}
+	// Config.Load has validated the adapters
+	adapters, _ := c.LoraAdapters()
+	for _, adapter := range adapters {
+		if adapter.Scale == 1 {
+			args = append(args, "--lora", adapter.Path)
+		} else {
+			args = append(args, "--lora-scaled", adapter.Path+":"+strconv.FormatFloat(float64(adapter.Scale), 'g', -1, 32))
+		}
+	}
+	return args
+}
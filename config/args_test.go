@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/internal/testutil"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+// argValue returns the argument following the flag name in args.
+func argValue(t *testing.T, args []string, name string) string {
+	t.Helper()
+	i := slices.Index(args, name)
+	if i < 0 || i+1 >= len(args) {
+		t.Fatalf("%s not found in %q", name, args)
+	}
+	return args[i+1]
+}
+
+func TestArgs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my models")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, `qwen "chat" 0.5b.gguf`)
+	kwargs := `{"enable_thinking": false, "reasoning_effort": "very high"}`
+	template := `{% for m in messages %}{{ m['role'] }}: {{ m["content"] }}{% endfor %}`
+
+	c := &Config{
+		Model:              model,
+		ModelDir:           dir,
+		CtxSize:            8192,
+		NGpuLayers:         DefaultNGpuLayers,
+		Seed:               42,
+		ChatTemplate:       template,
+		ChatTemplateFile:   filepath.Join(dir, "chat template.jinja"),
+		ChatTemplateKwargs: kwargs,
+		Lora:               []string{"style adapter.gguf:0.5"},
+		YarnExtFactor:      DefaultYarn,
+		YarnAttnFactor:     DefaultYarn,
+		YarnBetaSlow:       DefaultYarn,
+		YarnBetaFast:       DefaultYarn,
+		ContBatching:       true,
+		BatchSize:          2048,
+		UBatchSize:         512,
+		DraftMax:           DefaultDraftMax,
+	}
+	args := c.Args()
+
+	if args[0] != "llama" {
+		t.Errorf("args[0] = %q, want the program name", args[0])
+	}
+	for name, want := range map[string]string{
+		"--model":                model,
+		"--ctx-size":             "8192",
+		"--seed":                 "42",
+		"--chat-template":        template,
+		"--chat-template-file":   filepath.Join(dir, "chat template.jinja"),
+		"--chat-template-kwargs": kwargs,
+		"--lora-scaled":          filepath.Join(dir, "style adapter.gguf") + ":0.5",
+	} {
+		if got := argValue(t, args, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"--n-gpu-layers", "--batch-size", "--no-cont-batching", "--yarn-ext-factor", "--model-draft"} {
+		if slices.Contains(args, name) {
+			t.Errorf("unexpected %s for a default value in %q", name, args)
+		}
+	}
+}
+
+func TestArgsEmbeddings(t *testing.T) {
+	chat := testutil.WriteModel(t, ggml.KV{"general.architecture": "test"})
+	embedding := testutil.WriteModel(t, ggml.KV{"general.architecture": "test", "test.pooling_type": uint32(1)})
+	reranker := testutil.WriteModel(t, ggml.KV{"general.architecture": "test", "test.pooling_type": uint32(4)})
+
+	cases := []struct {
+		name       string
+		model      string
+		embeddings bool
+		want       []string
+	}{
+		{"chat model", chat, false, []string{"--pooling"}},
+		{"chat model with --embeddings", chat, true, []string{"--pooling", "--embeddings"}},
+		{"pooling model", embedding, false, []string{"--pooling", "--embeddings"}},
+		{"reranking model", reranker, false, []string{"--reranking"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Model: tt.model, Pooling: "mean", Embeddings: tt.embeddings}
+			args := c.Args()
+			for _, name := range []string{"--pooling", "--embeddings", "--reranking"} {
+				if got, want := slices.Contains(args, name), slices.Contains(tt.want, name); got != want {
+					t.Errorf("%s in %q = %v, want %v", name, args, got, want)
+				}
+			}
+		})
+	}
+}
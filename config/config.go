@@ -9,10 +9,13 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/model/store"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
@@ -53,6 +56,18 @@ const (
 
 	DefaultNGpuLayers = -1
 
+	DefaultDraftMax = 16
+	DefaultDraftMin = 0
+
+	// DefaultDrainTimeout is the number of seconds the requests in flight
+	// have to complete when the server shuts down.
+	DefaultDrainTimeout = 30
+
+	DefaultFlashAttention = "auto"
+	DefaultCacheType      = "f16"
+	// DefaultYarn leaves a YaRN parameter to the model metadata.
+	DefaultYarn = -1.0
+
 	EXT = ".gguf" // TODO:We will soon release our better format
 )
 
@@ -64,6 +79,13 @@ var (
 var (
 	Conf = &Config{}
 
+	ConfigFile = &cli.StringFlag{
+		Name:        "config",
+		Usage:       "YAML or TOML config file, whose settings are overridden by the command line and the environment",
+		EnvVars:     []string{"LLAMAGO_CONFIG"},
+		Destination: &Conf.ConfigFile,
+	}
+
 	LogLevel = &cli.StringFlag{
 		Name:        "log-level",
 		Aliases:     []string{"l"},
@@ -135,6 +157,13 @@ var (
 		Destination: &Conf.Pooling,
 	}
 
+	Embeddings = &cli.BoolFlag{
+		Name:        "embeddings",
+		Usage:       "Serve the embeddings of a model which does not declare a pooling type, such as a chat model",
+		EnvVars:     []string{"LLAMAGO_EMBEDDINGS"},
+		Destination: &Conf.Embeddings,
+	}
+
 	BatchSize = &cli.IntFlag{
 		Name:        "batch-size",
 		Aliases:     []string{"b"},
@@ -216,7 +245,187 @@ var (
 		Destination: &Conf.NoPrune,
 	}
 
+	Lora = &cli.StringSliceFlag{
+		Name:    "lora",
+		Usage:   "LoRA adapter to apply as path[:scale], relative to the model directory unless a path (can be repeated)",
+		EnvVars: []string{"LLAMAGO_LORA"},
+		Action: func(ctx *cli.Context, v []string) error {
+			Conf.Lora = v
+			return nil
+		},
+	}
+
+	ModelDraft = &cli.StringFlag{
+		Name:        "model-draft",
+		Usage:       "Draft model for speculative decoding, sharing the vocabulary of the model",
+		EnvVars:     []string{"LLAMAGO_MODEL_DRAFT"},
+		Destination: &Conf.ModelDraft,
+	}
+
+	DraftMax = &cli.IntFlag{
+		Name:        "draft-max",
+		Usage:       "Number of tokens to draft for speculative decoding",
+		Value:       DefaultDraftMax,
+		EnvVars:     []string{"LLAMAGO_DRAFT_MAX"},
+		Destination: &Conf.DraftMax,
+	}
+
+	DraftMin = &cli.IntFlag{
+		Name:        "draft-min",
+		Usage:       "Minimum number of draft tokens to use for speculative decoding",
+		Value:       DefaultDraftMin,
+		EnvVars:     []string{"LLAMAGO_DRAFT_MIN"},
+		Destination: &Conf.DraftMin,
+	}
+
+	Threads = &cli.IntFlag{
+		Name:        "threads",
+		Usage:       "Number of threads to use during generation, 0 for the core default",
+		EnvVars:     []string{"LLAMAGO_THREADS"},
+		Destination: &Conf.Threads,
+	}
+
+	Parallel = &cli.IntFlag{
+		Name:        "parallel",
+		Usage:       "Number of requests decoded in parallel, 0 for the core default",
+		EnvVars:     []string{"LLAMAGO_PARALLEL"},
+		Destination: &Conf.Parallel,
+	}
+
+	FlashAttention = &cli.StringFlag{
+		Name:        "flash-attn",
+		Aliases:     []string{"fa"},
+		Usage:       "Use flash attention {on, off, auto}",
+		Value:       DefaultFlashAttention,
+		EnvVars:     []string{"LLAMAGO_FLASH_ATTENTION"},
+		Destination: &Conf.FlashAttention,
+	}
+
+	CacheTypeK = &cli.StringFlag{
+		Name:        "cache-type-k",
+		Usage:       "KV cache data type for K {f16, q8_0, q4_0}",
+		Value:       DefaultCacheType,
+		EnvVars:     []string{"LLAMAGO_CACHE_TYPE_K"},
+		Destination: &Conf.CacheTypeK,
+	}
+
+	CacheTypeV = &cli.StringFlag{
+		Name:        "cache-type-v",
+		Usage:       "KV cache data type for V {f16, q8_0, q4_0}, quantized types require flash attention",
+		Value:       DefaultCacheType,
+		EnvVars:     []string{"LLAMAGO_CACHE_TYPE_V"},
+		Destination: &Conf.CacheTypeV,
+	}
+
+	Mlock = &cli.BoolFlag{
+		Name:        "mlock",
+		Usage:       "Force the system to keep the model in RAM rather than swapping or compressing",
+		EnvVars:     []string{"LLAMAGO_MLOCK"},
+		Destination: &Conf.Mlock,
+	}
+
+	NoMmap = &cli.BoolFlag{
+		Name:        "no-mmap",
+		Usage:       "Do not memory-map the model, slower to load but may reduce pageouts",
+		EnvVars:     []string{"LLAMAGO_NO_MMAP"},
+		Destination: &Conf.NoMmap,
+	}
+
+	ContBatching = &cli.BoolFlag{
+		Name:        "cont-batching",
+		Usage:       "Enable continuous batching, a.k.a. dynamic batching, on by default and disabled with --cont-batching=false",
+		Value:       true,
+		EnvVars:     []string{"LLAMAGO_CONT_BATCHING"},
+		Destination: &Conf.ContBatching,
+	}
+
+	RopeScaling = &cli.StringFlag{
+		Name:        "rope-scaling",
+		Usage:       "RoPE frequency scaling method {none, linear, yarn}, the model default if unspecified",
+		EnvVars:     []string{"LLAMAGO_ROPE_SCALING"},
+		Destination: &Conf.RopeScaling,
+	}
+
+	RopeScale = &cli.Float64Flag{
+		Name:        "rope-scale",
+		Usage:       "RoPE context scaling factor, expands context by a factor of N, 0 for the model default",
+		EnvVars:     []string{"LLAMAGO_ROPE_SCALE"},
+		Destination: &Conf.RopeScale,
+	}
+
+	YarnOrigCtx = &cli.IntFlag{
+		Name:        "yarn-orig-ctx",
+		Usage:       "YaRN original context size of the model, 0 for the training context size",
+		EnvVars:     []string{"LLAMAGO_YARN_ORIG_CTX"},
+		Destination: &Conf.YarnOrigCtx,
+	}
+
+	YarnExtFactor = &cli.Float64Flag{
+		Name:        "yarn-ext-factor",
+		Usage:       "YaRN extrapolation mix factor, 0.0 for full interpolation, -1 for the model default",
+		Value:       DefaultYarn,
+		EnvVars:     []string{"LLAMAGO_YARN_EXT_FACTOR"},
+		Destination: &Conf.YarnExtFactor,
+	}
+
+	YarnAttnFactor = &cli.Float64Flag{
+		Name:        "yarn-attn-factor",
+		Usage:       "YaRN scale sqrt(t) or attention magnitude, -1 for the model default",
+		Value:       DefaultYarn,
+		EnvVars:     []string{"LLAMAGO_YARN_ATTN_FACTOR"},
+		Destination: &Conf.YarnAttnFactor,
+	}
+
+	YarnBetaSlow = &cli.Float64Flag{
+		Name:        "yarn-beta-slow",
+		Usage:       "YaRN high correction dim or alpha, -1 for the model default",
+		Value:       DefaultYarn,
+		EnvVars:     []string{"LLAMAGO_YARN_BETA_SLOW"},
+		Destination: &Conf.YarnBetaSlow,
+	}
+
+	YarnBetaFast = &cli.Float64Flag{
+		Name:        "yarn-beta-fast",
+		Usage:       "YaRN low correction dim or beta, -1 for the model default",
+		Value:       DefaultYarn,
+		EnvVars:     []string{"LLAMAGO_YARN_BETA_FAST"},
+		Destination: &Conf.YarnBetaFast,
+	}
+
+	APIKeys = &cli.StringSliceFlag{
+		Name:    "api-key",
+		Usage:   "API key the clients of the server have to give as a bearer token (can be repeated)",
+		EnvVars: []string{"LLAMAGO_API_KEY"},
+		Action: func(ctx *cli.Context, v []string) error {
+			Conf.APIKeys = v
+			return nil
+		},
+	}
+
+	MaxRequests = &cli.IntFlag{
+		Name:        "max-requests",
+		Usage:       "Maximum number of requests handled at once, further requests get 503, 0 for no limit",
+		EnvVars:     []string{"LLAMAGO_MAX_REQUESTS"},
+		Destination: &Conf.MaxRequests,
+	}
+
+	DrainTimeout = &cli.IntFlag{
+		Name:        "drain-timeout",
+		Usage:       "Seconds the requests in flight have to complete when the server shuts down or restarts the runner, before they are cut off",
+		Value:       DefaultDrainTimeout,
+		EnvVars:     []string{"LLAMAGO_DRAIN_TIMEOUT"},
+		Destination: &Conf.DrainTimeout,
+	}
+
+	WhisperModel = &cli.StringFlag{
+		Name:        "whisper-model",
+		Usage:       "whisper model transcribing the audio of /v1/audio/transcriptions, a file name under the model directory or a path",
+		EnvVars:     []string{"LLAMAGO_WHISPER_MODEL"},
+		Destination: &Conf.WhisperModel,
+	}
+
 	AppFlags = []cli.Flag{
+		ConfigFile,
 		LogLevel,
 		Model,
 		ModelDir,
@@ -226,6 +435,7 @@ var (
 		NPredict,
 		Seed,
 		Pooling,
+		Embeddings,
 		BatchSize,
 		UBatchSize,
 		OutputFile,
@@ -236,52 +446,304 @@ var (
 		ChatTemplateFile,
 		ChatTemplateKwargs,
 		NoPrune,
+		Lora,
+		ModelDraft,
+		DraftMax,
+		DraftMin,
+		Threads,
+		Parallel,
+		FlashAttention,
+		CacheTypeK,
+		CacheTypeV,
+		Mlock,
+		NoMmap,
+		ContBatching,
+		RopeScaling,
+		RopeScale,
+		YarnOrigCtx,
+		YarnExtFactor,
+		YarnAttnFactor,
+		YarnBetaSlow,
+		YarnBetaFast,
+		APIKeys,
+		MaxRequests,
+		DrainTimeout,
+		WhisperModel,
 	}
 )
 
+// Config holds the settings of the flags, each field being tagged with the
+// name of its flag, which is also its key in the config file.
 type Config struct {
-	LogLevel string
-	Model    string
-	ModelDir string
-
-	CtxSize            int
-	Prompt             string
-	NGpuLayers         int
-	NPredict           int
-	Seed               uint
-	Pooling            string
-	BatchSize          int
-	UBatchSize         int
-	OutputFile         string
-	Host               string
-	Origins            string
-	Jinja              bool
-	ChatTemplate       string
-	ChatTemplateFile   string
-	ChatTemplateKwargs string
-	NoPrune            bool
+	ConfigFile string
+	LogLevel   string `flag:"log-level"`
+	Model      string `flag:"model"`
+	ModelDir   string `flag:"model-dir"`
+
+	CtxSize            int      `flag:"ctx-size"`
+	Prompt             string   `flag:"prompt"`
+	NGpuLayers         int      `flag:"n-gpu-layers"`
+	NPredict           int      `flag:"n-predict"`
+	Seed               uint     `flag:"seed"`
+	Pooling            string   `flag:"pooling"`
+	Embeddings         bool     `flag:"embeddings"`
+	BatchSize          int      `flag:"batch-size"`
+	UBatchSize         int      `flag:"ubatch-size"`
+	OutputFile         string   `flag:"output-file"`
+	Host               string   `flag:"host"`
+	Origins            string   `flag:"origins"`
+	Jinja              bool     `flag:"jinja"`
+	ChatTemplate       string   `flag:"chat-template"`
+	ChatTemplateFile   string   `flag:"chat-template-file"`
+	ChatTemplateKwargs string   `flag:"chat-template-kwargs"`
+	NoPrune            bool     `flag:"noprune"`
+	Lora               []string `flag:"lora"`
+	ModelDraft         string   `flag:"model-draft"`
+	DraftMax           int      `flag:"draft-max"`
+	DraftMin           int      `flag:"draft-min"`
+	Threads            int      `flag:"threads"`
+	Parallel           int      `flag:"parallel"`
+	FlashAttention     string   `flag:"flash-attn"`
+	CacheTypeK         string   `flag:"cache-type-k"`
+	CacheTypeV         string   `flag:"cache-type-v"`
+	Mlock              bool     `flag:"mlock"`
+	NoMmap             bool     `flag:"no-mmap"`
+	ContBatching       bool     `flag:"cont-batching"`
+	RopeScaling        string   `flag:"rope-scaling"`
+	RopeScale          float64  `flag:"rope-scale"`
+	YarnOrigCtx        int      `flag:"yarn-orig-ctx"`
+	YarnExtFactor      float64  `flag:"yarn-ext-factor"`
+	YarnAttnFactor     float64  `flag:"yarn-attn-factor"`
+	YarnBetaSlow       float64  `flag:"yarn-beta-slow"`
+	YarnBetaFast       float64  `flag:"yarn-beta-fast"`
+	APIKeys            []string `flag:"api-key"`
+	MaxRequests        int      `flag:"max-requests"`
+	DrainTimeout       int      `flag:"drain-timeout"`
+	WhisperModel       string   `flag:"whisper-model"`
+
+	// Aliases are the aliases of the config file, which take precedence over
+	// those of the model store.
+	Aliases map[string]string
+
+	// base holds the settings before the config file was applied, and fixed
+	// the flags given on the command line or through the environment, which
+	// the config file does not override when it is reloaded.
+	base  *Config
+	fixed []string
+}
+
+// LoraAdapter is a LoRA adapter loaded along with the model.
+type LoraAdapter struct {
+	Path  string
+	Scale float32
 }
 
 func (c *Config) Load() error {
 	log.Debug("Try to load config")
+	if err := c.checkSettings(); err != nil {
+		return err
+	}
 	if !c.HasModel() {
 		return fmt.Errorf("No config model")
 	}
 	log.Debug("Model info", "model path", c.ModelPath())
+	adapters, err := c.LoraAdapters()
+	if err != nil {
+		return err
+	}
+	for _, adapter := range adapters {
+		if !common.IsExist(adapter.Path) {
+			return fmt.Errorf("LoRA adapter %s not found", adapter.Path)
+		}
+	}
+	if err := c.checkLoadOptions(); err != nil {
+		return err
+	}
+	return c.checkDraft()
+}
+
+// checkSettings validates the settings of the server which do not depend on
+// the model.
+func (c *Config) checkSettings() error {
+	if !slices.Contains([]string{"trace", "debug", "info", "warn", "error"}, c.LogLevel) {
+		return fmt.Errorf("log-level must be one of trace, debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.DrainTimeout < 0 {
+		return fmt.Errorf("drain-timeout must not be negative, got %d", c.DrainTimeout)
+	}
+	if c.MaxRequests < 0 {
+		return fmt.Errorf("max-requests must not be negative, got %d", c.MaxRequests)
+	}
+	for _, key := range c.APIKeys {
+		if len(strings.TrimSpace(key)) <= 0 {
+			return fmt.Errorf("api-key must not be empty")
+		}
+	}
+	for alias, target := range c.Aliases {
+		if _, err := store.ParseName(alias); err != nil {
+			return fmt.Errorf("alias %q: %w", alias, err)
+		}
+		if len(target) <= 0 {
+			return fmt.Errorf("alias %q has no target", alias)
+		}
+	}
 	return nil
 }
 
-func (c *Config) ModelPath() string {
-	if len(c.Model) <= 0 {
-		return ""
+// checkLoadOptions validates the options the model is loaded with, including
+// those the model itself has to support.
+func (c *Config) checkLoadOptions() error {
+	if c.Threads < 0 {
+		return fmt.Errorf("threads must not be negative, got %d", c.Threads)
+	}
+	if c.Parallel < 0 {
+		return fmt.Errorf("parallel must not be negative, got %d", c.Parallel)
+	}
+	if !slices.Contains([]string{"", "on", "off", "auto"}, c.FlashAttention) {
+		return fmt.Errorf("flash-attn must be one of on, off or auto, got %q", c.FlashAttention)
+	}
+	if !slices.Contains([]string{"", "none", "linear", "yarn"}, c.RopeScaling) {
+		return fmt.Errorf("rope-scaling must be one of none, linear or yarn, got %q", c.RopeScaling)
+	}
+	if c.RopeScale < 0 {
+		return fmt.Errorf("rope-scale must not be negative, got %g", c.RopeScale)
+	}
+	if c.YarnOrigCtx < 0 {
+		return fmt.Errorf("yarn-orig-ctx must not be negative, got %d", c.YarnOrigCtx)
+	}
+	for name, v := range map[string]float64{
+		"yarn-ext-factor":  c.YarnExtFactor,
+		"yarn-attn-factor": c.YarnAttnFactor,
+		"yarn-beta-slow":   c.YarnBetaSlow,
+		"yarn-beta-fast":   c.YarnBetaFast,
+	} {
+		if v < 0 && v != DefaultYarn {
+			return fmt.Errorf("%s must not be negative, got %g", name, v)
+		}
+	}
+	if !f16(c.CacheTypeV) && c.FlashAttention == "off" {
+		return fmt.Errorf("cache-type-v %s requires flash attention", c.CacheTypeV)
+	}
+
+	if f16(c.CacheTypeK) && f16(c.CacheTypeV) && c.FlashAttention != "on" {
+		return nil
+	}
+	f, err := model.LoadGGML(c.ModelPath())
+	if err != nil {
+		return fmt.Errorf("model %s: %w", c.Model, err)
+	}
+	for _, cacheType := range []string{c.CacheTypeK, c.CacheTypeV} {
+		if !f.SupportsKVCacheType(cacheType) {
+			return fmt.Errorf("model %s does not support the cache type %s", c.Model, cacheType)
+		}
 	}
-	if !strings.Contains(c.Model, EXT) {
+	if c.FlashAttention == "on" && !f.SupportsFlashAttention() {
+		return fmt.Errorf("model %s does not support flash attention", c.Model)
+	}
+	return nil
+}
+
+// checkDraft validates the draft model, whose vocabulary must match the one
+// of the model for its tokens to be verified.
+func (c *Config) checkDraft() error {
+	if len(c.ModelDraft) <= 0 {
+		return nil
+	}
+	if c.DraftMax < 1 {
+		return fmt.Errorf("draft-max must be at least 1, got %d", c.DraftMax)
+	}
+	if c.DraftMin < 0 || c.DraftMin > c.DraftMax {
+		return fmt.Errorf("draft-min must be between 0 and draft-max (%d), got %d", c.DraftMax, c.DraftMin)
+	}
+	path := c.DraftModelPath()
+	if len(path) <= 0 || !common.IsExist(path) {
+		return fmt.Errorf("draft model %s not found", c.ModelDraft)
+	}
+	target, err := model.LoadGGML(c.ModelPath())
+	if err != nil {
+		return err
+	}
+	draft, err := model.LoadGGML(path)
+	if err != nil {
+		return fmt.Errorf("draft model %s: %w", c.ModelDraft, err)
+	}
+	if err := target.KV().CompareVocab(draft.KV()); err != nil {
+		return fmt.Errorf("draft model %s does not match the vocabulary of %s: %w", c.ModelDraft, c.Model, err)
+	}
+	return nil
+}
+
+// DraftModelPath is the GGUF file of the draft model, if any.
+func (c *Config) DraftModelPath() string {
+	return c.GetModelPath(c.ModelDraft)
+}
+
+// f16 reports whether cacheType is the default cache type.
+func f16(cacheType string) bool {
+	return len(cacheType) <= 0 || cacheType == DefaultCacheType
+}
+
+// LoraAdapters returns the adapters of the --lora flags, after the adapters
+// of the model when it was created from a Modelfile.
+func (c *Config) LoraAdapters() ([]LoraAdapter, error) {
+	var ret []LoraAdapter
+	models := c.Models()
+	if m, err := models.Get(models.Resolve(c.Model)); err == nil {
+		for _, path := range m.Adapters {
+			ret = append(ret, LoraAdapter{Path: path, Scale: 1})
+		}
+	}
+	for _, v := range c.Lora {
+		adapter := parseLora(v)
+		if len(adapter.Path) <= 0 {
+			return nil, fmt.Errorf("invalid LoRA adapter %q", v)
+		}
+		if !common.IsFilePath(adapter.Path) {
+			path, err := filepath.Abs(filepath.Join(c.ModelDir, adapter.Path))
+			if err != nil {
+				return nil, err
+			}
+			adapter.Path = path
+		}
+		ret = append(ret, adapter)
+	}
+	return ret, nil
+}
+
+// parseLora parses a --lora flag given as path[:scale]. The path is split at
+// its last colon only when a finite scale follows, so that the colons of a
+// path such as C:\adapters\style.gguf are kept.
+func parseLora(v string) LoraAdapter {
+	adapter := LoraAdapter{Path: v, Scale: 1}
+	if i := strings.LastIndex(v, ":"); i >= 0 {
+		if scale, err := strconv.ParseFloat(v[i+1:], 32); err == nil && !math.IsInf(scale, 0) && !math.IsNaN(scale) {
+			adapter.Path, adapter.Scale = v[:i], float32(scale)
+		}
+	}
+	return adapter
+}
+
+// ModelPath is the GGUF file of the model the server is started with.
+func (c *Config) ModelPath() string {
+	return c.GetModelPath(c.Model)
+}
+
+// WhisperModelPath is the file of the whisper model transcribing the audio,
+// or "" when there is none.
+func (c *Config) WhisperModelPath() string {
+	return c.GetWhisperModelPath(c.WhisperModel)
+}
+
+// GetWhisperModelPath returns the file of the whisper model, which is a file
+// name under ModelDir or a path.
+func (c *Config) GetWhisperModelPath(model string) string {
+	if len(model) <= 0 {
 		return ""
 	}
-	if common.IsFilePath(c.Model) {
-		return c.Model
+	if common.IsFilePath(model) {
+		return model
 	}
-	ret, err := filepath.Abs(filepath.Join(c.ModelDir, c.Model))
+	ret, err := filepath.Abs(filepath.Join(c.ModelDir, model))
 	if err != nil {
 		return ""
 	}
@@ -294,7 +756,8 @@ func (c *Config) HasModel() bool {
 
 func (c *Config) GetModelFileInfos() []os.FileInfo {
 	var firstInfo os.FileInfo
-	if c.HasModel() {
+	// the blob of a named model is listed under its name instead
+	if c.HasModel() && filepath.Ext(c.ModelPath()) == EXT {
 		info, err := os.Stat(c.ModelPath())
 		if err == nil {
 			firstInfo = info
@@ -336,13 +799,30 @@ func (c *Config) GetModelFileInfos() []os.FileInfo {
 	return ret
 }
 
+// GetModelPath returns the GGUF file of model, which is a file name under
+// ModelDir, a path, an alias or the name:tag of a model created from a
+// Modelfile. It returns "" for an unknown model.
 func (c *Config) GetModelPath(model string) string {
 	if len(model) <= 0 {
 		return ""
 	}
-	if !strings.Contains(model, EXT) {
+	return c.ResolveModelPath(c.Models(), model)
+}
+
+// ResolveModelPath returns the GGUF file of model as GetModelPath, resolving
+// it through models, the store of ModelDir kept by the caller.
+func (c *Config) ResolveModelPath(models *store.Store, model string) string {
+	if len(model) <= 0 {
 		return ""
 	}
+	model = models.Resolve(model)
+	if !strings.Contains(model, EXT) {
+		m, err := models.Get(model)
+		if err != nil {
+			return ""
+		}
+		return m.Path
+	}
 	if common.IsFilePath(model) {
 		return model
 	}
@@ -353,6 +833,12 @@ func (c *Config) GetModelPath(model string) string {
 	return ret
 }
 
+// Models is the store of the models under ModelDir, with the aliases of the
+// config file.
+func (c *Config) Models() *store.Store {
+	return store.New(c.ModelDir).WithAliases(c.Aliases)
+}
+
 func (c *Config) HostURL() *url.URL {
 	defaultPort := DefaultPort
 	chost := c.Host
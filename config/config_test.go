@@ -0,0 +1,120 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/internal/testutil"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+func TestParseLora(t *testing.T) {
+	cases := []struct {
+		in    string
+		path  string
+		scale float32
+	}{
+		{"style.gguf", "style.gguf", 1},
+		{"style.gguf:0.5", "style.gguf", 0.5},
+		{"style.gguf:-1", "style.gguf", -1},
+		{"/adapters/style.gguf:2", "/adapters/style.gguf", 2},
+		{`C:\adapters\style.gguf`, `C:\adapters\style.gguf`, 1},
+		{`C:\adapters\style.gguf:0.25`, `C:\adapters\style.gguf`, 0.25},
+		{"/adapters/v1:style.gguf", "/adapters/v1:style.gguf", 1},
+		{"/adapters/v1:style.gguf:0.5", "/adapters/v1:style.gguf", 0.5},
+		{"style.gguf:inf", "style.gguf:inf", 1},
+		{"style.gguf:NaN", "style.gguf:NaN", 1},
+		{"style.gguf:", "style.gguf:", 1},
+	}
+
+	for _, c := range cases {
+		got := parseLora(c.in)
+		if got.Path != c.path || got.Scale != c.scale {
+			t.Errorf("parseLora(%q) = %+v, want {Path:%s Scale:%g}", c.in, got, c.path, c.scale)
+		}
+	}
+}
+
+func TestLoraAdapters(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "abs.gguf")
+	c := &Config{ModelDir: dir, Lora: []string{"style.gguf:0.5", abs}}
+
+	adapters, err := c.LoraAdapters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LoraAdapter{
+		{Path: filepath.Join(dir, "style.gguf"), Scale: 0.5},
+		{Path: abs, Scale: 1},
+	}
+	if len(adapters) != len(want) {
+		t.Fatalf("got %+v, want %+v", adapters, want)
+	}
+	for i := range want {
+		if adapters[i] != want[i] {
+			t.Errorf("adapter %d = %+v, want %+v", i, adapters[i], want[i])
+		}
+	}
+
+	c.Lora = []string{":0.5"}
+	if _, err := c.LoraAdapters(); err == nil {
+		t.Error("expected an error for an adapter without path")
+	}
+}
+
+func TestCheckLoadOptions(t *testing.T) {
+	llama := testutil.WriteModel(t, ggml.KV{
+		"general.architecture":         "llama",
+		"llama.attention.key_length":   uint32(128),
+		"llama.attention.value_length": uint32(128),
+	})
+	gemma := testutil.WriteModel(t, ggml.KV{"general.architecture": "gemma2"})
+
+	valid := func() *Config {
+		return &Config{
+			Model:          llama,
+			YarnExtFactor:  DefaultYarn,
+			YarnAttnFactor: DefaultYarn,
+			YarnBetaSlow:   DefaultYarn,
+			YarnBetaFast:   DefaultYarn,
+		}
+	}
+	cases := []struct {
+		desc   string
+		modify func(c *Config)
+		err    string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"threads", func(c *Config) { c.Threads = 8 }, ""},
+		{"negative threads", func(c *Config) { c.Threads = -1 }, "threads must not be negative"},
+		{"negative parallel", func(c *Config) { c.Parallel = -2 }, "parallel must not be negative"},
+		{"flash attention", func(c *Config) { c.FlashAttention = "on" }, ""},
+		{"unknown flash attention", func(c *Config) { c.FlashAttention = "yes" }, "flash-attn must be one of"},
+		{"flash attention unsupported", func(c *Config) { c.Model, c.FlashAttention = gemma, "on" }, "does not support flash attention"},
+		{"yarn", func(c *Config) { c.RopeScaling, c.RopeScale, c.YarnOrigCtx, c.YarnExtFactor = "yarn", 4, 32768, 1 }, ""},
+		{"unknown rope scaling", func(c *Config) { c.RopeScaling = "ntk" }, "rope-scaling must be one of"},
+		{"negative rope scale", func(c *Config) { c.RopeScale = -1 }, "rope-scale must not be negative"},
+		{"negative yarn context", func(c *Config) { c.YarnOrigCtx = -1 }, "yarn-orig-ctx must not be negative"},
+		{"negative yarn factor", func(c *Config) { c.YarnBetaFast = -2 }, "yarn-beta-fast must not be negative"},
+		{"quantized cache", func(c *Config) { c.CacheTypeK, c.CacheTypeV, c.FlashAttention = "q8_0", "q8_0", "on" }, ""},
+		{"quantized v cache without flash attention", func(c *Config) { c.CacheTypeV, c.FlashAttention = "q8_0", "off" }, "requires flash attention"},
+		{"unknown cache type", func(c *Config) { c.CacheTypeK = "q3_k" }, "does not support the cache type q3_k"},
+		{"missing model", func(c *Config) { c.Model, c.CacheTypeK = filepath.Join(t.TempDir(), "missing.gguf"), "q8_0" }, "missing.gguf"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.desc, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+			err := c.checkLoadOptions()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
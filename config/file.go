@@ -0,0 +1,299 @@
+// Copyright (c) 2017-2025 The qitmeer developers
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// aliasesKey is the section of the config file mapping aliases to models.
+	aliasesKey = "aliases"
+	// modelsKey is the section of the config file with the load options of
+	// each model, keyed by the name the model is given with --model.
+	modelsKey = "models"
+)
+
+// modelScoped are the settings which make no sense in the section of a model.
+var modelScoped = []string{"model", "model-dir"}
+
+// LoadFile applies the settings of the config file to the flags which were
+// given neither on the command line nor through the environment, so that
+// the command line comes first, then the environment, the file and the
+// defaults. The settings of the section of the model override those at the
+// top of the file.
+//
+// Settings are keyed by the flag names, e.g. in YAML:
+//
+//	host: 0.0.0.0:8081
+//	model: qwen
+//	ctx-size: 8192
+//	api-key: [secret]
+//	aliases:
+//	  qwen: qwen2.5-7b-q8_0.gguf
+//	models:
+//	  qwen:
+//	    ctx-size: 32768
+func (c *Config) LoadFile(ctx *cli.Context) error {
+	if len(c.ConfigFile) <= 0 {
+		return nil
+	}
+	var fixed []string
+	for _, name := range settingNames() {
+		if !ctx.IsSet(name) {
+			continue
+		}
+		fixed = append(fixed, name)
+		// the actions setting the lists run after LoadFile
+		if f, _ := c.setting(name); f.Kind() == reflect.Slice {
+			f.Set(reflect.ValueOf(ctx.StringSlice(name)))
+		}
+	}
+	base := *c
+	c.base, c.fixed = &base, fixed
+
+	settings, err := readFile(c.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if err := c.applyFile(settings); err != nil {
+		return fmt.Errorf("config file %s: %w", c.ConfigFile, err)
+	}
+	return nil
+}
+
+// Reload reads the config file again and returns the settings it results in
+// along with the command line and the environment, leaving c unchanged.
+func (c *Config) Reload() (*Config, error) {
+	if len(c.ConfigFile) <= 0 || c.base == nil {
+		return nil, fmt.Errorf("no config file to reload")
+	}
+	settings, err := readFile(c.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	next := *c.base
+	next.base, next.fixed = c.base, c.fixed
+	if err := next.applyFile(settings); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", c.ConfigFile, err)
+	}
+	if err := next.checkSettings(); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", c.ConfigFile, err)
+	}
+	return &next, nil
+}
+
+func (c *Config) applyFile(settings map[string]any) error {
+	aliases, err := stringMap(settings[aliasesKey])
+	if err != nil {
+		return fmt.Errorf("%s: %w", aliasesKey, err)
+	}
+	c.Aliases = aliases
+
+	models, ok := settings[modelsKey].(map[string]any)
+	if !ok && settings[modelsKey] != nil {
+		return fmt.Errorf("%s must be a table of models", modelsKey)
+	}
+	delete(settings, aliasesKey)
+	delete(settings, modelsKey)
+
+	model := c.Model
+	if v, ok := settings["model"]; ok && !slices.Contains(c.fixed, "model") {
+		model = fmt.Sprint(v)
+	}
+	if v, ok := models[model]; ok {
+		section, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s.%s must be a table of settings", modelsKey, model)
+		}
+		for name, v := range section {
+			if slices.Contains(modelScoped, name) {
+				return fmt.Errorf("%s.%s: %s cannot be set for a model", modelsKey, model, name)
+			}
+			settings[name] = v
+		}
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, ok := c.setting(name)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		if slices.Contains(c.fixed, name) {
+			continue
+		}
+		if err := setValue(f, settings[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readFile decodes the YAML or TOML config file at path.
+func readFile(path string) (map[string]any, error) {
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bts, &settings)
+	case ".toml":
+		err = toml.Unmarshal(bts, &settings)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// settingNames returns the names of the flags backed by a field of Config,
+// in the order of the fields.
+func settingNames() []string {
+	var ret []string
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		if name := t.Field(i).Tag.Get("flag"); len(name) > 0 {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// setting returns the field of c set by the flag name.
+func (c *Config) setting(name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i := range v.NumField() {
+		if v.Type().Field(i).Tag.Get("flag") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setValue sets the field f to the value v of the config file, parsed as if
+// it was given on the command line.
+func setValue(f reflect.Value, v any) error {
+	if vs, ok := v.([]any); ok {
+		if f.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		ss := make([]string, 0, len(vs))
+		for _, v := range vs {
+			ss = append(ss, fmt.Sprint(v))
+		}
+		f.Set(reflect.ValueOf(ss))
+		return nil
+	}
+	if _, ok := v.(map[string]any); ok {
+		return fmt.Errorf("expected a value, got a table")
+	}
+
+	s := fmt.Sprint(v)
+	var err error
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			f.SetBool(b)
+		}
+	case reflect.Int:
+		var n int64
+		if n, err = strconv.ParseInt(s, 0, 64); err == nil {
+			f.SetInt(n)
+		}
+	case reflect.Uint:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 0, 64); err == nil {
+			f.SetUint(n)
+		}
+	case reflect.Float64:
+		var n float64
+		if n, err = strconv.ParseFloat(s, 64); err == nil {
+			f.SetFloat(n)
+		}
+	case reflect.Slice:
+		f.Set(reflect.ValueOf([]string{s}))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q", s)
+	}
+	return nil
+}
+
+func stringMap(v any) (map[string]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a table")
+	}
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a string, got %v", k, v)
+		}
+		ret[k] = s
+	}
+	return ret, nil
+}
+
+// Print writes the effective settings in the format of a config file, either
+// yaml or toml. API keys are masked.
+func (c *Config) Print(format string) (string, error) {
+	var settings yaml.MapSlice
+	for _, name := range settingNames() {
+		f, _ := c.setting(name)
+		v := f.Interface()
+		if name == APIKeys.Name {
+			v = slices.Repeat([]string{"****"}, len(c.APIKeys))
+		}
+		settings = append(settings, yaml.MapItem{Key: name, Value: v})
+	}
+	if len(c.Aliases) > 0 {
+		settings = append(settings, yaml.MapItem{Key: aliasesKey, Value: c.Aliases})
+	}
+
+	switch format {
+	case "", "yaml":
+		bts, err := yaml.Marshal(settings)
+		return string(bts), err
+	case "toml":
+		m := make(map[string]any, len(settings))
+		for _, item := range settings {
+			m[item.Key.(string)] = item.Value
+		}
+		var buf bytes.Buffer
+		enc := toml.NewEncoder(&buf)
+		if err := enc.Encode(m); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, expected yaml or toml", format)
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func runWithFile(t *testing.T, name, content string, args ...string) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := &cli.App{
+		Flags: AppFlags,
+		Before: func(ctx *cli.Context) error {
+			return Conf.LoadFile(ctx)
+		},
+		Action: func(ctx *cli.Context) error { return nil },
+	}
+	return app.Run(append([]string{"llama", "--config", path}, args...))
+}
+
+func TestLoadFilePrecedence(t *testing.T) {
+	t.Setenv("LLAMAGO_THREADS", "3")
+	err := runWithFile(t, "llama.yaml", `
+model: qwen
+ctx-size: 2048
+threads: 8
+parallel: 2
+flash-attn: "on"
+api-key: [a, b]
+aliases:
+  chat: qwen2.5-0.5b-q8_0.gguf
+models:
+  qwen:
+    parallel: 4
+    n-gpu-layers: 10
+  other:
+    parallel: 8
+`, "--ctx-size", "1024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if Conf.Model != "qwen" {
+		t.Errorf("model = %q, want qwen from the file", Conf.Model)
+	}
+	if Conf.CtxSize != 1024 {
+		t.Errorf("ctx-size = %d, want 1024 from the command line", Conf.CtxSize)
+	}
+	if Conf.Threads != 3 {
+		t.Errorf("threads = %d, want 3 from the environment", Conf.Threads)
+	}
+	if Conf.Parallel != 4 || Conf.NGpuLayers != 10 {
+		t.Errorf("parallel, n-gpu-layers = %d, %d, want 4, 10 from the section of the model", Conf.Parallel, Conf.NGpuLayers)
+	}
+	if Conf.FlashAttention != "on" {
+		t.Errorf("flash-attn = %q, want on", Conf.FlashAttention)
+	}
+	if Conf.BatchSize != 2048 {
+		t.Errorf("batch-size = %d, want the default 2048", Conf.BatchSize)
+	}
+	if strings.Join(Conf.APIKeys, ",") != "a,b" {
+		t.Errorf("api-key = %v, want [a b]", Conf.APIKeys)
+	}
+	if Conf.Aliases["chat"] != "qwen2.5-0.5b-q8_0.gguf" {
+		t.Errorf("aliases = %v", Conf.Aliases)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	cases := []struct {
+		name, content, err string
+	}{
+		{"llama.toml", "ctx-sise = 2048\n", `unknown setting "ctx-sise"`},
+		{"llama.toml", "ctx-size = \"large\"\n", `ctx-size: invalid value "large"`},
+		{"llama.yaml", "n-predict: [1, 2]\n", "n-predict: expected a single value"},
+		{"llama.yaml", "models:\n  qwen:\n    model-dir: /tmp\nmodel: qwen\n", "model-dir cannot be set for a model"},
+		{"llama.yaml", "aliases:\n  chat: 1\n", "aliases: chat: expected a string"},
+		{"llama.json", "{}", "unsupported format"},
+	}
+	for _, tt := range cases {
+		err := runWithFile(t, tt.name, tt.content)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s %q: got error %v, want %q", tt.name, tt.content, err, tt.err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llama.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ubatch-size: 256\nmax-requests: 4\norigins: http://a.example\n")
+	app := &cli.App{
+		Flags: AppFlags,
+		Before: func(ctx *cli.Context) error {
+			return Conf.LoadFile(ctx)
+		},
+		Action: func(ctx *cli.Context) error { return nil },
+	}
+	if err := app.Run([]string{"llama", "--config", path, "--origins", "http://cli.example", "--lora", "style.gguf"}); err != nil {
+		t.Fatal(err)
+	}
+
+	write("max-requests: 8\norigins: http://b.example\nlog-level: debug\naliases:\n  chat: qwen.gguf\n")
+	next, err := Conf.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Conf.MaxRequests != 4 || Conf.UBatchSize != 256 {
+		t.Errorf("Reload changed the config to max-requests %d, ubatch-size %d", Conf.MaxRequests, Conf.UBatchSize)
+	}
+	if next.MaxRequests != 8 || next.LogLevel != "debug" || next.Aliases["chat"] != "qwen.gguf" {
+		t.Errorf("unexpected reloaded settings %+v", next)
+	}
+	if next.UBatchSize != 512 {
+		t.Errorf("ubatch-size = %d, want the default once removed from the file", next.UBatchSize)
+	}
+	if next.Origins != "http://cli.example" || strings.Join(next.Lora, ",") != "style.gguf" {
+		t.Errorf("origins, lora = %q, %v, want those of the command line", next.Origins, next.Lora)
+	}
+
+	write("max-requests: -1\n")
+	if _, err := Conf.Reload(); err == nil || !strings.Contains(err.Error(), "max-requests must not be negative") {
+		t.Errorf("got error %v, want an invalid max-requests", err)
+	}
+}
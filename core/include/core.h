@@ -1,4 +1,3 @@
 #pragma once
 
-#include "process.h"
-#include "embedding.h"
\ No newline at end of file
+#include "process.h"
\ No newline at end of file
@@ -21,21 +21,32 @@ typedef struct LlamaHTTPBody {
     char *body;
 } LlamaHTTPBody;
 
-bool llama_start(const char * args);
+/** argv[0] is the program name, as for main. */
+bool llama_start(int argc, const char ** argv);
 bool llama_stop();
 Result llama_gen(int id,const char * js_str);
 Result llama_chat(int id,const char * js_str);
+Result llama_infill(int id,const char * js_str);
 
-bool llama_interactive_start(const char * args,const char * prompt);
+bool llama_interactive_start(int argc, const char ** argv, const char * prompt);
 bool llama_interactive_stop();
 
-Result whisper_gen(const char * model,const char * input);
-
 bool llama_is_running(void);
 
 CommonParams get_common_params();
 LlamaHTTPBody llama_props_http(void);
 LlamaHTTPBody llama_slots_http(void);
+LlamaHTTPBody llama_lora_adapters_http(void);
+LlamaHTTPBody llama_lora_adapters_set_http(const char * js_str);
+LlamaHTTPBody llama_tokenize_http(const char * js_str);
+LlamaHTTPBody llama_detokenize_http(const char * js_str);
+LlamaHTTPBody llama_embeddings_http(const char * js_str);
+LlamaHTTPBody llama_rerank_http(const char * js_str);
+LlamaHTTPBody llama_apply_template_http(const char * js_str);
+/** Transcribes 16 kHz mono samples with whisper, independently of llama_start. */
+LlamaHTTPBody whisper_transcribe_http(const char * js_str, const float * samples, int n_samples);
+/** Loads the whisper model ahead of whisper_transcribe_http. */
+bool whisper_load(const char * model);
 
 #ifdef __cplusplus
 }
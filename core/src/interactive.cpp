@@ -1,13 +1,8 @@
 #include "process.h"
 #include "runner.h"
 
-bool llama_interactive_start(const char * args,const char * prompt) {
-    std::istringstream iss(args);
-    std::vector<std::string> v_args;
-    std::string v_a;
-    while (iss >> v_a) {
-        v_args.push_back(v_a);
-    }
+bool llama_interactive_start(int argc, const char ** argv, const char * prompt) {
+    std::vector<std::string> v_args(argv, argv + argc);
     return Runner::instance().start(1,v_args, false,std::string(prompt));
 }
 
@@ -9,21 +9,17 @@
 #include <string>
 
 extern "C" {
-    void PushToChan(int id, const char* val);
+    bool PushToChan(int id, const char* val);
+    bool ChanCancelled(int id);
     void CloseChan(int id);
 }
 
-bool llama_start(const char * args) {
+bool llama_start(int argc, const char ** argv) {
     if (Server::instance().is_running()) {
         return false;
     }
 
-    std::istringstream iss(args);
-    std::vector<std::string> v_args;
-    std::string v_a;
-    while (iss >> v_a) {
-        v_args.push_back(v_a);
-    }
+    std::vector<std::string> v_args(argv, argv + argc);
 
     if (!Server::instance().start(v_args)) {
         return false;
@@ -53,9 +49,10 @@ Result llama_gen(int id, const char * js_str) {
             id,
             std::string(js_str),
             [](int cid, const std::string & content) {
-                PushToChan(cid, content.c_str());
-                return true;
-            }
+                // false once the task is cancelled, which stops the stream
+                return PushToChan(cid, content.c_str());
+            },
+            [id] { return ChanCancelled(id); }
     };
 
     server_http_res_ptr rp = Server::instance().post_completions(rq);
@@ -77,9 +74,9 @@ Result llama_chat(int id, const char * js_str) {
             id,
             std::string(js_str),
             [](int cid, const std::string & content) {
-                PushToChan(cid, content.c_str());
-                return true;
-            }
+                return PushToChan(cid, content.c_str());
+            },
+            [id] { return ChanCancelled(id); }
     };
 
     server_http_res_ptr rp = Server::instance().post_chat_completions(rq);
@@ -89,10 +86,28 @@ Result llama_chat(int id, const char * js_str) {
     return {ok, nullptr};
 }
 
-Result whisper_gen(const char * model,const char * input) {
-    (void)model;
-    (void)input;
-    return {true, nullptr};
+Result llama_infill(int id, const char * js_str) {
+    if (!Server::instance().is_running()) {
+        return {false, nullptr};
+    }
+    if (!js_str) {
+        return {false, nullptr};
+    }
+
+    server_http_req rq{
+            id,
+            std::string(js_str),
+            [](int cid, const std::string & content) {
+                return PushToChan(cid, content.c_str());
+            },
+            [id] { return ChanCancelled(id); }
+    };
+
+    server_http_res_ptr rp = Server::instance().post_infill(rq);
+    const bool ok = rp->is_success();
+
+    CloseChan(id);
+    return {ok, nullptr};
 }
 
 static LlamaHTTPBody make_http_body(const server_http_res_ptr &rp) {
@@ -141,4 +156,116 @@ LlamaHTTPBody llama_slots_http(void) {
     return make_http_body(Server::instance().get_slots(req));
 }
 
+LlamaHTTPBody llama_lora_adapters_http(void) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    server_http_req req{};
+    return make_http_body(Server::instance().get_lora_adapters(req));
+}
+
+LlamaHTTPBody llama_lora_adapters_set_http(const char * js_str) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    if (!js_str) {
+        out.status = 400;
+        return out;
+    }
+    server_http_req req{0, std::string(js_str)};
+    return make_http_body(Server::instance().post_lora_adapters(req));
+}
+
+LlamaHTTPBody llama_tokenize_http(const char * js_str) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    if (!js_str) {
+        out.status = 400;
+        return out;
+    }
+    server_http_req req{0, std::string(js_str)};
+    return make_http_body(Server::instance().post_tokenize(req));
+}
+
+LlamaHTTPBody llama_detokenize_http(const char * js_str) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    if (!js_str) {
+        out.status = 400;
+        return out;
+    }
+    server_http_req req{0, std::string(js_str)};
+    return make_http_body(Server::instance().post_detokenize(req));
+}
+
+LlamaHTTPBody llama_embeddings_http(const char * js_str) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    if (!js_str) {
+        out.status = 400;
+        return out;
+    }
+    server_http_req req{0, std::string(js_str)};
+    return make_http_body(Server::instance().post_embeddings(req));
+}
+
+LlamaHTTPBody llama_rerank_http(const char * js_str) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    if (!js_str) {
+        out.status = 400;
+        return out;
+    }
+    server_http_req req{0, std::string(js_str)};
+    return make_http_body(Server::instance().post_rerank(req));
+}
+
+LlamaHTTPBody llama_apply_template_http(const char * js_str) {
+    LlamaHTTPBody out{};
+    out.status = 503;
+    if (!Server::instance().is_running()) {
+        return out;
+    }
+    if (!js_str) {
+        out.status = 400;
+        return out;
+    }
+    server_http_req req{0, std::string(js_str)};
+    return make_http_body(Server::instance().post_apply_template(req));
+}
+
+LlamaHTTPBody whisper_transcribe_http(const char * js_str, const float * samples, int n_samples) {
+    LlamaHTTPBody out{};
+    out.status = 400;
+    if (!js_str || n_samples < 0) {
+        return out;
+    }
+    const std::string body = WhisperService::instance().transcribe(std::string(js_str), samples, (size_t) n_samples, out.status);
+    out.body = strdup(body.c_str());
+    return out;
+}
+
+bool whisper_load(const char * model) {
+    if (!model) {
+        return false;
+    }
+    return WhisperService::instance().load(std::string(model));
+}
+
 }
\ No newline at end of file
@@ -215,6 +215,10 @@ server_http_res_ptr Server::post_chat_completions(const server_http_req &req) {
    return process(routes->post_chat_completions,req);
 }
 
+server_http_res_ptr Server::post_infill(const server_http_req &req) {
+    return process(routes->post_infill, req);
+}
+
 server_http_res_ptr Server::get_props(const server_http_req &req) {
     return process(routes->get_props, req);
 }
@@ -223,6 +227,34 @@ server_http_res_ptr Server::get_slots(const server_http_req &req) {
     return process(routes->get_slots, req);
 }
 
+server_http_res_ptr Server::get_lora_adapters(const server_http_req &req) {
+    return process(routes->get_lora_adapters, req);
+}
+
+server_http_res_ptr Server::post_lora_adapters(const server_http_req &req) {
+    return process(routes->post_lora_adapters, req);
+}
+
+server_http_res_ptr Server::post_tokenize(const server_http_req &req) {
+    return process(routes->post_tokenize, req);
+}
+
+server_http_res_ptr Server::post_detokenize(const server_http_req &req) {
+    return process(routes->post_detokenize, req);
+}
+
+server_http_res_ptr Server::post_embeddings(const server_http_req &req) {
+    return process(routes->post_embeddings, req);
+}
+
+server_http_res_ptr Server::post_rerank(const server_http_req &req) {
+    return process(routes->post_rerank, req);
+}
+
+server_http_res_ptr Server::post_apply_template(const server_http_req &req) {
+    return process(routes->post_apply_template, req);
+}
+
 bool Server::endpoint_props() const {
     if (!routes) {
         return false;
@@ -28,8 +28,16 @@ public:
     bool get_health();
     server_http_res_ptr post_completions(const server_http_req& req);
     server_http_res_ptr post_chat_completions(const server_http_req& req);
+    server_http_res_ptr post_infill(const server_http_req& req);
     server_http_res_ptr get_props(const server_http_req& req);
     server_http_res_ptr get_slots(const server_http_req& req);
+    server_http_res_ptr get_lora_adapters(const server_http_req& req);
+    server_http_res_ptr post_lora_adapters(const server_http_req& req);
+    server_http_res_ptr post_tokenize(const server_http_req& req);
+    server_http_res_ptr post_detokenize(const server_http_req& req);
+    server_http_res_ptr post_embeddings(const server_http_req& req);
+    server_http_res_ptr post_rerank(const server_http_req& req);
+    server_http_res_ptr post_apply_template(const server_http_req& req);
     bool is_running() const;
     bool endpoint_props() const;
 
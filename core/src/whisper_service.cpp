@@ -1,301 +1,202 @@
 #include "whisper_service.h"
 
-#include <iostream>
-
-#include "examples/common.h"
-#include "examples/common-whisper.h"
 #include "whisper.h"
-#include <cmath>
-#include <fstream>
-#include <cstdio>
-#include <string>
-#include <thread>
-#include <vector>
-#include <cstring>
-#include <cfloat>
 
-#if defined(_WIN32)
-#ifndef NOMINMAX
-#define NOMINMAX
-#endif
-#include <windows.h>
-#endif
+#include <nlohmann/json.hpp>
 
-struct whisper_params {
-    int32_t n_threads     = std::min(4, (int32_t) std::thread::hardware_concurrency());
-    int32_t n_processors  = 1;
-    int32_t offset_t_ms   = 0;
-    int32_t offset_n      = 0;
-    int32_t duration_ms   = 0;
-    int32_t progress_step = 5;
-    int32_t max_context   = -1;
-    int32_t max_len       = 0;
-    int32_t best_of       = whisper_full_default_params(WHISPER_SAMPLING_GREEDY).greedy.best_of;
-    int32_t beam_size     = whisper_full_default_params(WHISPER_SAMPLING_BEAM_SEARCH).beam_search.beam_size;
-    int32_t audio_ctx     = 0;
-
-    float word_thold      =  0.01f;
-    float entropy_thold   =  2.40f;
-    float logprob_thold   = -1.00f;
-    float no_speech_thold =  0.6f;
-    float grammar_penalty = 100.0f;
-    float temperature     = 0.0f;
-    float temperature_inc = 0.2f;
+#include <algorithm>
+#include <cstdio>
+#include <thread>
 
-    bool debug_mode      = false;
-    bool translate       = false;
-    bool detect_language = false;
-    bool diarize         = false;
-    bool tinydiarize     = false;
-    bool split_on_word   = false;
-    bool no_fallback     = false;
-    bool output_wts      = false;
-    bool output_jsn_full = false;
-    bool print_special   = false;
-    bool print_colors    = false;
-    bool print_confidence= false;
-    bool print_progress  = false;
-    bool no_timestamps   = true;
-    bool use_gpu         = true;
-    bool flash_attn      = false;
-    bool suppress_nst    = false;
+using json = nlohmann::ordered_json;
 
-    std::string language  = "en";
+struct whisper_transcribe_params {
+    std::string model;
+    std::string language = "auto";
     std::string prompt;
-    std::string font_path = "/System/Library/Fonts/Supplemental/Courier New Bold.ttf";
-    std::string model     = "";
-    std::string grammar;
-    std::string grammar_rule;
-
-    // [TDRZ] speaker turn string
-    std::string tdrz_speaker_turn = " [SPEAKER_TURN]"; // TODO: set from command line
-
-    // A regular expression that matches tokens to suppress
-    std::string suppress_regex;
-
-    std::string openvino_encode_device = "CPU";
-
-    std::string dtw = "";
-
-    std::vector<std::string> fname_inp = {};
-    std::vector<std::string> fname_out = {};
-
-
-    // Voice Activity Detection (VAD) parameters
-    bool        vad           = false;
-    std::string vad_model     = "";
-    float       vad_threshold = 0.5f;
-    int         vad_min_speech_duration_ms = 250;
-    int         vad_min_silence_duration_ms = 100;
-    float       vad_max_speech_duration_s = FLT_MAX;
-    int         vad_speech_pad_ms = 30;
-    float       vad_samples_overlap = 0.1f;
-};
-
-struct whisper_print_user_data {
-    const whisper_params * params;
-
-    const std::vector<std::vector<float>> * pcmf32s;
-    int progress_prev;
-
-    std::string * ret;
+    float temperature    = 0.0f;
+    bool translate       = false;
+    int32_t n_threads    = 0;
+    bool word_timestamps = false;
+    bool single_segment  = false;
+    bool no_context      = true;
 };
 
-WhisperService::WhisperService() {
-    std::cout << "Whisper Constructor:"<< std::endl;
+static std::string trim(const std::string& s) {
+    const size_t begin = s.find_first_not_of(" \t\n");
+    if (begin == std::string::npos) {
+        return "";
+    }
+    return s.substr(begin, s.find_last_not_of(" \t\n") - begin + 1);
 }
 
-WhisperService::~WhisperService() {
-    std::cout << "Whisper Destructor:"<< std::endl;
+static std::string error_json(const std::string& message) {
+    return json{{"error", message}}.dump();
 }
 
-const std::string WhisperService::generate(const std::string& model,const std::string& input) {
-    std::string ret;
-    if (model.empty() || input.empty()) {
-        return ret;
+std::shared_ptr<whisper_context> WhisperService::context(const std::string& model) {
+    std::lock_guard<std::mutex> lock(mtx);
+    if (ctx && loaded_model == model) {
+        return ctx;
     }
 
     ggml_backend_load_all();
 
-#if defined(_WIN32)
-    // Set the console output code page to UTF-8, while command line arguments
-    // are still encoded in the system's code page. In this way, we can print
-    // non-ASCII characters to the console, and access files with non-ASCII paths.
-    SetConsoleOutputCP(CP_UTF8);
-#endif
-
-    whisper_params params;
-    params.fname_inp={input};
-    params.model=model;
-
+    struct whisper_context_params cparams = whisper_context_default_params();
+    struct whisper_context * wctx = whisper_init_from_file_with_params(model.c_str(), cparams);
+    if (wctx == nullptr) {
+        fprintf(stderr, "error: failed to initialize whisper context of '%s'\n", model.c_str());
+        return nullptr;
+    }
+    // initialize openvino encoder. this has no effect on whisper.cpp builds that don't have OpenVINO configured
+    whisper_ctx_init_openvino_encoder(wctx, nullptr, "CPU", nullptr);
 
-    // remove non-existent files
-    for (auto it = params.fname_inp.begin(); it != params.fname_inp.end();) {
-        const auto fname_inp = it->c_str();
+    ctx.reset(wctx, whisper_free);
+    loaded_model = model;
+    return ctx;
+}
 
-        if (*it != "-" && !is_file_exist(fname_inp)) {
-            fprintf(stderr, "error: input file not found '%s'\n", fname_inp);
-            it = params.fname_inp.erase(it);
-            continue;
-        }
+bool WhisperService::load(const std::string& model) {
+    return context(model) != nullptr;
+}
 
-        it++;
+std::string WhisperService::transcribe(const std::string& request, const float* samples, size_t n_samples, int& status) {
+    status = 400;
+    whisper_transcribe_params params;
+    try {
+        const json js = json::parse(request);
+        params.model       = js.value("model", params.model);
+        params.language    = js.value("language", params.language);
+        params.prompt      = js.value("prompt", params.prompt);
+        params.temperature = js.value("temperature", params.temperature);
+        params.translate   = js.value("translate", params.translate);
+        params.n_threads   = js.value("threads", params.n_threads);
+        params.word_timestamps = js.value("word_timestamps", params.word_timestamps);
+        params.single_segment  = js.value("single_segment", params.single_segment);
+        params.no_context      = js.value("no_context", params.no_context);
+    } catch (const std::exception& e) {
+        return error_json(std::string("invalid request: ") + e.what());
     }
-
-    if (params.fname_inp.empty()) {
-        fprintf(stderr, "error: no input files specified\n");
-        return ret;
+    if (params.language.empty()) {
+        params.language = "auto";
+    }
+    if (params.model.empty()) {
+        return error_json("model is required");
+    }
+    if (samples == nullptr || n_samples == 0) {
+        return error_json("no audio");
     }
-
     if (params.language != "auto" && whisper_lang_id(params.language.c_str()) == -1) {
-        fprintf(stderr, "error: unknown language '%s'\n", params.language.c_str());
-        return ret;
+        return error_json("unknown language '" + params.language + "'");
     }
 
-    if (params.diarize && params.tinydiarize) {
-        fprintf(stderr, "error: cannot use both --diarize and --tinydiarize\n");
-        return ret;
+    status = 500;
+    // kept alive until the transcription is done, even if another model is
+    // loaded meanwhile
+    const std::shared_ptr<whisper_context> model_ctx = context(params.model);
+    if (!model_ctx) {
+        return error_json("failed to load the whisper model");
     }
+    whisper_context * ctx = model_ctx.get();
 
-    // whisper init
-    struct whisper_context_params cparams = whisper_context_default_params();
-
-    cparams.use_gpu    = params.use_gpu;
-    cparams.flash_attn = params.flash_attn;
-
-    struct whisper_context * ctx = whisper_init_from_file_with_params(params.model.c_str(), cparams);
-
-    if (ctx == nullptr) {
-        fprintf(stderr, "error: failed to initialize whisper context\n");
-        return ret;
+    std::string language = params.language;
+    bool translate = params.translate;
+    if (!whisper_is_multilingual(ctx)) {
+        language = "en";
+        translate = false;
     }
 
-    // initialize openvino encoder. this has no effect on whisper.cpp builds that don't have OpenVINO configured
-    whisper_ctx_init_openvino_encoder(ctx, nullptr, params.openvino_encode_device.c_str(), nullptr);
-
-    for (int f = 0; f < (int) params.fname_inp.size(); ++f) {
-        const auto & fname_inp = params.fname_inp[f];
-
-        std::vector<float> pcmf32;               // mono-channel F32 PCM
-        std::vector<std::vector<float>> pcmf32s; // stereo-channel F32 PCM
-
-        if (!::read_audio_data(fname_inp, pcmf32, pcmf32s, params.diarize)) {
-            fprintf(stderr, "error: failed to read audio file '%s'\n", fname_inp.c_str());
-            continue;
-        }
-
-        if (!whisper_is_multilingual(ctx)) {
-            if (params.language != "en" || params.translate) {
-                params.language = "en";
-                params.translate = false;
-                fprintf(stderr, "%s: WARNING: model is not multilingual, ignoring language and translation options\n", __func__);
-            }
-        }
-        if (params.detect_language) {
-            params.language = "auto";
-        }
-
-        // run the inference
-        {
-            whisper_full_params wparams = whisper_full_default_params(WHISPER_SAMPLING_GREEDY);
-
-            wparams.strategy = (params.beam_size > 1) ? WHISPER_SAMPLING_BEAM_SEARCH : WHISPER_SAMPLING_GREEDY;
-
-            wparams.print_realtime   = false;
-            wparams.print_progress   = params.print_progress;
-            wparams.print_timestamps = !params.no_timestamps;
-            wparams.print_special    = params.print_special;
-            wparams.translate        = params.translate;
-            wparams.language         = params.language.c_str();
-            wparams.detect_language  = params.detect_language;
-            wparams.n_threads        = params.n_threads;
-            wparams.n_max_text_ctx   = params.max_context >= 0 ? params.max_context : wparams.n_max_text_ctx;
-            wparams.offset_ms        = params.offset_t_ms;
-            wparams.duration_ms      = params.duration_ms;
-
-            wparams.token_timestamps = params.output_wts || params.output_jsn_full || params.max_len > 0;
-            wparams.thold_pt         = params.word_thold;
-            wparams.max_len          = params.output_wts && params.max_len == 0 ? 60 : params.max_len;
-            wparams.split_on_word    = params.split_on_word;
-            wparams.audio_ctx        = params.audio_ctx;
-
-            wparams.debug_mode       = params.debug_mode;
-
-            wparams.tdrz_enable      = params.tinydiarize; // [TDRZ]
-
-            wparams.suppress_regex   = params.suppress_regex.empty() ? nullptr : params.suppress_regex.c_str();
-
-            wparams.initial_prompt   = params.prompt.c_str();
-
-            wparams.greedy.best_of        = params.best_of;
-            wparams.beam_search.beam_size = params.beam_size;
-
-            wparams.temperature_inc  = params.no_fallback ? 0.0f : params.temperature_inc;
-            wparams.temperature      = params.temperature;
-
-            wparams.entropy_thold    = params.entropy_thold;
-            wparams.logprob_thold    = params.logprob_thold;
-            wparams.no_speech_thold  = params.no_speech_thold;
-
-            wparams.no_timestamps    = params.no_timestamps;
-
-            wparams.suppress_nst     = params.suppress_nst;
-
-            wparams.vad            = params.vad;
-            wparams.vad_model_path = params.vad_model.c_str();
-
-            wparams.vad_params.threshold               = params.vad_threshold;
-            wparams.vad_params.min_speech_duration_ms  = params.vad_min_speech_duration_ms;
-            wparams.vad_params.min_silence_duration_ms = params.vad_min_silence_duration_ms;
-            wparams.vad_params.max_speech_duration_s   = params.vad_max_speech_duration_s;
-            wparams.vad_params.speech_pad_ms           = params.vad_speech_pad_ms;
-            wparams.vad_params.samples_overlap         = params.vad_samples_overlap;
-
-            whisper_print_user_data user_data = { &params, &pcmf32s, 0 ,&ret};
-
-            if (!wparams.print_realtime) {
-
-                auto whisper_print_segment_callback=[](struct whisper_context * ctx, struct whisper_state * /*state*/, int n_new, void * user_data) {
-                    std::string & ret  = *((whisper_print_user_data *) user_data)->ret;
-                    const int n_segments = whisper_full_n_segments(ctx);
-                    // print the last n_new segments
-                    const int s0 = n_segments - n_new;
-                    for (int i = s0; i < n_segments; i++) {
-                        const char * text = whisper_full_get_segment_text(ctx, i);
-                        ret.append(text);
-                    }
-                };
-
-                wparams.new_segment_callback           = whisper_print_segment_callback;
-                wparams.new_segment_callback_user_data = &user_data;
-            }
-            {
-                static bool is_aborted = false; // NOTE: this should be atomic to avoid data race
+    whisper_full_params wparams = whisper_full_default_params(WHISPER_SAMPLING_GREEDY);
+    wparams.print_realtime   = false;
+    wparams.print_progress   = false;
+    wparams.print_timestamps = false;
+    wparams.print_special    = false;
+    wparams.translate        = translate;
+    wparams.language         = language.c_str();
+    wparams.detect_language  = false;
+    wparams.n_threads        = params.n_threads > 0 ? params.n_threads : std::min(4, (int32_t) std::thread::hardware_concurrency());
+    wparams.initial_prompt   = params.prompt.c_str();
+    wparams.temperature      = params.temperature;
+    wparams.no_timestamps    = false;
+    wparams.token_timestamps = params.word_timestamps;
+    wparams.single_segment   = params.single_segment;
+    wparams.no_context       = params.no_context;
+
+    // each request decodes with a state of its own, sharing the model
+    struct whisper_state * state = whisper_init_state(ctx);
+    if (state == nullptr) {
+        return error_json("failed to initialize the whisper state");
+    }
+    if (whisper_full_with_state(ctx, state, wparams, samples, (int) n_samples) != 0) {
+        whisper_free_state(state);
+        return error_json("failed to process the audio");
+    }
 
-                wparams.encoder_begin_callback = [](struct whisper_context * /*ctx*/, struct whisper_state * /*state*/, void * user_data) {
-                    bool is_aborted = *(bool*)user_data;
-                    return !is_aborted;
-                };
-                wparams.encoder_begin_callback_user_data = &is_aborted;
+    // timestamps are in units of 10 ms
+    std::string text;
+    json segments = json::array();
+    json words = json::array();
+    const whisper_token token_eot = whisper_token_eot(ctx);
+    const int n_segments = whisper_full_n_segments_from_state(state);
+    for (int i = 0; i < n_segments; ++i) {
+        const char * segment = whisper_full_get_segment_text_from_state(state, i);
+        text += segment;
+
+        // the confidence of a segment is the mean probability of its text tokens,
+        // and a word the text tokens from one starting with a space
+        double sum_p = 0.0;
+        int n_text = 0;
+        const int n_tokens = whisper_full_n_tokens_from_state(state, i);
+        for (int j = 0; j < n_tokens; ++j) {
+            const whisper_token_data data = whisper_full_get_token_data_from_state(state, i, j);
+            if (data.id >= token_eot) {
+                continue;
             }
-            {
-                static bool is_aborted = false; // NOTE: this should be atomic to avoid data race
-
-                wparams.abort_callback = [](void * user_data) {
-                    bool is_aborted = *(bool*)user_data;
-                    return is_aborted;
-                };
-                wparams.abort_callback_user_data = &is_aborted;
+            sum_p += data.p;
+            ++n_text;
+            if (!params.word_timestamps) {
+                continue;
             }
-
-            if (whisper_full_parallel(ctx, wparams, pcmf32.data(), pcmf32.size(), params.n_processors) != 0) {
-                fprintf(stderr, "%s %s: failed to process audio\n", model.c_str(),input.c_str());
-                return ret;
+            const std::string piece = whisper_token_to_str(ctx, data.id);
+            if (words.empty() || (!piece.empty() && piece[0] == ' ')) {
+                words.push_back({
+                    {"word",        piece},
+                    {"start",       data.t0 / 100.0},
+                    {"end",         data.t1 / 100.0},
+                    {"probability", data.p},
+                    {"n",           1},
+                });
+                continue;
             }
+            json & word = words.back();
+            word["word"]        = word["word"].get<std::string>() + piece;
+            word["end"]         = data.t1 / 100.0;
+            word["probability"] = word["probability"].get<double>() + data.p;
+            word["n"]           = word["n"].get<int>() + 1;
         }
-    }
-
-    whisper_free(ctx);
 
-    return ret;
+        segments.push_back({
+            {"id",         i},
+            {"start",      whisper_full_get_segment_t0_from_state(state, i) / 100.0},
+            {"end",        whisper_full_get_segment_t1_from_state(state, i) / 100.0},
+            {"text",       segment},
+            {"confidence", n_text > 0 ? sum_p / n_text : 0.0},
+        });
+    }
+    for (json & word : words) {
+        word["word"]        = trim(word["word"].get<std::string>());
+        word["probability"] = word["probability"].get<double>() / word["n"].get<int>();
+        word.erase("n");
+    }
+    const int lang_id = whisper_full_lang_id_from_state(state);
+    whisper_free_state(state);
+
+    status = 200;
+    return json{
+        {"text",     text},
+        {"language", lang_id >= 0 ? whisper_lang_str(lang_id) : language.c_str()},
+        {"duration", n_samples / (double) WHISPER_SAMPLE_RATE},
+        {"segments", segments},
+        {"words",    words},
+    }.dump();
 }
@@ -1,12 +1,38 @@
 #pragma once
 
-#include <iostream>
-class WhisperService {
+#include "singleton.h"
+
+#include <cstddef>
+#include <memory>
+#include <mutex>
+#include <string>
+
+struct whisper_context;
+
+class WhisperService: public patterns::Singleton<WhisperService> {
+    friend class patterns::Singleton<WhisperService>;
 private:
+    std::mutex mtx;
+    // the whisper context of the model used last, loaded on first use and kept
+    // so that the requests do not reload the model. Loading another model
+    // replaces it, the transcriptions still running holding a reference.
+    std::string loaded_model;
+    std::shared_ptr<whisper_context> ctx;
+
+    WhisperService() = default;
+    ~WhisperService() = default;
+
+    std::shared_ptr<whisper_context> context(const std::string& model);
 
 public:
-    WhisperService();
-    ~WhisperService();
 
-    const std::string generate(const std::string& model,const std::string& input);
-};
\ No newline at end of file
+    // loads the context of the model ahead of the requests
+    bool load(const std::string& model);
+
+    // transcribes the 16 kHz mono samples with the JSON request {"model","language",
+    // "prompt","temperature","translate","threads","word_timestamps",
+    // "single_segment","no_context"}, returning {"text","language","duration",
+    // "segments","words"} or {"error"}, the words being empty without
+    // word_timestamps. status is set as for HTTP.
+    std::string transcribe(const std::string& request, const float* samples, size_t n_samples, int& status);
+};
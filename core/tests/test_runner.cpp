@@ -18,11 +18,10 @@ int main() {
 
     std::cout << "env: " << env_model << "=" << model << std::endl;
 
-    std::stringstream ss;
-    ss << "test_runner -m " << model << " --seed 0";
+    const char * argv[] = {"test_runner", "-m", model, "--seed", "0"};
 
     std::future<void> start = std::async(std::launch::async, [&](){
-        bool ret=llama_start(ss.str().c_str());
+        bool ret=llama_start(5, argv);
         if (!ret) {
             std::cout<<"Start Fail:"<<ret<<std::endl;
         }
@@ -26,11 +26,10 @@ int main() {
 
     std::cout << "env: " << env_model << "=" << model << std::endl;
 
-    std::stringstream ss;
-    ss << "test_runner_gen -m " << model << " --seed 0";
+    const char * argv[] = {"test_runner_gen", "-m", model, "--seed", "0"};
 
     std::future<void> start = std::async(std::launch::async, [&]() {
-        if (!llama_start(ss.str().c_str())) {
+        if (!llama_start(5, argv)) {
             std::cerr << "llama_start failed\n";
         }
     });
@@ -0,0 +1,73 @@
+// Package testutil holds the fixtures the tests of several packages share.
+package testutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+// WriteModel writes a GGUF model with the metadata kv to a temporary
+// directory and returns its path. The architecture is "test" unless kv sets
+// general.architecture.
+func WriteModel(t testing.TB, kv ggml.KV) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "model.gguf")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, ok := kv["general.architecture"]; !ok {
+		kv["general.architecture"] = "test"
+	}
+	if err := ggml.WriteGGUF(f, kv, nil); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// WAV returns a WAV of the frames of format, each sample being encoded by
+// put in width bytes.
+func WAV(format, channels, rate, width int, frames [][]float64, put func([]byte, float64)) []byte {
+	var data []byte
+	for _, f := range frames {
+		for _, v := range f {
+			b := make([]byte, width)
+			put(b, v)
+			data = append(data, b...)
+		}
+	}
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteString("RIFF")
+	binary.Write(&buf, le, uint32(4+8+16+8+4+8+len(data)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, le, uint32(16))
+	binary.Write(&buf, le, uint16(format))
+	binary.Write(&buf, le, uint16(channels))
+	binary.Write(&buf, le, uint32(rate))
+	binary.Write(&buf, le, uint32(rate*channels*width))
+	binary.Write(&buf, le, uint16(channels*width))
+	binary.Write(&buf, le, uint16(8*width))
+	// a chunk the decoder skips
+	buf.WriteString("LIST")
+	binary.Write(&buf, le, uint32(4))
+	buf.WriteString("INFO")
+	buf.WriteString("data")
+	binary.Write(&buf, le, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// PutS16 encodes v as a 16 bits sample, for WAV.
+func PutS16(b []byte, v float64) {
+	binary.LittleEndian.PutUint16(b, uint16(int16(v*(1<<15))))
+}
@@ -1,5 +1,11 @@
 package model
 
+import (
+	"slices"
+
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
 type Capability string
 
 const (
@@ -9,8 +15,82 @@ const (
 	CapabilityVision     = Capability("vision")
 	CapabilityEmbedding  = Capability("embedding")
 	CapabilityThinking   = Capability("thinking")
+	CapabilityRerank     = Capability("rerank")
 )
 
+// poolingTypeRank is the llama.cpp pooling type of the cross-encoders which
+// score query and document pairs.
+const poolingTypeRank = 4
+
 func (c Capability) String() string {
 	return string(c)
 }
+
+// fimKeys lists, for the prefix, suffix and middle tokens of fill-in-the-middle
+// prompts, the GGUF metadata keys which may hold their id.
+var fimKeys = [3][]string{
+	{"tokenizer.ggml.fim_pre_token_id", "tokenizer.ggml.prefix_token_id"},
+	{"tokenizer.ggml.fim_suf_token_id", "tokenizer.ggml.suffix_token_id"},
+	{"tokenizer.ggml.fim_mid_token_id", "tokenizer.ggml.middle_token_id"},
+}
+
+// fimTokens lists the texts llama.cpp recognizes as the prefix, suffix and
+// middle tokens of vocabularies lacking the metadata.
+var fimTokens = [3][]string{
+	{"<|fim_prefix|>", "<fim-prefix>", "<fim_prefix>", "<PRE>", "▁<PRE>", "<｜fim▁begin｜>", "<|fim▁begin|>", "<|fim_begin|>"},
+	{"<|fim_suffix|>", "<fim-suffix>", "<fim_suffix>", "<SUF>", "▁<SUF>", "<｜fim▁hole｜>", "<|fim▁hole|>", "<|fim_hole|>"},
+	{"<|fim_middle|>", "<fim-middle>", "<fim_middle>", "<MID>", "▁<MID>", "<｜fim▁end｜>", "<|fim▁end|>", "<|fim_end|>"},
+}
+
+// SupportsInsert reports whether the model at path has the prefix, suffix and
+// middle tokens required for fill-in-the-middle completions.
+func SupportsInsert(path string) bool {
+	c, err := loadGGML(path)
+	if err != nil {
+		return false
+	}
+	return c.insert
+}
+
+// hasFIMTokens reports whether kv, decoded with the vocabulary, declares the
+// fill-in-the-middle tokens or has them in its vocabulary.
+func hasFIMTokens(kv ggml.KV) bool {
+	missing := false
+	for _, keys := range fimKeys {
+		if !slices.ContainsFunc(keys, func(k string) bool { _, ok := kv[k]; return ok }) {
+			missing = true
+		}
+	}
+	if !missing {
+		return true
+	}
+
+	vocab := kv.Strings("tokenizer.ggml.tokens")
+	for _, texts := range fimTokens {
+		if !slices.ContainsFunc(vocab, func(t string) bool { return slices.Contains(texts, t) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// SupportsEmbedding reports whether the model at path declares how its
+// outputs are pooled, as the embedding models and cross-encoders do.
+func SupportsEmbedding(path string) bool {
+	g, err := LoadGGML(path)
+	if err != nil {
+		return false
+	}
+	_, ok := g.KV()[g.KV().Architecture()+".pooling_type"]
+	return ok
+}
+
+// SupportsRerank reports whether the model at path pools its outputs into a
+// relevance score, as the cross-encoders used for reranking do.
+func SupportsRerank(path string) bool {
+	g, err := LoadGGML(path)
+	if err != nil {
+		return false
+	}
+	return g.KV().Uint("pooling_type") == poolingTypeRank
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/internal/testutil"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+func TestSupportsInsert(t *testing.T) {
+	vocab := make([]string, 2048)
+	for i := range vocab {
+		vocab[i] = "tok"
+	}
+
+	cases := []struct {
+		name string
+		kv   ggml.KV
+		want bool
+	}{
+		{
+			name: "fim metadata",
+			kv: ggml.KV{
+				"tokenizer.ggml.fim_pre_token_id": uint32(1),
+				"tokenizer.ggml.fim_suf_token_id": uint32(2),
+				"tokenizer.ggml.fim_mid_token_id": uint32(3),
+			},
+			want: true,
+		},
+		{
+			name: "legacy metadata",
+			kv: ggml.KV{
+				"tokenizer.ggml.prefix_token_id": uint32(0),
+				"tokenizer.ggml.suffix_token_id": uint32(1),
+				"tokenizer.ggml.middle_token_id": uint32(2),
+			},
+			want: true,
+		},
+		{
+			name: "partial metadata",
+			kv: ggml.KV{
+				"tokenizer.ggml.fim_pre_token_id": uint32(1),
+				"tokenizer.ggml.tokens":           vocab,
+			},
+			want: false,
+		},
+		{
+			name: "vocabulary",
+			kv: ggml.KV{
+				"tokenizer.ggml.tokens": append(append([]string{}, vocab...), "<|fim_prefix|>", "<|fim_suffix|>", "<|fim_middle|>"),
+			},
+			want: true,
+		},
+		{
+			name: "no fim tokens",
+			kv:   ggml.KV{"tokenizer.ggml.tokens": vocab},
+			want: false,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SupportsInsert(testutil.WriteModel(t, tt.kv)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if SupportsInsert(filepath.Join(t.TempDir(), "missing.gguf")) {
+		t.Error("missing model should not support insert")
+	}
+}
+
+func TestSupportsEmbedding(t *testing.T) {
+	if !SupportsEmbedding(testutil.WriteModel(t, ggml.KV{"test.pooling_type": uint32(1)})) {
+		t.Error("expected a mean pooling model to support embedding")
+	}
+	if SupportsEmbedding(testutil.WriteModel(t, ggml.KV{})) {
+		t.Error("expected a model without pooling type not to support embedding")
+	}
+}
+
+func TestSupportsRerank(t *testing.T) {
+	if !SupportsRerank(testutil.WriteModel(t, ggml.KV{"test.pooling_type": uint32(poolingTypeRank)})) {
+		t.Error("expected a rank pooling model to support rerank")
+	}
+	if SupportsRerank(testutil.WriteModel(t, ggml.KV{"test.pooling_type": uint32(1)})) {
+		t.Error("expected a mean pooling model not to support rerank")
+	}
+	if SupportsRerank(testutil.WriteModel(t, ggml.KV{})) {
+		t.Error("expected a model without pooling type not to support rerank")
+	}
+}
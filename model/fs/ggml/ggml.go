@@ -238,6 +238,44 @@ func (kv KV) Bools(key string, defaultValue ...[]bool) []bool {
 	return val.values
 }
 
+// DropArrays drops the values of the arrays larger than maxArraySize, keeping
+// their length, as Decode does for those arrays.
+func (kv KV) DropArrays(maxArraySize int) {
+	for _, v := range kv {
+		if a, ok := v.(interface{ drop(int) }); ok {
+			a.drop(maxArraySize)
+		}
+	}
+}
+
+// ArrayLen returns the length of the array under key, which is known even
+// when the array is too large for its values to be decoded.
+func (kv KV) ArrayLen(key string) int {
+	if !strings.HasPrefix(key, "tokenizer.") && !strings.HasPrefix(key, "general.") {
+		key = kv.Architecture() + "." + key
+	}
+	if a, ok := kv[key].(interface{ len() int }); ok {
+		return a.len()
+	}
+	return 0
+}
+
+// CompareVocab returns an error unless kv and other have the same vocabulary
+// size and special tokens, as required of a draft model and its target.
+func (kv KV) CompareVocab(other KV) error {
+	if n, m := kv.ArrayLen("tokenizer.ggml.tokens"), other.ArrayLen("tokenizer.ggml.tokens"); n != m {
+		return fmt.Errorf("vocabulary sizes differ: %d and %d", n, m)
+	}
+	for _, key := range []string{"tokenizer.ggml.bos_token_id", "tokenizer.ggml.eos_token_id"} {
+		a, aok := keyValue(kv, key, uint32(0))
+		b, bok := keyValue(other, key, uint32(0))
+		if a != b || aok != bok {
+			return fmt.Errorf("%s differs: %d and %d", key, a, b)
+		}
+	}
+	return nil
+}
+
 func (kv KV) EngineRequired() bool {
 	return slices.Contains([]string{
 		"gemma3",
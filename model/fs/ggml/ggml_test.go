@@ -270,6 +270,43 @@ func TestKeyValue(t *testing.T) {
 	}
 }
 
+func TestCompareVocab(t *testing.T) {
+	target := KV{
+		"general.architecture":        "qwen2",
+		"tokenizer.ggml.tokens":       &array[string]{size: 151936},
+		"tokenizer.ggml.bos_token_id": uint32(151643),
+		"tokenizer.ggml.eos_token_id": uint32(151645),
+	}
+	if n := target.ArrayLen("tokenizer.ggml.tokens"); n != 151936 {
+		t.Errorf("expected the length of an undecoded array, got %d", n)
+	}
+	if n := target.ArrayLen("tokenizer.ggml.merges"); n != 0 {
+		t.Errorf("expected 0 for a missing array, got %d", n)
+	}
+
+	draft := maps.Clone(target)
+	if err := target.CompareVocab(draft); err != nil {
+		t.Errorf("expected matching vocabularies, got %v", err)
+	}
+
+	draft["tokenizer.ggml.tokens"] = &array[string]{size: 32000}
+	if err := target.CompareVocab(draft); err == nil {
+		t.Error("expected an error for a different vocabulary size")
+	}
+
+	draft = maps.Clone(target)
+	delete(draft, "tokenizer.ggml.bos_token_id")
+	if err := target.CompareVocab(draft); err == nil {
+		t.Error("expected an error for a missing BOS token")
+	}
+
+	draft = maps.Clone(target)
+	draft["tokenizer.ggml.eos_token_id"] = uint32(151643)
+	if err := target.CompareVocab(draft); err == nil {
+		t.Error("expected an error for a different EOS token")
+	}
+}
+
 func TestHeadCount(t *testing.T) {
 	valuesArray := []int32{1, 5, 3, 4}
 	cases := []struct {
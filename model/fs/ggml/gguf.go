@@ -394,6 +394,16 @@ type array[T any] struct {
 	values []T
 }
 
+func (a *array[T]) len() int {
+	return a.size
+}
+
+func (a *array[T]) drop(maxSize int) {
+	if maxSize >= 0 && a.size > maxSize {
+		a.values = nil
+	}
+}
+
 func (a *array[T]) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a.values)
 }
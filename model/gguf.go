@@ -0,0 +1,84 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+// maxCachedArraySize bounds the GGUF arrays kept in memory. Vocabulary arrays
+// are far larger than this and only their lengths are retained, along with
+// what is derived from them when the file is decoded.
+const maxCachedArraySize = 1024
+
+type cachedGGML struct {
+	modTime time.Time
+	size    int64
+	ggml    *ggml.GGML
+	// insert is set when the vocabulary has fill-in-the-middle tokens
+	insert bool
+}
+
+var (
+	ggmlMu    sync.Mutex
+	ggmlCache = make(map[string]cachedGGML)
+)
+
+// LoadGGML decodes the metadata of the GGUF file at path. Results are cached
+// per path and refreshed when the file changes on disk.
+func LoadGGML(path string) (*ggml.GGML, error) {
+	c, err := loadGGML(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.ggml, nil
+}
+
+func loadGGML(path string) (cachedGGML, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return cachedGGML{}, err
+	}
+
+	ggmlMu.Lock()
+	defer ggmlMu.Unlock()
+
+	if c, ok := ggmlCache[path]; ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cachedGGML{}, err
+	}
+	defer f.Close()
+
+	// the arrays are decoded in full for the vocabulary to be inspected, then
+	// the large ones are dropped
+	g, err := ggml.Decode(f, -1)
+	if err != nil {
+		return cachedGGML{}, err
+	}
+	c := cachedGGML{modTime: info.ModTime(), size: info.Size(), ggml: g, insert: hasFIMTokens(g.KV())}
+	g.KV().DropArrays(maxCachedArraySize)
+
+	ggmlCache[path] = c
+	return c, nil
+}
+
+// VocabSize returns the number of tokens in the vocabulary of the GGUF file at
+// path.
+func VocabSize(path string) (int, error) {
+	g, err := LoadGGML(path)
+	if err != nil {
+		return 0, err
+	}
+	n := g.KV().ArrayLen("tokenizer.ggml.tokens")
+	if n <= 0 {
+		return 0, fmt.Errorf("%s has no vocabulary", path)
+	}
+	return n, nil
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/Qitmeer/llama.go/internal/testutil"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+func TestVocabSize(t *testing.T) {
+	vocab := make([]string, 2048)
+	for i := range vocab {
+		vocab[i] = "tok"
+	}
+
+	n, err := VocabSize(testutil.WriteModel(t, ggml.KV{"tokenizer.ggml.tokens": vocab}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(vocab) {
+		t.Errorf("got %d tokens, want %d", n, len(vocab))
+	}
+
+	g, err := LoadGGML(testutil.WriteModel(t, ggml.KV{"tokenizer.ggml.tokens": vocab}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := g.KV().Strings("tokenizer.ggml.tokens"); tokens != nil {
+		t.Errorf("the vocabulary should not be kept, got %d tokens", len(tokens))
+	}
+
+	if _, err := VocabSize(testutil.WriteModel(t, ggml.KV{})); err == nil {
+		t.Error("expected an error for a model without vocabulary")
+	}
+}
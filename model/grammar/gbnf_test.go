@@ -0,0 +1,326 @@
+package grammar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// gbnf is a minimal recognizer for the GBNF emitted by this package, used to
+// check the documents accepted by generated grammars.
+type gbnf struct {
+	rules map[string]*gnode
+}
+
+const (
+	nodeLiteral = iota
+	nodeClass
+	nodeRef
+	nodeAlt
+	nodeSeq
+)
+
+type gnode struct {
+	kind     int
+	literal  []rune
+	ranges   [][2]rune
+	negate   bool
+	ref      string
+	children []*gnode
+	min, max int
+}
+
+func parseGBNF(t *testing.T, src string) *gbnf {
+	t.Helper()
+
+	g := &gbnf{rules: make(map[string]*gnode)}
+	for _, line := range strings.Split(strings.TrimSpace(src), "\n") {
+		name, body, ok := strings.Cut(line, " ::= ")
+		if !ok {
+			t.Fatalf("invalid rule %q", line)
+		}
+		p := &gbnfParser{src: []rune(body)}
+		n, err := p.alternation()
+		if err == nil && p.pos != len(p.src) {
+			err = fmt.Errorf("unexpected %q", string(p.src[p.pos:]))
+		}
+		if err != nil {
+			t.Fatalf("rule %s: %v", name, err)
+		}
+		g.rules[name] = n
+	}
+
+	for name, n := range g.rules {
+		var check func(*gnode)
+		check = func(n *gnode) {
+			if n.kind == nodeRef {
+				if _, ok := g.rules[n.ref]; !ok {
+					t.Fatalf("rule %s references undefined rule %s", name, n.ref)
+				}
+			}
+			for _, c := range n.children {
+				check(c)
+			}
+		}
+		check(n)
+	}
+	return g
+}
+
+func (g *gbnf) accepts(s string) bool {
+	in := []rune(s)
+	for _, end := range g.match(g.rules["root"], in, 0) {
+		if end == len(in) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *gbnf) match(n *gnode, in []rune, pos int) []int {
+	seen := map[int]bool{}
+	var out []int
+	cur := []int{pos}
+	for i := 0; len(cur) > 0 && (n.max < 0 || i <= n.max); i++ {
+		var next []int
+		for _, p := range cur {
+			if i >= n.min && !seen[p] {
+				seen[p] = true
+				out = append(out, p)
+			}
+			if n.max < 0 || i < n.max {
+				next = append(next, g.matchOnce(n, in, p)...)
+			}
+		}
+		if i >= n.min {
+			filtered := next[:0]
+			for _, p := range next {
+				if !seen[p] {
+					filtered = append(filtered, p)
+				}
+			}
+			next = filtered
+		}
+		cur = dedup(next)
+	}
+	return out
+}
+
+func (g *gbnf) matchOnce(n *gnode, in []rune, pos int) []int {
+	switch n.kind {
+	case nodeLiteral:
+		if pos+len(n.literal) <= len(in) && string(in[pos:pos+len(n.literal)]) == string(n.literal) {
+			return []int{pos + len(n.literal)}
+		}
+	case nodeClass:
+		if pos < len(in) && n.matchesRune(in[pos]) {
+			return []int{pos + 1}
+		}
+	case nodeRef:
+		return g.match(g.rules[n.ref], in, pos)
+	case nodeAlt:
+		var out []int
+		for _, c := range n.children {
+			out = append(out, g.match(c, in, pos)...)
+		}
+		return dedup(out)
+	case nodeSeq:
+		cur := []int{pos}
+		for _, c := range n.children {
+			var next []int
+			for _, p := range cur {
+				next = append(next, g.match(c, in, p)...)
+			}
+			cur = dedup(next)
+		}
+		return cur
+	}
+	return nil
+}
+
+func (n *gnode) matchesRune(r rune) bool {
+	for _, rg := range n.ranges {
+		if r >= rg[0] && r <= rg[1] {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+
+func dedup(in []int) []int {
+	seen := map[int]bool{}
+	out := in[:0:0]
+	for _, p := range in {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+type gbnfParser struct {
+	src []rune
+	pos int
+}
+
+func (p *gbnfParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *gbnfParser) alternation() (*gnode, error) {
+	alt := &gnode{kind: nodeAlt, min: 1, max: 1}
+	for {
+		seq, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		alt.children = append(alt.children, seq)
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '|' {
+			p.pos++
+			continue
+		}
+		return alt, nil
+	}
+}
+
+func (p *gbnfParser) sequence() (*gnode, error) {
+	seq := &gnode{kind: nodeSeq, min: 1, max: 1}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] == '|' || p.src[p.pos] == ')' {
+			return seq, nil
+		}
+
+		var n *gnode
+		var err error
+		switch r := p.src[p.pos]; {
+		case r == '"':
+			n, err = p.literal()
+		case r == '[':
+			n, err = p.class()
+		case r == '(':
+			p.pos++
+			if n, err = p.alternation(); err == nil {
+				if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+					err = fmt.Errorf("missing ) at %d", p.pos)
+				}
+				p.pos++
+			}
+		case r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9':
+			start := p.pos
+			for p.pos < len(p.src) && (p.src[p.pos] == '-' || p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] >= 'A' && p.src[p.pos] <= 'Z' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+				p.pos++
+			}
+			n = &gnode{kind: nodeRef, ref: string(p.src[start:p.pos])}
+		default:
+			err = fmt.Errorf("unexpected %q at %d", r, p.pos)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		n.min, n.max = 1, 1
+		if p.pos < len(p.src) {
+			switch p.src[p.pos] {
+			case '*':
+				n.min, n.max = 0, -1
+				p.pos++
+			case '+':
+				n.min, n.max = 1, -1
+				p.pos++
+			case '?':
+				n.min, n.max = 0, 1
+				p.pos++
+			case '{':
+				end := p.pos
+				for p.src[end] != '}' {
+					end++
+				}
+				lo, hi, found := strings.Cut(string(p.src[p.pos+1:end]), ",")
+				n.min, _ = strconv.Atoi(lo)
+				n.max = n.min
+				if found {
+					n.max = -1
+					if hi != "" {
+						n.max, _ = strconv.Atoi(hi)
+					}
+				}
+				p.pos = end + 1
+			}
+		}
+		seq.children = append(seq.children, n)
+	}
+}
+
+func (p *gbnfParser) char() (rune, error) {
+	r := p.src[p.pos]
+	p.pos++
+	if r != '\\' {
+		return r, nil
+	}
+	r = p.src[p.pos]
+	p.pos++
+	switch r {
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'x':
+		v, err := strconv.ParseUint(string(p.src[p.pos:p.pos+2]), 16, 32)
+		p.pos += 2
+		return rune(v), err
+	}
+	return r, nil
+}
+
+func (p *gbnfParser) literal() (*gnode, error) {
+	p.pos++
+	n := &gnode{kind: nodeLiteral}
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		r, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		n.literal = append(n.literal, r)
+	}
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("unterminated literal")
+	}
+	p.pos++
+	return n, nil
+}
+
+func (p *gbnfParser) class() (*gnode, error) {
+	p.pos++
+	n := &gnode{kind: nodeClass}
+	if p.src[p.pos] == '^' {
+		n.negate = true
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] != ']' {
+		lo, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+			p.pos++
+			if hi, err = p.char(); err != nil {
+				return nil, err
+			}
+		}
+		n.ranges = append(n.ranges, [2]rune{lo, hi})
+	}
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("unterminated class")
+	}
+	p.pos++
+	return n, nil
+}
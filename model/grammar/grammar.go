@@ -0,0 +1,645 @@
+// Package grammar converts JSON schemas into GBNF grammars understood by the
+// llama.cpp sampler, so that structured outputs can be enforced by the core.
+package grammar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type primitive struct {
+	body string
+	deps []string
+}
+
+var primitives = map[string]primitive{
+	"space":         {`| " " | "\n" [ \t]{0,20}`, nil},
+	"boolean":       {`("true" | "false") space`, []string{"space"}},
+	"null":          {`"null" space`, []string{"space"}},
+	"integral-part": {`[0] | [1-9] [0-9]{0,15}`, nil},
+	"decimal-part":  {`[0-9]{1,16}`, nil},
+	"integer":       {`("-"? integral-part) space`, []string{"integral-part", "space"}},
+	"number":        {`("-"? integral-part) ("." decimal-part)? ([eE] [-+]? integral-part)? space`, []string{"integral-part", "decimal-part", "space"}},
+	"char":          {`[^"\\\x7F\x00-\x1F] | [\\] (["\\bfnrt] | "u" [0-9a-fA-F]{4})`, nil},
+	"string":        {`"\"" char* "\"" space`, []string{"char", "space"}},
+	"value":         {`object | array | string | number | boolean | null`, []string{"object", "array", "string", "number", "boolean", "null"}},
+	"object":        {`"{" space ( string ":" space value ( "," space string ":" space value )* )? "}" space`, []string{"string", "value", "space"}},
+	"array":         {`"[" space ( value ( "," space value )* )? "]" space`, []string{"value", "space"}},
+	"date":          {`[0-9]{4} "-" ( "0" [1-9] | "1" [0-2] ) "-" ( "0" [1-9] | [1-2] [0-9] | "3" [0-1] )`, nil},
+	"time":          {`( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] ":" [0-5] [0-9] ( "." [0-9]{1,9} )? ( "Z" | [+-] ( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] )`, nil},
+	"date-time":     {`date "T" time`, []string{"date", "time"}},
+	"uuid":          {`[0-9a-fA-F]{8} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{12}`, nil},
+}
+
+// formats maps supported string formats to the primitive matching them.
+var formats = map[string]string{
+	"date":      "date",
+	"time":      "time",
+	"date-time": "date-time",
+	"uuid":      "uuid",
+}
+
+var invalidRuleChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// JSON is the grammar used for format "json", matching any JSON object.
+var JSON = func() string {
+	g, err := FromSchema([]byte(`{"type":"object"}`))
+	if err != nil {
+		panic(err)
+	}
+	return g
+}()
+
+// FromFormat returns the grammar for the format field of a request: "json"
+// for any JSON object or a JSON schema. An empty format yields no grammar.
+func FromFormat(format json.RawMessage) (string, error) {
+	format = bytes.TrimSpace(format)
+	if len(format) == 0 || bytes.Equal(format, []byte("null")) {
+		return "", nil
+	}
+
+	if format[0] == '"' {
+		var s string
+		if err := json.Unmarshal(format, &s); err != nil {
+			return "", err
+		}
+		switch s {
+		case "":
+			return "", nil
+		case "json":
+			return JSON, nil
+		}
+		return "", fmt.Errorf("invalid format: %q; expected \"json\" or a valid JSON Schema", s)
+	}
+
+	return FromSchema(format)
+}
+
+// FromSchema converts a JSON schema into a GBNF grammar whose root rule
+// matches the JSON documents satisfying the schema.
+func FromSchema(schema []byte) (string, error) {
+	root, err := decode(schema)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	c := &converter{root: root, rules: make(map[string]string), refs: make(map[string]string)}
+	c.reserve("root")
+	body, err := c.visit(root, "root")
+	if err != nil {
+		return "", err
+	}
+	c.rules["root"] = body
+
+	names := make([]string, 0, len(c.rules))
+	for name := range c.rules {
+		if name != "root" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var sb strings.Builder
+	for _, name := range append([]string{"root"}, names...) {
+		fmt.Fprintf(&sb, "%s ::= %s\n", name, c.rules[name])
+	}
+	return sb.String(), nil
+}
+
+type converter struct {
+	root  any
+	rules map[string]string
+	// refs maps resolved $ref values to the rule implementing them
+	refs map[string]string
+}
+
+// primitive adds the named builtin rule along with its dependencies.
+func (c *converter) primitive(name string) string {
+	if _, ok := c.rules[name]; ok {
+		return name
+	}
+	p := primitives[name]
+	c.rules[name] = p.body
+	for _, dep := range p.deps {
+		c.primitive(dep)
+	}
+	return name
+}
+
+// add stores a rule under a name derived from name, reusing an existing rule
+// with the same body and renaming on conflicts.
+func (c *converter) add(name, body string) string {
+	base := invalidRuleChars.ReplaceAllString(name, "-")
+	key := base
+	for i := 0; ; i++ {
+		existing, taken := c.rules[key]
+		if p, ok := primitives[key]; ok {
+			existing, taken = p.body, true
+		}
+		if !taken {
+			c.rules[key] = body
+			return key
+		}
+		if existing == body {
+			if _, ok := primitives[key]; ok {
+				c.primitive(key)
+			}
+			return key
+		}
+		key = base + strconv.Itoa(i)
+	}
+}
+
+// reserve claims a free rule name whose body is filled in later, which allows
+// recursive references.
+func (c *converter) reserve(name string) string {
+	base := invalidRuleChars.ReplaceAllString(name, "-")
+	key := base
+	for i := 0; ; i++ {
+		_, taken := c.rules[key]
+		if _, ok := primitives[key]; !ok && !taken {
+			c.rules[key] = ""
+			return key
+		}
+		key = base + strconv.Itoa(i)
+	}
+}
+
+// rule converts schema into a named rule and returns its name.
+func (c *converter) rule(name string, schema any) (string, error) {
+	body, err := c.visit(schema, name)
+	if err != nil {
+		return "", err
+	}
+	return c.add(name, body), nil
+}
+
+// visit returns a GBNF expression matching schema.
+func (c *converter) visit(schema any, name string) (string, error) {
+	var s *object
+	switch v := schema.(type) {
+	case bool:
+		if !v {
+			return "", errors.New("schema false matches nothing")
+		}
+		return c.primitive("value"), nil
+	case *object:
+		s = v
+	default:
+		return "", fmt.Errorf("invalid schema %s", encode(schema))
+	}
+
+	if ref, ok := s.get("$ref").(string); ok {
+		return c.ref(ref)
+	}
+
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if s.has(key) {
+			alts, ok := s.get(key).([]any)
+			if !ok || len(alts) == 0 {
+				return "", fmt.Errorf("%s must be a non-empty array", key)
+			}
+			return c.alternatives(name, alts)
+		}
+	}
+
+	if s.has("allOf") {
+		merged, err := c.allOf(s)
+		if err != nil {
+			return "", err
+		}
+		return c.visit(merged, name)
+	}
+
+	if s.has("const") {
+		return literal(encode(s.get("const"))) + " " + c.primitive("space"), nil
+	}
+
+	if s.has("enum") {
+		values, ok := s.get("enum").([]any)
+		if !ok || len(values) == 0 {
+			return "", errors.New("enum must be a non-empty array")
+		}
+		c.primitive("space")
+		alts := make([]string, len(values))
+		for i, v := range values {
+			alts[i] = literal(encode(v))
+		}
+		return "(" + strings.Join(alts, " | ") + ") space", nil
+	}
+
+	typ := s.get("type")
+	if types, ok := typ.([]any); ok {
+		alts := make([]any, len(types))
+		for i, t := range types {
+			alts[i] = s.with("type", t)
+		}
+		return c.alternatives(name, alts)
+	}
+
+	if typ == nil {
+		switch {
+		case s.has("properties") || s.has("additionalProperties") || s.has("required"):
+			typ = "object"
+		case s.has("items") || s.has("prefixItems"):
+			typ = "array"
+		case s.has("pattern") || s.has("format") || s.has("minLength") || s.has("maxLength"):
+			typ = "string"
+		default:
+			return c.primitive("value"), nil
+		}
+	}
+
+	switch typ {
+	case "object":
+		return c.object(s, name)
+	case "array":
+		return c.array(s, name)
+	case "string":
+		return c.string(s, name)
+	case "number", "integer", "boolean", "null":
+		return c.primitive(typ.(string)), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", encode(typ))
+}
+
+func (c *converter) alternatives(name string, schemas []any) (string, error) {
+	rules := make([]string, len(schemas))
+	for i, schema := range schemas {
+		rule, err := c.rule(fmt.Sprintf("%s-%d", name, i), schema)
+		if err != nil {
+			return "", err
+		}
+		rules[i] = rule
+	}
+	return strings.Join(rules, " | "), nil
+}
+
+// ref resolves a local JSON pointer such as #/$defs/Item.
+func (c *converter) ref(ref string) (string, error) {
+	if rule, ok := c.refs[ref]; ok {
+		return rule, nil
+	}
+
+	if !strings.HasPrefix(ref, "#") {
+		return "", fmt.Errorf("unsupported $ref %q: only local references are supported", ref)
+	}
+
+	target, err := c.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+
+	name := "ref"
+	if i := strings.LastIndexByte(ref, '/'); i >= 0 && i < len(ref)-1 {
+		name = ref[i+1:]
+	}
+
+	rule := c.reserve(name)
+	c.refs[ref] = rule
+	body, err := c.visit(target, rule)
+	if err != nil {
+		return "", err
+	}
+	c.rules[rule] = body
+	return rule, nil
+}
+
+func (c *converter) resolve(ref string) (any, error) {
+	target := c.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return target, nil
+	}
+
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		switch t := target.(type) {
+		case *object:
+			if !t.has(part) {
+				return nil, fmt.Errorf("unresolved $ref %q", ref)
+			}
+			target = t.get(part)
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, fmt.Errorf("unresolved $ref %q", ref)
+			}
+			target = t[i]
+		default:
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return target, nil
+}
+
+// allOf merges the object schemas of an allOf into a single object schema.
+func (c *converter) allOf(s *object) (any, error) {
+	members, ok := s.get("allOf").([]any)
+	if !ok || len(members) == 0 {
+		return nil, errors.New("allOf must be a non-empty array")
+	}
+	if len(members) == 1 {
+		return members[0], nil
+	}
+
+	properties := &object{values: make(map[string]any)}
+	var required []any
+	for _, member := range members {
+		for {
+			m, ok := member.(*object)
+			if !ok {
+				return nil, errors.New("allOf is only supported for object schemas")
+			}
+			ref, ok := m.get("$ref").(string)
+			if !ok {
+				break
+			}
+			target, err := c.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			member = target
+		}
+
+		m := member.(*object)
+		if props, ok := m.get("properties").(*object); ok {
+			for _, k := range props.keys {
+				properties = properties.with(k, props.get(k))
+			}
+		} else if t := m.get("type"); t != nil && t != "object" {
+			return nil, errors.New("allOf is only supported for object schemas")
+		}
+		if r, ok := m.get("required").([]any); ok {
+			required = append(required, r...)
+		}
+	}
+
+	return (&object{values: map[string]any{}}).
+		with("type", "object").
+		with("properties", properties).
+		with("required", required), nil
+}
+
+func (c *converter) object(s *object, name string) (string, error) {
+	properties, _ := s.get("properties").(*object)
+	if s.has("properties") && properties == nil {
+		return "", errors.New("properties must be an object")
+	}
+
+	required := make(map[string]bool)
+	if s.has("required") {
+		r, ok := s.get("required").([]any)
+		if !ok {
+			return "", errors.New("required must be an array")
+		}
+		for _, k := range r {
+			key, ok := k.(string)
+			if !ok {
+				return "", errors.New("required must be an array of strings")
+			}
+			required[key] = true
+		}
+	}
+
+	// additional properties are only allowed when requested, or when the
+	// schema doesn't describe any property at all
+	additional := s.get("additionalProperties")
+	if additional == nil && (properties == nil || len(properties.keys) == 0) {
+		additional = true
+	}
+	if b, ok := additional.(bool); ok && !b {
+		additional = nil
+	}
+
+	if properties == nil || len(properties.keys) == 0 {
+		if additional == nil {
+			return `"{" space "}" space`, nil
+		}
+		if additional == true {
+			return c.primitive("object"), nil
+		}
+	}
+
+	c.primitive("space")
+
+	var req, opt []string
+	if properties != nil {
+		for _, key := range properties.keys {
+			value, err := c.rule(name+"-"+key, properties.get(key))
+			if err != nil {
+				return "", err
+			}
+			kv := c.add(name+"-"+key+"-kv", literal(quote(key))+` space ":" space `+value)
+			if required[key] {
+				req = append(req, kv)
+			} else {
+				opt = append(opt, kv)
+			}
+		}
+	}
+
+	if additional != nil {
+		value, err := c.rule(name+"-additional-value", additional)
+		if err != nil {
+			return "", err
+		}
+		c.primitive("string")
+		kv := c.add(name+"-additional-kv", `string ":" space `+value)
+		opt = append(opt, c.add(name+"-additional-kvs", kv+` ( "," space `+kv+` )*`))
+	}
+
+	parts := []string{`"{" space`}
+	for i, kv := range req {
+		if i > 0 {
+			parts = append(parts, `"," space`)
+		}
+		parts = append(parts, kv)
+	}
+
+	if len(opt) > 0 {
+		// rest-i matches an ordered subset of the optional properties
+		// starting at index i, without repeating any of them
+		rests := make([]string, len(opt))
+		for i := len(opt) - 1; i >= 0; i-- {
+			alts := make([]string, 0, len(opt)-i)
+			for k := i; k < len(opt); k++ {
+				alt := opt[k]
+				if k+1 < len(opt) {
+					alt += ` ( "," space ` + rests[k+1] + ` )?`
+				}
+				alts = append(alts, alt)
+			}
+			rests[i] = c.add(fmt.Sprintf("%s-rest-%d", name, i), strings.Join(alts, " | "))
+		}
+
+		if len(req) > 0 {
+			parts = append(parts, `( "," space `+rests[0]+` )?`)
+		} else {
+			parts = append(parts, `( `+rests[0]+` )?`)
+		}
+	}
+
+	parts = append(parts, `"}" space`)
+	return strings.Join(parts, " "), nil
+}
+
+func (c *converter) array(s *object, name string) (string, error) {
+	c.primitive("space")
+
+	if prefix, ok := s.get("prefixItems").([]any); ok {
+		parts := []string{`"[" space`}
+		for i, item := range prefix {
+			rule, err := c.rule(fmt.Sprintf("%s-tuple-%d", name, i), item)
+			if err != nil {
+				return "", err
+			}
+			if i > 0 {
+				parts = append(parts, `"," space`)
+			}
+			parts = append(parts, rule)
+		}
+		parts = append(parts, `"]" space`)
+		return strings.Join(parts, " "), nil
+	}
+
+	var items any = true
+	if s.has("items") {
+		items = s.get("items")
+	}
+	item, err := c.rule(name+"-item", items)
+	if err != nil {
+		return "", err
+	}
+
+	minItems, _, err := intValue(s.get("minItems"))
+	if err != nil {
+		return "", fmt.Errorf("minItems: %w", err)
+	}
+	maxItems, hasMax, err := intValue(s.get("maxItems"))
+	if err != nil {
+		return "", fmt.Errorf("maxItems: %w", err)
+	}
+	if !hasMax {
+		maxItems = -1
+	} else if maxItems < minItems {
+		return "", fmt.Errorf("maxItems %d is less than minItems %d", maxItems, minItems)
+	}
+
+	if maxItems == 0 {
+		return `"[" space "]" space`, nil
+	}
+
+	rest := ""
+	if maxItems < 0 {
+		rest = repeat(`"," space `+item, max(minItems-1, 0), -1)
+	} else {
+		rest = repeat(`"," space `+item, max(minItems-1, 0), maxItems-1)
+	}
+
+	elements := item
+	if rest != "" {
+		elements += " " + rest
+	}
+	if minItems == 0 {
+		elements = "( " + elements + " )?"
+	}
+	return `"[" space ` + elements + ` "]" space`, nil
+}
+
+func (c *converter) string(s *object, name string) (string, error) {
+	c.primitive("space")
+
+	if pattern, ok := s.get("pattern").(string); ok {
+		expr, err := c.pattern(pattern)
+		if err != nil {
+			return "", fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		rule := c.add(name+"-pattern", expr)
+		return `"\"" ` + rule + ` "\"" space`, nil
+	}
+
+	if format, ok := s.get("format").(string); ok {
+		if p, ok := formats[format]; ok {
+			return `"\"" ` + c.primitive(p) + ` "\"" space`, nil
+		}
+	}
+
+	minLength, _, err := intValue(s.get("minLength"))
+	if err != nil {
+		return "", fmt.Errorf("minLength: %w", err)
+	}
+	maxLength, hasMax, err := intValue(s.get("maxLength"))
+	if err != nil {
+		return "", fmt.Errorf("maxLength: %w", err)
+	}
+	if !hasMax {
+		if minLength == 0 {
+			return c.primitive("string"), nil
+		}
+		maxLength = -1
+	} else if maxLength < minLength {
+		return "", fmt.Errorf("maxLength %d is less than minLength %d", maxLength, minLength)
+	}
+
+	return `"\"" ` + repeat(c.primitive("char"), minLength, maxLength) + ` "\"" space`, nil
+}
+
+// repeat applies a repetition of min to max (-1 for unbounded) times to expr.
+func repeat(expr string, min, max int) string {
+	if max == 0 {
+		return ""
+	}
+	if min == 1 && max == 1 {
+		return expr
+	}
+
+	if strings.ContainsAny(expr, " |") {
+		expr = "( " + expr + " )"
+	}
+
+	switch {
+	case min == 0 && max < 0:
+		return expr + "*"
+	case min == 1 && max < 0:
+		return expr + "+"
+	case min == 0 && max == 1:
+		return expr + "?"
+	case max < 0:
+		return fmt.Sprintf("%s{%d,}", expr, min)
+	case min == max:
+		return fmt.Sprintf("%s{%d}", expr, min)
+	}
+	return fmt.Sprintf("%s{%d,%d}", expr, min, max)
+}
+
+// literal quotes s as a GBNF string literal.
+func literal(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
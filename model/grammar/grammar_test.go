@@ -0,0 +1,267 @@
+package grammar
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFromSchema(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		accept []string
+		reject []string
+	}{
+		{
+			name:   "required and optional properties",
+			schema: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"},"email":{"type":"string"}},"required":["name"]}`,
+			accept: []string{
+				`{"name":"bob"}`,
+				`{"name": "bob", "age": 42}`,
+				`{"name":"bob","email":"b@example.com"}`,
+				`{"name":"bob","age":1,"email":"x"}`,
+			},
+			reject: []string{
+				`{}`,
+				`{"age":42}`,
+				`{"name":"bob","age":"42"}`,
+				`{"name":"bob","age":1,"age":2}`,
+				`{"name":"bob","email":"x","age":1}`,
+				`{"name":"bob","other":1}`,
+			},
+		},
+		{
+			name:   "only optional properties",
+			schema: `{"type":"object","properties":{"a":{"type":"boolean"},"b":{"type":"null"}}}`,
+			accept: []string{`{}`, `{"a":true}`, `{"b":null}`, `{"a":false,"b":null}`},
+			reject: []string{`{"b":null,"a":true}`, `{"a":true,}`, `{,"b":null}`},
+		},
+		{
+			name:   "additional properties",
+			schema: `{"type":"object","properties":{"id":{"type":"integer"}},"required":["id"],"additionalProperties":{"type":"number"}}`,
+			accept: []string{`{"id":1}`, `{"id":1,"x":1.5,"y":-2e3}`},
+			reject: []string{`{"id":1,"x":"1"}`},
+		},
+		{
+			name:   "free-form object",
+			schema: `{"type":"object"}`,
+			accept: []string{`{}`, `{"a":[1,{"b":null}],"c":"d"}`},
+			reject: []string{`[]`, `"a"`, `{"a"}`},
+		},
+		{
+			name:   "nested objects and arrays",
+			schema: `{"type":"object","properties":{"tags":{"type":"array","items":{"type":"string"}},"point":{"type":"object","properties":{"x":{"type":"number"},"y":{"type":"number"}},"required":["x","y"]}},"required":["tags","point"]}`,
+			accept: []string{
+				`{"tags":[],"point":{"x":1,"y":2.5}}`,
+				`{"tags":["a","b"],"point":{"x":-1,"y":0}}`,
+			},
+			reject: []string{
+				`{"tags":[1],"point":{"x":1,"y":2}}`,
+				`{"tags":[],"point":{"x":1}}`,
+			},
+		},
+		{
+			name:   "array bounds",
+			schema: `{"type":"array","items":{"type":"integer"},"minItems":1,"maxItems":3}`,
+			accept: []string{`[1]`, `[1,2]`, `[1, 2, 3]`},
+			reject: []string{`[]`, `[1,2,3,4]`, `[1.5]`},
+		},
+		{
+			name:   "tuple",
+			schema: `{"type":"array","prefixItems":[{"type":"string"},{"type":"integer"}]}`,
+			accept: []string{`["a",1]`},
+			reject: []string{`["a"]`, `[1,"a"]`, `["a",1,2]`},
+		},
+		{
+			name:   "enum",
+			schema: `{"type":"object","properties":{"color":{"enum":["red","green",null,3]}},"required":["color"]}`,
+			accept: []string{`{"color":"red"}`, `{"color":"green"}`, `{"color":null}`, `{"color":3}`},
+			reject: []string{`{"color":"blue"}`, `{"color":"Red"}`},
+		},
+		{
+			name:   "const",
+			schema: `{"const":{"kind":"point","at":[1,2]}}`,
+			accept: []string{`{"kind":"point","at":[1,2]}`},
+			reject: []string{`{"kind":"point","at":[2,1]}`, `{"at":[1,2],"kind":"point"}`},
+		},
+		{
+			name:   "nullable type list",
+			schema: `{"type":["string","null"]}`,
+			accept: []string{`"a"`, `null`},
+			reject: []string{`1`, `true`},
+		},
+		{
+			name:   "anyOf",
+			schema: `{"anyOf":[{"type":"integer"},{"type":"object","properties":{"n":{"type":"integer"}},"required":["n"]}]}`,
+			accept: []string{`1`, `{"n":1}`},
+			reject: []string{`"1"`, `{}`},
+		},
+		{
+			name:   "anchored pattern",
+			schema: `{"type":"string","pattern":"^[A-Z]{2}-\\d{3,4}$"}`,
+			accept: []string{`"AB-123"`, `"XY-0000"`},
+			reject: []string{`"ab-123"`, `"AB-12"`, `"AB-12345"`, `"xAB-123"`},
+		},
+		{
+			name:   "pattern with groups and alternation",
+			schema: `{"type":"string","pattern":"^(?:foo|ba[rz])+(-[a-f0-9]+)?$"}`,
+			accept: []string{`"foo"`, `"barbaz"`, `"foo-dead01"`},
+			reject: []string{`"bay"`, `"foo-"`, `""`},
+		},
+		{
+			name:   "unanchored pattern",
+			schema: `{"type":"string","pattern":"@"}`,
+			accept: []string{`"a@b"`, `"@"`},
+			reject: []string{`"ab"`},
+		},
+		{
+			name:   "pattern with characters escaped in JSON",
+			schema: `{"type":"string","pattern":"^a\"[\\\\b]$"}`,
+			accept: []string{`"a\"\\"`, `"a\"b"`},
+			reject: []string{`"a\"c"`},
+		},
+		{
+			name:   "string length",
+			schema: `{"type":"string","minLength":2,"maxLength":3}`,
+			accept: []string{`"ab"`, `"a\nc"`},
+			reject: []string{`"a"`, `"abcd"`},
+		},
+		{
+			name:   "string formats",
+			schema: `{"type":"object","properties":{"id":{"type":"string","format":"uuid"},"day":{"type":"string","format":"date"}},"required":["id","day"]}`,
+			accept: []string{`{"id":"123e4567-e89b-12d3-a456-426614174000","day":"2024-02-29"}`},
+			reject: []string{`{"id":"123","day":"2024-02-29"}`, `{"id":"123e4567-e89b-12d3-a456-426614174000","day":"2024-13-01"}`},
+		},
+		{
+			name:   "$defs reference",
+			schema: `{"type":"object","properties":{"home":{"$ref":"#/$defs/Address"},"work":{"$ref":"#/$defs/Address"}},"required":["home"],"$defs":{"Address":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}`,
+			accept: []string{`{"home":{"city":"a"}}`, `{"home":{"city":"a"},"work":{"city":"b"}}`},
+			reject: []string{`{"home":{}}`, `{"home":{"city":"a"},"work":{}}`},
+		},
+		{
+			name:   "recursive reference",
+			schema: `{"$ref":"#/definitions/Node","definitions":{"Node":{"type":"object","properties":{"value":{"type":"integer"},"children":{"type":"array","items":{"$ref":"#/definitions/Node"}}},"required":["value"]}}}`,
+			accept: []string{`{"value":1}`, `{"value":1,"children":[{"value":2,"children":[{"value":3}]}]}`},
+			reject: []string{`{"value":1,"children":[{}]}`},
+		},
+		{
+			name:   "allOf",
+			schema: `{"allOf":[{"$ref":"#/$defs/Base"},{"properties":{"b":{"type":"integer"}},"required":["b"]}],"$defs":{"Base":{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}}}`,
+			accept: []string{`{"a":"x","b":1}`},
+			reject: []string{`{"a":"x"}`, `{"b":1}`},
+		},
+		{
+			name:   "properties named like primitives",
+			schema: `{"type":"object","properties":{"string":{"type":"integer"},"value":{"type":"boolean"}},"required":["string","value"]}`,
+			accept: []string{`{"string":1,"value":true}`},
+			reject: []string{`{"string":"1","value":true}`},
+		},
+		{
+			name:   "empty schema",
+			schema: `{}`,
+			accept: []string{`1`, `"a"`, `[true]`, `{"a":null}`},
+			reject: []string{`nul`, `[1,]`},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := FromSchema([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := parseGBNF(t, s)
+			for _, in := range tt.accept {
+				if !g.accepts(in) {
+					t.Errorf("expected %s to be accepted by\n%s", in, s)
+				}
+			}
+			for _, in := range tt.reject {
+				if g.accepts(in) {
+					t.Errorf("expected %s to be rejected by\n%s", in, s)
+				}
+			}
+		})
+	}
+}
+
+func TestFromSchemaOutput(t *testing.T) {
+	s, err := FromSchema([]byte(`{"type":"object","properties":{"b":{"type":"boolean"},"a":{"type":"integer"}},"required":["b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `root ::= "{" space root-b-kv ( "," space root-rest-0 )? "}" space
+boolean ::= ("true" | "false") space
+integer ::= ("-"? integral-part) space
+integral-part ::= [0] | [1-9] [0-9]{0,15}
+root-a ::= integer
+root-a-kv ::= "\"a\"" space ":" space root-a
+root-b ::= boolean
+root-b-kv ::= "\"b\"" space ":" space root-b
+root-rest-0 ::= root-a-kv
+space ::= | " " | "\n" [ \t]{0,20}
+`
+	if s != want {
+		t.Errorf("got\n%s\nwant\n%s", s, want)
+	}
+}
+
+func TestFromSchemaErrors(t *testing.T) {
+	cases := map[string]string{
+		"invalid json":         `{"type":`,
+		"unknown type":         `{"type":"date"}`,
+		"missing ref":          `{"$ref":"#/$defs/Missing"}`,
+		"remote ref":           `{"$ref":"https://example.com/schema.json"}`,
+		"empty enum":           `{"enum":[]}`,
+		"false schema":         `false`,
+		"invalid bounds":       `{"type":"array","minItems":3,"maxItems":1}`,
+		"unbalanced pattern":   `{"type":"string","pattern":"^(a$"}`,
+		"lookahead pattern":    `{"type":"string","pattern":"^(?=a)a$"}`,
+		"backreference":        `{"type":"string","pattern":"^(a)\\1$"}`,
+		"nothing to repeat":    `{"type":"string","pattern":"^*$"}`,
+		"negative min":         `{"type":"string","minLength":-1}`,
+		"required not strings": `{"type":"object","required":[1]}`,
+	}
+
+	for name, schema := range cases {
+		t.Run(name, func(t *testing.T) {
+			if s, err := FromSchema([]byte(schema)); err == nil {
+				t.Errorf("expected an error, got\n%s", s)
+			}
+		})
+	}
+}
+
+func TestFromFormat(t *testing.T) {
+	for _, format := range []string{``, `null`, `""`} {
+		if s, err := FromFormat(json.RawMessage(format)); err != nil || s != "" {
+			t.Errorf("format %q: got (%q, %v), want no grammar", format, s, err)
+		}
+	}
+
+	s, err := FromFormat(json.RawMessage(`"json"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != JSON {
+		t.Errorf("format json: got\n%s\nwant\n%s", s, JSON)
+	}
+	g := parseGBNF(t, s)
+	if !g.accepts(`{"a":[1,2]}`) || g.accepts(`[1,2]`) {
+		t.Errorf("format json should only accept objects:\n%s", s)
+	}
+
+	s, err = FromFormat(json.RawMessage(`{"type":"object","properties":{"n":{"type":"integer"}},"required":["n"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, "root ::= ") || !parseGBNF(t, s).accepts(`{"n":1}`) {
+		t.Errorf("unexpected grammar for schema format:\n%s", s)
+	}
+
+	if _, err := FromFormat(json.RawMessage(`"yaml"`)); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
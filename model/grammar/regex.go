@@ -0,0 +1,388 @@
+package grammar
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pattern translates a regular expression constraining a JSON string into a
+// GBNF expression matching the string's contents. Only the common subset of
+// ECMA 262 used in schemas is supported: literals, escapes, character
+// classes, groups, alternation and quantifiers.
+func (c *converter) pattern(pattern string) (string, error) {
+	src := []rune(pattern)
+
+	anchoredStart := len(src) > 0 && src[0] == '^'
+	if anchoredStart {
+		src = src[1:]
+	}
+	anchoredEnd := len(src) > 0 && src[len(src)-1] == '$' && (len(src) < 2 || src[len(src)-2] != '\\')
+	if anchoredEnd {
+		src = src[:len(src)-1]
+	}
+
+	p := &regexParser{src: src, char: c.primitive("char")}
+	expr, err := p.alternation()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.src) {
+		return "", fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+	}
+
+	// patterns are not implicitly anchored in JSON schema
+	if !anchoredStart {
+		expr = p.char + "* " + expr
+	}
+	if !anchoredEnd {
+		expr += " " + p.char + "*"
+	}
+	return expr, nil
+}
+
+type regexParser struct {
+	src  []rune
+	pos  int
+	char string
+}
+
+func (p *regexParser) peek() (rune, bool) {
+	if p.pos < len(p.src) {
+		return p.src[p.pos], true
+	}
+	return 0, false
+}
+
+func (p *regexParser) alternation() (string, error) {
+	var alts []string
+	for {
+		seq, err := p.sequence()
+		if err != nil {
+			return "", err
+		}
+		alts = append(alts, seq)
+
+		if r, ok := p.peek(); ok && r == '|' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return "( " + strings.Join(alts, " | ") + " )", nil
+}
+
+func (p *regexParser) sequence() (string, error) {
+	var items []string
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			items = append(items, literal(lit.String()))
+			lit.Reset()
+		}
+	}
+
+	for {
+		r, ok := p.peek()
+		if !ok || r == '|' || r == ')' {
+			break
+		}
+
+		expr, text, err := p.atom()
+		if err != nil {
+			return "", err
+		}
+		min, max, quantified, err := p.quantifier()
+		if err != nil {
+			return "", err
+		}
+
+		if !quantified && expr == "" {
+			lit.WriteString(text)
+			continue
+		}
+
+		flush()
+		if expr == "" {
+			expr = literal(text)
+		}
+		if quantified {
+			if max == 0 {
+				continue
+			}
+			expr = repeat(expr, min, max)
+		}
+		items = append(items, expr)
+	}
+	flush()
+
+	if len(items) == 0 {
+		return `""`, nil
+	}
+	return strings.Join(items, " "), nil
+}
+
+// quantifier parses an optional quantifier, returning its bounds with -1 as
+// an unbounded maximum. Lazy quantifiers are treated as greedy ones.
+func (p *regexParser) quantifier() (int, int, bool, error) {
+	r, ok := p.peek()
+	if !ok {
+		return 0, 0, false, nil
+	}
+
+	var min, max int
+	switch r {
+	case '*':
+		min, max = 0, -1
+		p.pos++
+	case '+':
+		min, max = 1, -1
+		p.pos++
+	case '?':
+		min, max = 0, 1
+		p.pos++
+	case '{':
+		end := p.pos + 1
+		for end < len(p.src) && p.src[end] != '}' {
+			end++
+		}
+		if end == len(p.src) {
+			return 0, 0, false, errors.New("missing closing brace")
+		}
+		bounds := string(p.src[p.pos+1 : end])
+		lo, hi, found := strings.Cut(bounds, ",")
+		var err error
+		if min, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil || min < 0 {
+			return 0, 0, false, fmt.Errorf("invalid quantifier {%s}", bounds)
+		}
+		switch {
+		case !found:
+			max = min
+		case strings.TrimSpace(hi) == "":
+			max = -1
+		default:
+			if max, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || max < min {
+				return 0, 0, false, fmt.Errorf("invalid quantifier {%s}", bounds)
+			}
+		}
+		p.pos = end + 1
+	default:
+		return 0, 0, false, nil
+	}
+
+	if r, ok := p.peek(); ok && r == '?' {
+		p.pos++
+	}
+	return min, max, true, nil
+}
+
+// atom returns either a GBNF expression or, for a literal character, its
+// JSON encoded text.
+func (p *regexParser) atom() (string, string, error) {
+	r := p.src[p.pos]
+	p.pos++
+
+	switch r {
+	case '(':
+		if strings.HasPrefix(string(p.src[p.pos:]), "?:") {
+			p.pos += 2
+		} else if strings.HasPrefix(string(p.src[p.pos:]), "?<") || strings.HasPrefix(string(p.src[p.pos:]), "?P<") {
+			end := strings.IndexRune(string(p.src[p.pos:]), '>')
+			if end < 0 {
+				return "", "", errors.New("unterminated group name")
+			}
+			p.pos += len([]rune(string(p.src[p.pos:])[:end])) + 1
+		} else if r, ok := p.peek(); ok && r == '?' {
+			return "", "", errors.New("lookaround assertions are not supported")
+		}
+		inner, err := p.alternation()
+		if err != nil {
+			return "", "", err
+		}
+		if r, ok := p.peek(); !ok || r != ')' {
+			return "", "", errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return "( " + inner + " )", "", nil
+	case '[':
+		class, err := p.class()
+		return class, "", err
+	case '.':
+		return p.char, "", nil
+	case '\\':
+		return p.escape()
+	case '^', '$':
+		return "", "", fmt.Errorf("anchor %q is only supported at the ends of the pattern", r)
+	case '*', '+', '?', '{':
+		return "", "", fmt.Errorf("nothing to repeat at offset %d", p.pos-1)
+	}
+
+	return "", jsonChar(r), nil
+}
+
+func (p *regexParser) escape() (string, string, error) {
+	r, ok := p.peek()
+	if !ok {
+		return "", "", errors.New("trailing backslash")
+	}
+	p.pos++
+
+	switch r {
+	case 'd', 'w', 's':
+		return "[" + shorthand(r) + "]", "", nil
+	case 'D', 'W', 'S':
+		return "[^" + shorthand(r+'a'-'A') + jsonExcluded + "]", "", nil
+	case 'n':
+		return "", jsonChar('\n'), nil
+	case 't':
+		return "", jsonChar('\t'), nil
+	case 'r':
+		return "", jsonChar('\r'), nil
+	case 'b', 'B':
+		return "", "", errors.New("word boundaries are not supported")
+	}
+	if r >= '1' && r <= '9' {
+		return "", "", errors.New("backreferences are not supported")
+	}
+	return "", jsonChar(r), nil
+}
+
+// jsonExcluded lists, in GBNF class syntax, the characters which cannot
+// appear unescaped in a JSON string.
+const jsonExcluded = `"\\\x7F\x00-\x1F`
+
+func shorthand(r rune) string {
+	switch r {
+	case 'd':
+		return "0-9"
+	case 'w':
+		return "a-zA-Z0-9_"
+	}
+	return " "
+}
+
+// class translates a bracket expression. Characters that must be escaped in
+// JSON are matched through their escaped form.
+func (p *regexParser) class() (string, error) {
+	negate := false
+	if r, ok := p.peek(); ok && r == '^' {
+		negate = true
+		p.pos++
+	}
+
+	var items strings.Builder
+	var escaped []string
+	first := true
+	for {
+		r, ok := p.peek()
+		if !ok {
+			return "", errors.New("missing closing bracket")
+		}
+		if r == ']' && !first {
+			p.pos++
+			break
+		}
+		first = false
+
+		lo, short, err := p.classChar()
+		if err != nil {
+			return "", err
+		}
+		if short != "" {
+			items.WriteString(short)
+			continue
+		}
+
+		hi := lo
+		if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+			p.pos++
+			if hi, short, err = p.classChar(); err != nil {
+				return "", err
+			} else if short != "" {
+				return "", errors.New("invalid range in character class")
+			}
+			if hi < lo {
+				return "", fmt.Errorf("invalid range %q-%q in character class", lo, hi)
+			}
+		}
+
+		if lo == hi && (lo == '"' || lo == '\\' || lo < 0x20) {
+			if !negate {
+				escaped = append(escaped, literal(jsonChar(lo)))
+			}
+			continue
+		}
+		items.WriteString(classRune(lo))
+		if hi != lo {
+			items.WriteString("-" + classRune(hi))
+		}
+	}
+
+	if negate {
+		return "[^" + items.String() + jsonExcluded + "]", nil
+	}
+
+	var alts []string
+	if items.Len() > 0 {
+		alts = append(alts, "["+items.String()+"]")
+	}
+	alts = append(alts, escaped...)
+	if len(alts) == 0 {
+		return "", errors.New("empty character class")
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return "( " + strings.Join(alts, " | ") + " )", nil
+}
+
+// classChar reads a character of a bracket expression, returning either the
+// character or the contents of a shorthand class such as \d.
+func (p *regexParser) classChar() (rune, string, error) {
+	r := p.src[p.pos]
+	p.pos++
+	if r != '\\' {
+		return r, "", nil
+	}
+
+	r, ok := p.peek()
+	if !ok {
+		return 0, "", errors.New("trailing backslash")
+	}
+	p.pos++
+	switch r {
+	case 'd', 'w', 's':
+		return 0, shorthand(r), nil
+	case 'D', 'W', 'S':
+		return 0, "", errors.New("negated shorthands are not supported in character classes")
+	case 'n':
+		return '\n', "", nil
+	case 't':
+		return '\t', "", nil
+	case 'r':
+		return '\r', "", nil
+	}
+	return r, "", nil
+}
+
+func classRune(r rune) string {
+	switch r {
+	case '\\', ']', '[', '-', '^':
+		return `\` + string(r)
+	}
+	if r < 0x20 || r == 0x7f {
+		return fmt.Sprintf(`\x%02X`, r)
+	}
+	return string(r)
+}
+
+// jsonChar returns r as it appears inside a JSON string.
+func jsonChar(r rune) string {
+	q := quote(string(r))
+	return q[1 : len(q)-1]
+}
@@ -0,0 +1,181 @@
+package grammar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// object is a decoded JSON object which remembers the order of its keys, so
+// that generated grammars emit properties in the order of the schema.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) get(key string) any {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+func (o *object) has(key string) bool {
+	if o == nil {
+		return false
+	}
+	_, ok := o.values[key]
+	return ok
+}
+
+// with returns a shallow copy of o with key set to v.
+func (o *object) with(key string, v any) *object {
+	c := &object{keys: make([]string, 0, len(o.keys)+1), values: make(map[string]any, len(o.values)+1)}
+	for _, k := range o.keys {
+		c.keys = append(c.keys, k)
+		c.values[k] = o.values[k]
+	}
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.values[key] = v
+	return c
+}
+
+// decode parses data into *object, []any, string, json.Number, bool or nil.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after schema")
+	}
+	return v, nil
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		o := &object{values: make(map[string]any)}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object key %v", tok)
+			}
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := o.values[key]; !ok {
+				o.keys = append(o.keys, key)
+			}
+			o.values[key] = v
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return o, nil
+	case '[':
+		a := []any{}
+		for dec.More() {
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	return nil, fmt.Errorf("unexpected delimiter %v", delim)
+}
+
+// encode writes v back as compact JSON, keeping the order of object keys.
+func encode(v any) string {
+	var sb strings.Builder
+	encodeTo(&sb, v)
+	return sb.String()
+}
+
+func encodeTo(sb *strings.Builder, v any) {
+	switch v := v.(type) {
+	case *object:
+		sb.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(quote(k))
+			sb.WriteByte(':')
+			encodeTo(sb, v.values[k])
+		}
+		sb.WriteByte('}')
+	case []any:
+		sb.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			encodeTo(sb, e)
+		}
+		sb.WriteByte(']')
+	case string:
+		sb.WriteString(quote(v))
+	case json.Number:
+		sb.WriteString(v.String())
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case nil:
+		sb.WriteString("null")
+	}
+}
+
+// quote returns s as a JSON string without escaping HTML characters.
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// intValue reads a non-negative integer keyword such as minItems.
+func intValue(v any) (int, bool, error) {
+	if v == nil {
+		return 0, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false, fmt.Errorf("expected a number, got %s", encode(v))
+	}
+	i, err := strconv.Atoi(n.String())
+	if err != nil || i < 0 {
+		return 0, false, fmt.Errorf("expected a non-negative integer, got %s", n)
+	}
+	return i, true, nil
+}
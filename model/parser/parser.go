@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
 	"os/user"
@@ -17,6 +18,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/model/store"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
@@ -37,6 +40,102 @@ func (f Modelfile) String() string {
 	return sb.String()
 }
 
+// CreateRequest converts the Modelfile to a create request. Paths are
+// resolved relative to relativeDir, the directory of the Modelfile.
+func (f Modelfile) CreateRequest(relativeDir string) (*api.CreateRequest, error) {
+	req := &api.CreateRequest{}
+
+	params := make(map[string][]string)
+	for _, c := range f.Commands {
+		switch c.Name {
+		case "model":
+			path, err := expandPath(c.Args, relativeDir)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := os.Stat(path); err == nil {
+				req.From = path
+			} else {
+				req.From = c.Args
+			}
+		case "adapter":
+			path, err := expandPath(c.Args, relativeDir)
+			if err != nil {
+				return nil, err
+			}
+			req.Adapters = append(req.Adapters, path)
+		case "template":
+			req.Template = c.Args
+		case "system":
+			req.System = c.Args
+		case "license":
+			req.License = append(req.License, c.Args)
+		case "renderer":
+			req.Renderer = c.Args
+		case "parser":
+			req.Parser = c.Args
+		case "message":
+			role, content, _ := strings.Cut(c.Args, ": ")
+			req.Messages = append(req.Messages, api.Message{Role: role, Content: content})
+		default:
+			if slices.Contains(deprecatedParameters, c.Name) {
+				fmt.Printf("warning: parameter %s is deprecated\n", c.Name)
+				break
+			}
+			params[c.Name] = append(params[c.Name], c.Args)
+		}
+	}
+
+	if len(params) > 0 {
+		p, err := api.FormatParams(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Parameters = p
+	}
+	return req, nil
+}
+
+// FromModel returns the Modelfile recreating a created model.
+func FromModel(m *store.Model) *Modelfile {
+	var f Modelfile
+	add := func(name, args string) {
+		if args != "" {
+			f.Commands = append(f.Commands, Command{Name: name, Args: args})
+		}
+	}
+
+	add("model", m.Path)
+	for _, adapter := range m.Adapters {
+		add("adapter", adapter)
+	}
+	add("template", m.Template)
+	add("system", m.System)
+	for _, license := range m.License {
+		add("license", license)
+	}
+	add("renderer", m.Renderer)
+	add("parser", m.Parser)
+	for _, key := range slices.Sorted(maps.Keys(m.Parameters)) {
+		switch v := m.Parameters[key].(type) {
+		case []any:
+			for _, s := range v {
+				add(key, fmt.Sprint(s))
+			}
+		case []string:
+			for _, s := range v {
+				add(key, s)
+			}
+		default:
+			add(key, fmt.Sprint(v))
+		}
+	}
+	for _, msg := range m.Messages {
+		add("message", msg.Role+": "+msg.Content)
+	}
+	return &f
+}
+
 var deprecatedParameters = []string{
 	"penalize_newline",
 	"low_vram",
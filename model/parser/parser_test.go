@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/model/store"
+)
+
+func TestCreateRequest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "base.gguf"), []byte("GGUF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ParseFile(strings.NewReader(`FROM ./base.gguf
+ADAPTER ./lora.gguf
+SYSTEM """You are a helpful
+assistant."""
+PARAMETER temperature 0.2
+PARAMETER top_k 20
+PARAMETER stop "<|im_end|>"
+PARAMETER stop "<|endoftext|>"
+PARAMETER penalize_newline true
+MESSAGE user Hello
+MESSAGE assistant "Hi, how can I help?"
+LICENSE MIT
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := f.CreateRequest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.From != filepath.Join(dir, "base.gguf") {
+		t.Errorf("from: got %q", req.From)
+	}
+	if len(req.Adapters) != 1 || req.Adapters[0] != filepath.Join(dir, "lora.gguf") {
+		t.Errorf("adapters: got %v", req.Adapters)
+	}
+	if req.System != "You are a helpful\nassistant." {
+		t.Errorf("system: got %q", req.System)
+	}
+	if len(req.License) != 1 || req.License[0] != "MIT" {
+		t.Errorf("license: got %v", req.License)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "user" || req.Messages[1].Content != "Hi, how can I help?" {
+		t.Errorf("messages: got %+v", req.Messages)
+	}
+	if req.Parameters["temperature"] != float32(0.2) || req.Parameters["top_k"] != int64(20) {
+		t.Errorf("parameters: got %v", req.Parameters)
+	}
+	if stop, _ := req.Parameters["stop"].([]string); len(stop) != 2 || stop[1] != "<|endoftext|>" {
+		t.Errorf("stop: got %v", req.Parameters["stop"])
+	}
+	if _, ok := req.Parameters["penalize_newline"]; ok {
+		t.Error("deprecated parameters should be dropped")
+	}
+
+	// a FROM which is not a file names a model
+	f, err = ParseFile(strings.NewReader("FROM mario\nPARAMETER unknown 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.CreateRequest(dir); err == nil {
+		t.Error("expected an error for an unknown parameter")
+	}
+	f.Commands = f.Commands[:1]
+	req, err = f.CreateRequest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.From != "mario" {
+		t.Errorf("from: got %q", req.From)
+	}
+}
+
+func TestFromModel(t *testing.T) {
+	m := &store.Model{
+		Path:       "/models/base.gguf",
+		Template:   "{{ .Prompt }}",
+		System:     "You are Mario.",
+		Parameters: map[string]any{"temperature": 0.7, "num_ctx": float64(4096), "stop": []any{"<|im_end|>", "<|endoftext|>"}},
+		Messages:   []store.Message{{Role: "user", Content: "Who are you?"}},
+	}
+
+	want := `FROM /models/base.gguf
+TEMPLATE {{ .Prompt }}
+SYSTEM You are Mario.
+PARAMETER num_ctx 4096
+PARAMETER stop <|im_end|>
+PARAMETER stop <|endoftext|>
+PARAMETER temperature 0.7
+MESSAGE user Who are you?
+`
+	if got := FromModel(m).String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the Modelfile parses back to the same model
+	f, err := ParseFile(strings.NewReader(FromModel(m).String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := f.CreateRequest(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.From != m.Path || req.Template != m.Template || req.System != m.System ||
+		len(req.Messages) != 1 || req.Messages[0].Content != "Who are you?" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if stop, _ := req.Parameters["stop"].([]string); len(stop) != 2 || req.Parameters["num_ctx"] != int64(4096) {
+		t.Errorf("unexpected parameters %v", req.Parameters)
+	}
+}
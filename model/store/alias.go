@@ -0,0 +1,194 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+)
+
+// maxAliasDepth bounds the chain of aliases followed by Resolve.
+const maxAliasDepth = 16
+
+// WithAliases sets aliases which are resolved as the stored ones, and take
+// precedence over them, but are never written to the store. They replace the
+// aliases given before.
+func (s *Store) WithAliases(aliases map[string]string) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.static = make(map[string]string, len(aliases))
+	for alias, target := range aliases {
+		if n, err := ParseName(alias); err == nil {
+			s.static[n.String()] = target
+		}
+	}
+	return s
+}
+
+// Resolve follows the aliases of name and returns the model or GGUF file it
+// stands for. Names which are not aliases are returned unchanged.
+func (s *Store) Resolve(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aliases, err := s.allAliases()
+	if err != nil {
+		return name
+	}
+	return resolve(aliases, name)
+}
+
+func resolve(aliases map[string]string, name string) string {
+	for range maxAliasDepth {
+		n, err := ParseName(name)
+		if err != nil {
+			return name
+		}
+		target, ok := aliases[n.String()]
+		if !ok {
+			return name
+		}
+		name = target
+	}
+	return name
+}
+
+// Aliases returns the aliases by name along with their targets.
+func (s *Store) Aliases() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.allAliases()
+}
+
+// allAliases returns the stored aliases along with the static ones.
+func (s *Store) allAliases() (map[string]string, error) {
+	aliases, err := s.readAliases()
+	if err != nil {
+		return nil, err
+	}
+	for alias, target := range s.static {
+		aliases[alias] = target
+	}
+	return aliases, nil
+}
+
+// SetAlias makes alias stand for target, which is either a model name, another
+// alias or the path of a GGUF file. A model called alias must not exist.
+func (s *Store) SetAlias(alias, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := ParseName(alias)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.manifestPath(n)); err == nil {
+		return fmt.Errorf("model %q already exists", n)
+	}
+	aliases, err := s.readAliases()
+	if err != nil {
+		return err
+	}
+	aliases[n.String()] = target
+	if t, err := ParseName(resolve(aliases, target)); err == nil && aliases[t.String()] != "" {
+		return fmt.Errorf("alias %q would form a cycle", n)
+	}
+	return s.writeAliases(aliases)
+}
+
+// DeleteAlias removes the alias called name, or returns ErrNotFound.
+func (s *Store) DeleteAlias(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := ParseName(name)
+	if err != nil {
+		return ErrNotFound
+	}
+	removed, err := s.dropAlias(n)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// dropAlias removes the alias n, which a model of the same name replaces.
+func (s *Store) dropAlias(n Name) (bool, error) {
+	aliases, err := s.readAliases()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := aliases[n.String()]; !ok {
+		return false, nil
+	}
+	delete(aliases, n.String())
+	return true, s.writeAliases(aliases)
+}
+
+// dropAliasesOf removes the stored aliases which stand for the model n,
+// directly or through other aliases.
+func (s *Store) dropAliasesOf(n Name) error {
+	aliases, err := s.allAliases()
+	if err != nil {
+		return err
+	}
+	stored, err := s.readAliases()
+	if err != nil {
+		return err
+	}
+	removed := false
+	for alias := range stored {
+		if t, err := ParseName(resolve(aliases, alias)); err == nil && t == n {
+			delete(stored, alias)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return s.writeAliases(stored)
+}
+
+// readAliases returns a copy of the stored aliases. The file is only read
+// again once it was replaced or modified, so that resolving a name on each
+// request does not parse it.
+func (s *Store) readAliases() (map[string]string, error) {
+	info, err := os.Stat(s.aliases)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]string), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if s.cachedInfo != nil && os.SameFile(s.cachedInfo, info) &&
+		s.cachedInfo.ModTime().Equal(info.ModTime()) && s.cachedInfo.Size() == info.Size() {
+		return maps.Clone(s.cached), nil
+	}
+
+	aliases := make(map[string]string)
+	bts, err := os.ReadFile(s.aliases)
+	if errors.Is(err, fs.ErrNotExist) {
+		return aliases, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bts, &aliases); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.aliases, err)
+	}
+	s.cached, s.cachedInfo = aliases, info
+	return maps.Clone(aliases), nil
+}
+
+func (s *Store) writeAliases(aliases map[string]string) error {
+	bts, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.cached, s.cachedInfo = nil, nil
+	return writeFile(s.aliases, bts)
+}
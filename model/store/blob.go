@@ -0,0 +1,164 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const partialSuffix = "-partial"
+
+// BlobPath returns the path of the blob with digest.
+func (s *Store) BlobPath(digest string) string {
+	return filepath.Join(s.blobs, strings.Replace(digest, ":", "-", 1))
+}
+
+// blobDigest returns the digest of the blob at path, if path is one.
+func (s *Store) blobDigest(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil || filepath.Dir(abs) != s.blobs {
+		return "", false
+	}
+	hex, ok := strings.CutPrefix(filepath.Base(abs), "sha256-")
+	if !ok || len(hex) != sha256.Size*2 {
+		return "", false
+	}
+	return "sha256:" + hex, true
+}
+
+// newLayer writes bts as a blob.
+func (s *Store) newLayer(mediaType string, bts []byte) (Layer, error) {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(bts))
+	layer := Layer{MediaType: mediaType, Digest: digest, Size: int64(len(bts))}
+	if _, err := os.Stat(s.BlobPath(digest)); err == nil {
+		return layer, nil
+	}
+	if err := os.MkdirAll(s.blobs, 0o755); err != nil {
+		return Layer{}, err
+	}
+	return layer, s.copyBlob(digest, bytes.NewReader(bts))
+}
+
+// fileLayer imports the file at path as a blob. The file is copied rather
+// than linked, so that rewriting it later does not change the blob under its
+// digest, and hashed as it is copied, so that the blob holds the bytes its
+// digest is of even if the file changes meanwhile.
+func (s *Store) fileLayer(mediaType, path string) (Layer, error) {
+	if digest, ok := s.blobDigest(path); ok {
+		info, err := os.Stat(path)
+		if err != nil {
+			return Layer{}, err
+		}
+		return Layer{MediaType: mediaType, Digest: digest, Size: info.Size()}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Layer{}, err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(s.blobs, 0o755); err != nil {
+		return Layer{}, err
+	}
+	tmp, err := os.CreateTemp(s.blobs, "sha256-*"+partialSuffix)
+	if err != nil {
+		return Layer{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), f)
+	if err != nil {
+		tmp.Close()
+		return Layer{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Layer{}, err
+	}
+	layer := Layer{MediaType: mediaType, Digest: fmt.Sprintf("sha256:%x", h.Sum(nil)), Size: n}
+	blob := s.BlobPath(layer.Digest)
+	if _, err := os.Stat(blob); err == nil {
+		return layer, nil
+	}
+	return layer, os.Rename(tmp.Name(), blob)
+}
+
+func (s *Store) copyBlob(digest string, r io.Reader) error {
+	tmp, err := os.CreateTemp(s.blobs, strings.Replace(digest, ":", "-", 1)+"-*"+partialSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.BlobPath(digest))
+}
+
+func (s *Store) readBlob(digest string) ([]byte, error) {
+	return os.ReadFile(s.BlobPath(digest))
+}
+
+// references counts the manifests referencing each blob.
+func (s *Store) references() (map[string]int, error) {
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]int)
+	for _, n := range names {
+		m, _, err := s.readManifest(n)
+		if err != nil {
+			return nil, err
+		}
+		for _, digest := range m.digests() {
+			refs[digest]++
+		}
+	}
+	return refs, nil
+}
+
+// Prune removes the blobs no manifest references, along with the leftovers
+// of interrupted writes.
+func (s *Store) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.references()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.blobs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.blobs, entry.Name())
+		if digest, ok := s.blobDigest(path); ok && refs[digest] > 0 {
+			continue
+		}
+		log.Debug("Pruning blob", "path", path)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
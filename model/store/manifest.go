@@ -0,0 +1,102 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	MediaTypeManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeConfig    = "application/vnd.llamago.image.config.v1+json"
+	MediaTypeModel     = "application/vnd.llamago.image.model"
+	MediaTypeProjector = "application/vnd.llamago.image.projector"
+	MediaTypeAdapter   = "application/vnd.llamago.image.adapter"
+	MediaTypeTemplate  = "application/vnd.llamago.image.template"
+	MediaTypeSystem    = "application/vnd.llamago.image.system"
+	MediaTypeParams    = "application/vnd.llamago.image.params"
+	MediaTypeMessages  = "application/vnd.llamago.image.messages"
+	MediaTypeLicense   = "application/vnd.llamago.image.license"
+)
+
+// Layer references a blob of a model.
+type Layer struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Manifest lists the blobs a model is made of: its config and layers for the
+// weights, projector, adapters, template, system prompt, parameters, messages
+// and licenses.
+type Manifest struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	MediaType     string  `json:"mediaType"`
+	Config        Layer   `json:"config"`
+	Layers        []Layer `json:"layers"`
+}
+
+// ConfigV1 is the config blob of a model.
+type ConfigV1 struct {
+	ModelFormat string `json:"model_format"`
+	From        string `json:"from,omitempty"`
+	Renderer    string `json:"renderer,omitempty"`
+	Parser      string `json:"parser,omitempty"`
+}
+
+// digests returns the blobs referenced by the manifest.
+func (m *Manifest) digests() []string {
+	ret := []string{m.Config.Digest}
+	for _, layer := range m.Layers {
+		ret = append(ret, layer.Digest)
+	}
+	return ret
+}
+
+func (s *Store) manifestPath(n Name) string {
+	return filepath.Join(s.manifests, n.Model, n.Tag)
+}
+
+// readManifest reads the manifest of n along with its digest.
+func (s *Store) readManifest(n Name) (*Manifest, string, error) {
+	bts, err := os.ReadFile(s.manifestPath(n))
+	if err != nil {
+		return nil, "", err
+	}
+	var m Manifest
+	if err := json.Unmarshal(bts, &m); err != nil {
+		return nil, "", fmt.Errorf("manifest %s: %w", n, err)
+	}
+	return &m, fmt.Sprintf("sha256:%x", sha256.Sum256(bts)), nil
+}
+
+func (s *Store) writeManifest(n Name, m *Manifest) error {
+	bts, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.manifestPath(n), bts)
+}
+
+// writeFile writes to a temporary file first so that a failed write never
+// leaves a truncated file behind.
+func writeFile(path string, bts []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bts); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
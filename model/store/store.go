@@ -0,0 +1,410 @@
+// Package store keeps the named models created from a Modelfile under the
+// model directory. A named model is a base GGUF together with the system
+// prompt, template, default parameters and seed messages applied to requests.
+//
+// Models are stored as manifests under manifests/<model>/<tag> referencing
+// content addressed blobs under blobs/sha256-<hex>, which models share.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// DefaultTag is the tag of names given without one.
+	DefaultTag = "latest"
+
+	manifestsDir = "manifests"
+	blobsDir     = "blobs"
+	aliasesFile  = "aliases.json"
+)
+
+var ErrNotFound = errors.New("model not found")
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,79}$`)
+
+// Name is a model name in the name:tag form.
+type Name struct {
+	Model string
+	Tag   string
+}
+
+// ParseName parses name, which may omit the tag.
+func ParseName(name string) (Name, error) {
+	model, tag, ok := strings.Cut(name, ":")
+	if !ok {
+		tag = DefaultTag
+	}
+	if !namePattern.MatchString(model) || !namePattern.MatchString(tag) || strings.HasSuffix(model, ".gguf") {
+		return Name{}, fmt.Errorf("invalid model name %q", name)
+	}
+	return Name{Model: model, Tag: tag}, nil
+}
+
+func (n Name) String() string {
+	return n.Model + ":" + n.Tag
+}
+
+// Message is a message seeding the chats with a model.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Model is a named model. The paths of a model read from the store are those
+// of its blobs.
+type Model struct {
+	Name Name
+
+	// From is the model it was created from, as given in the Modelfile.
+	From string
+	// Path is the base GGUF.
+	Path       string
+	Projector  string
+	Adapters   []string
+	Template   string
+	System     string
+	License    []string
+	Renderer   string
+	Parser     string
+	Parameters map[string]any
+	Messages   []Message
+
+	// Digest is the digest of the manifest and Size the total size of the
+	// blobs of a model read from the store.
+	Digest     string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Store reads and writes the named models under a model directory.
+type Store struct {
+	manifests string
+	blobs     string
+	aliases   string
+
+	// static are aliases which are not stored, see WithAliases.
+	static map[string]string
+	// cached are the stored aliases, read again once their file changed
+	cached     map[string]string
+	cachedInfo os.FileInfo
+
+	// mu serializes the changes to the store so that blobs are never pruned
+	// while a model referencing them is written
+	mu sync.Mutex
+}
+
+func New(modelDir string) *Store {
+	dir, err := filepath.Abs(modelDir)
+	if err != nil {
+		dir = modelDir
+	}
+	return &Store{
+		manifests: filepath.Join(dir, manifestsDir),
+		blobs:     filepath.Join(dir, blobsDir),
+		aliases:   filepath.Join(dir, aliasesFile),
+	}
+}
+
+// Get returns the model called name, or ErrNotFound.
+func (s *Store) Get(name string) (*Model, error) {
+	n, err := ParseName(name)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return s.read(n)
+}
+
+func (s *Store) read(n Name) (*Model, error) {
+	manifest, digest, err := s.readManifest(n)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(s.manifestPath(n))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Model{Name: n, Digest: digest, Size: manifest.Config.Size, ModifiedAt: info.ModTime()}
+
+	var config ConfigV1
+	if err := s.readJSON(manifest.Config.Digest, &config); err != nil {
+		return nil, err
+	}
+	m.From = config.From
+	m.Renderer = config.Renderer
+	m.Parser = config.Parser
+
+	for _, layer := range manifest.Layers {
+		m.Size += layer.Size
+		switch layer.MediaType {
+		case MediaTypeModel:
+			m.Path = s.BlobPath(layer.Digest)
+		case MediaTypeProjector:
+			m.Projector = s.BlobPath(layer.Digest)
+		case MediaTypeAdapter:
+			m.Adapters = append(m.Adapters, s.BlobPath(layer.Digest))
+		case MediaTypeTemplate, MediaTypeSystem, MediaTypeLicense:
+			bts, err := s.readBlob(layer.Digest)
+			if err != nil {
+				return nil, err
+			}
+			switch layer.MediaType {
+			case MediaTypeTemplate:
+				m.Template = string(bts)
+			case MediaTypeSystem:
+				m.System = string(bts)
+			default:
+				m.License = append(m.License, string(bts))
+			}
+		case MediaTypeParams:
+			if err := s.readJSON(layer.Digest, &m.Parameters); err != nil {
+				return nil, err
+			}
+		case MediaTypeMessages:
+			if err := s.readJSON(layer.Digest, &m.Messages); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.Path == "" {
+		return nil, fmt.Errorf("model %s has no weights", n)
+	}
+	return m, nil
+}
+
+func (s *Store) readJSON(digest string, v any) error {
+	bts, err := s.readBlob(digest)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bts, v)
+}
+
+// Create writes m, replacing any model of the same name. The files of the
+// weights, projector and adapters are imported as blobs unless they already
+// are blobs of the store.
+func (s *Store) Create(m *Model) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	manifest := &Manifest{SchemaVersion: 2, MediaType: MediaTypeManifest}
+	addFile := func(mediaType, path string) error {
+		layer, err := s.fileLayer(mediaType, path)
+		if err != nil {
+			return err
+		}
+		manifest.Layers = append(manifest.Layers, layer)
+		return nil
+	}
+	add := func(mediaType string, bts []byte) error {
+		layer, err := s.newLayer(mediaType, bts)
+		if err != nil {
+			return err
+		}
+		manifest.Layers = append(manifest.Layers, layer)
+		return nil
+	}
+	addJSON := func(mediaType string, v any) error {
+		bts, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return add(mediaType, bts)
+	}
+
+	if err := addFile(MediaTypeModel, m.Path); err != nil {
+		return err
+	}
+	if m.Projector != "" {
+		if err := addFile(MediaTypeProjector, m.Projector); err != nil {
+			return err
+		}
+	}
+	for _, adapter := range m.Adapters {
+		if err := addFile(MediaTypeAdapter, adapter); err != nil {
+			return err
+		}
+	}
+	if m.Template != "" {
+		if err := add(MediaTypeTemplate, []byte(m.Template)); err != nil {
+			return err
+		}
+	}
+	if m.System != "" {
+		if err := add(MediaTypeSystem, []byte(m.System)); err != nil {
+			return err
+		}
+	}
+	if len(m.Parameters) > 0 {
+		if err := addJSON(MediaTypeParams, m.Parameters); err != nil {
+			return err
+		}
+	}
+	if len(m.Messages) > 0 {
+		if err := addJSON(MediaTypeMessages, m.Messages); err != nil {
+			return err
+		}
+	}
+	for _, license := range m.License {
+		if err := add(MediaTypeLicense, []byte(license)); err != nil {
+			return err
+		}
+	}
+
+	config, err := json.Marshal(ConfigV1{ModelFormat: "gguf", From: m.From, Renderer: m.Renderer, Parser: m.Parser})
+	if err != nil {
+		return err
+	}
+	if manifest.Config, err = s.newLayer(MediaTypeConfig, config); err != nil {
+		return err
+	}
+
+	if err := s.writeManifest(m.Name, manifest); err != nil {
+		return err
+	}
+	_, err = s.dropAlias(m.Name)
+	return err
+}
+
+// Copy makes the model dst a copy of src. Both share the blobs of src.
+func (s *Store) Copy(src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, err := ParseName(src)
+	if err != nil {
+		return ErrNotFound
+	}
+	to, err := ParseName(dst)
+	if err != nil {
+		return err
+	}
+	bts, err := os.ReadFile(s.manifestPath(from))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := writeFile(s.manifestPath(to), bts); err != nil {
+		return err
+	}
+	_, err = s.dropAlias(to)
+	return err
+}
+
+// Delete removes the model called name along with the blobs no other model
+// references and the aliases standing for it. Deleting an alias leaves its
+// target alone.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := ParseName(name)
+	if err != nil {
+		return ErrNotFound
+	}
+	if removed, err := s.dropAlias(n); err != nil || removed {
+		return err
+	}
+	manifest, _, err := s.readManifest(n)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	path := s.manifestPath(n)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if err := s.dropAliasesOf(n); err != nil {
+		return err
+	}
+	// the directory of the model is left once its last tag is removed
+	if entries, err := os.ReadDir(filepath.Dir(path)); err == nil && len(entries) == 0 {
+		os.Remove(filepath.Dir(path))
+	}
+
+	refs, err := s.references()
+	if err != nil {
+		return err
+	}
+	for _, digest := range manifest.digests() {
+		if refs[digest] > 0 {
+			continue
+		}
+		if err := os.Remove(s.BlobPath(digest)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the names of all models.
+func (s *Store) names() ([]Name, error) {
+	entries, err := os.ReadDir(s.manifests)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []Name
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tags, err := os.ReadDir(filepath.Join(s.manifests, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if tag.IsDir() {
+				continue
+			}
+			n, err := ParseName(entry.Name() + ":" + tag.Name())
+			if err != nil {
+				continue
+			}
+			names = append(names, n)
+		}
+	}
+	return names, nil
+}
+
+// List returns all models, sorted by name.
+func (s *Store) List() ([]*Model, error) {
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]*Model, 0, len(names))
+	for _, n := range names {
+		m, err := s.read(n)
+		if err != nil {
+			log.Warn("Skipping unreadable model", "model", n, "error", err)
+			continue
+		}
+		models = append(models, m)
+	}
+	slices.SortFunc(models, func(a, b *Model) int {
+		return strings.Compare(a.Name.String(), b.Name.String())
+	})
+	return models, nil
+}
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Name
+		err  bool
+	}{
+		{in: "mario", want: Name{Model: "mario", Tag: "latest"}},
+		{in: "mario:v2", want: Name{Model: "mario", Tag: "v2"}},
+		{in: "qwen2.5-0.5b_q8:latest", want: Name{Model: "qwen2.5-0.5b_q8", Tag: "latest"}},
+		{in: "", err: true},
+		{in: "mario:", err: true},
+		{in: "../mario", err: true},
+		{in: "ns/mario", err: true},
+		{in: "mario:a:b", err: true},
+		{in: "model.gguf", err: true},
+	}
+
+	for _, tt := range cases {
+		got, err := ParseName(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", tt.in)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func blobs(t *testing.T, s *Store) []string {
+	t.Helper()
+	entries, err := os.ReadDir(s.blobs)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	base := writeTestFile(t, dir, "base.gguf", "GGUF weights")
+
+	if _, err := s.Get("mario"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if models, err := s.List(); err != nil || len(models) != 0 {
+		t.Fatalf("expected no models, got %v, %v", models, err)
+	}
+
+	m := &Model{
+		Name:       Name{Model: "mario", Tag: "latest"},
+		From:       "base.gguf",
+		Path:       base,
+		System:     "You are Mario from Super Mario Bros.",
+		License:    []string{"MIT"},
+		Parameters: map[string]any{"temperature": 0.7, "stop": []any{"<|im_end|>"}},
+		Messages:   []Message{{Role: "user", Content: "Who are you?"}, {Role: "assistant", Content: "It's a me, Mario!"}},
+	}
+	if err := s.Create(m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Get("mario")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != m.Name || got.From != m.From || got.System != m.System || got.Parameters["temperature"] != 0.7 ||
+		len(got.Messages) != 2 || len(got.License) != 1 || got.ModifiedAt.IsZero() || !strings.HasPrefix(got.Digest, "sha256:") {
+		t.Errorf("unexpected model %+v", got)
+	}
+	if filepath.Dir(got.Path) != s.blobs {
+		t.Errorf("weights should be a blob, got %s", got.Path)
+	}
+	if bts, err := os.ReadFile(got.Path); err != nil || string(bts) != "GGUF weights" {
+		t.Errorf("unexpected weights %q, %v", bts, err)
+	}
+	// the blob is a copy, which rewriting the source in place leaves intact
+	if err := os.WriteFile(base, []byte("requantized"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if bts, err := os.ReadFile(got.Path); err != nil || string(bts) != "GGUF weights" {
+		t.Errorf("the blob changed with its source: %q, %v", bts, err)
+	}
+	// config, weights, system, parameters, messages and license
+	if n := len(blobs(t, s)); n != 6 {
+		t.Errorf("expected 6 blobs, got %d", n)
+	}
+
+	// a model created from a model of the store reuses its blobs
+	luigi := *got
+	luigi.Name = Name{Model: "luigi", Tag: "v1"}
+	luigi.From = "mario"
+	luigi.System = "You are Luigi."
+	if err := s.Create(&luigi); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(blobs(t, s)); n != 8 {
+		t.Errorf("expected 8 blobs, got %d", n)
+	}
+	if _, err := s.Get("luigi"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the latest tag of luigi, got %v", err)
+	}
+
+	if err := s.Copy("mario", "peach:v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Copy("bowser", "peach:v3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	models, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range models {
+		names = append(names, m.Name.String())
+	}
+	if strings.Join(names, ",") != "luigi:v1,mario:latest,peach:v2" {
+		t.Errorf("unexpected models %v", names)
+	}
+
+	// blobs are removed once no model references them
+	if err := s.Delete("mario"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(blobs(t, s)); n != 8 {
+		t.Errorf("expected 8 blobs, got %d", n)
+	}
+	if err := s.Delete("peach:v2"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(blobs(t, s)); n != 6 {
+		t.Errorf("expected 6 blobs, got %d", n)
+	}
+	if err := s.Delete("peach:v2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.manifests, "peach")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the directory of peach to be removed, got %v", err)
+	}
+	if _, err := os.Stat(base); err != nil {
+		t.Errorf("the base model should be left untouched: %v", err)
+	}
+
+	// prune removes unreferenced blobs and partial writes only
+	writeTestFile(t, s.blobs, "sha256-"+strings.Repeat("0", 64), "orphan")
+	writeTestFile(t, s.blobs, "sha256-"+strings.Repeat("1", 64)+"-123-partial", "partial")
+	if err := s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(blobs(t, s)); n != 6 {
+		t.Errorf("expected 6 blobs, got %d", n)
+	}
+	if _, err := s.Get("luigi:v1"); err != nil {
+		t.Errorf("luigi should survive pruning: %v", err)
+	}
+
+	if err := s.Delete("luigi:v1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(blobs(t, s)); n != 0 {
+		t.Errorf("expected no blobs, got %v", blobs(t, s))
+	}
+}
+
+func TestAliases(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	base := writeTestFile(t, dir, "base.gguf", "weights")
+	if err := s.Create(&Model{Name: Name{Model: "mario", Tag: DefaultTag}, Path: base}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetAlias("qwen", "base.gguf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetAlias("plumber:v1", "mario"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetAlias("hero", "plumber:v1"); err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"qwen":        "base.gguf",
+		"qwen:latest": "base.gguf",
+		"hero":        "mario",
+		"plumber":     "plumber",
+		"base.gguf":   "base.gguf",
+	} {
+		if got := s.Resolve(in); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+
+	if err := s.SetAlias("mario", "base.gguf"); err == nil {
+		t.Error("expected an error for an alias named after a model")
+	}
+	if err := s.SetAlias("plumber:v1", "hero"); err == nil {
+		t.Error("expected an error for a cycle")
+	}
+	if got := s.Resolve("hero"); got != "mario" {
+		t.Errorf("a rejected alias should leave the table unchanged, got %q", got)
+	}
+
+	// a model replaces the alias of the same name
+	if err := s.Copy("mario", "qwen"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Resolve("qwen"); got != "qwen" {
+		t.Errorf("expected the alias to be replaced, got %q", got)
+	}
+
+	// deleting an alias leaves its target alone
+	if err := s.Delete("hero"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteAlias("hero"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	aliases, err := s.Aliases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 1 || aliases["plumber:v1"] != "mario" {
+		t.Errorf("unexpected aliases %v", aliases)
+	}
+	if _, err := s.Get("plumber:v1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get should not follow aliases, got %v", err)
+	}
+
+	// the table is persisted under the model directory
+	if got := New(dir).Resolve("plumber:v1"); got != "mario" {
+		t.Errorf("expected the alias to persist, got %q", got)
+	}
+
+	// deleting a model drops the aliases standing for it
+	if err := s.SetAlias("hero", "plumber:v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("mario"); err != nil {
+		t.Fatal(err)
+	}
+	if aliases, err := s.Aliases(); err != nil || len(aliases) != 0 {
+		t.Errorf("expected the aliases of mario to be dropped, got %v, %v", aliases, err)
+	}
+}
+
+func TestStaticAliases(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir).WithAliases(map[string]string{"chat": "qwen:7b", "qwen:7b": "qwen2.5-7b.gguf"})
+	if err := s.SetAlias("chat", "other.gguf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Resolve("chat:latest"); got != "qwen2.5-7b.gguf" {
+		t.Errorf("expected the static aliases to take precedence, got %q", got)
+	}
+	if got := New(dir).Resolve("chat"); got != "other.gguf" {
+		t.Errorf("expected the static aliases not to be stored, got %q", got)
+	}
+}
+
+func TestAliasesFileChanged(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	if err := s.SetAlias("chat", "qwen.gguf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Resolve("chat"); got != "qwen.gguf" {
+		t.Fatalf("got %q", got)
+	}
+	// another store of the directory, such as the one of another process
+	if err := New(dir).SetAlias("chat", "llama.gguf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Resolve("chat"); got != "llama.gguf" {
+		t.Errorf("expected the aliases to be read again once changed, got %q", got)
+	}
+}
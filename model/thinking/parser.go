@@ -51,6 +51,32 @@ type Parser struct {
 	acc        strings.Builder
 }
 
+// StartThinking treats the opening tag as already seen, for prompts rendered
+// with the opening tag at their end so that the model only generates the rest
+// of the thinking trace.
+func (s *Parser) StartThinking() {
+	if s.state == thinkingState_LookingForOpening {
+		s.state = thinkingState_ThinkingStartedEatingWhitespace
+	}
+}
+
+// Flush returns the thinking content and the non-thinking content still
+// buffered once there is no more content, e.g. a partial tag at the end of the
+// output.
+func (s *Parser) Flush() (string, string) {
+	acc := s.acc.String()
+	s.acc.Reset()
+	switch s.state {
+	case thinkingState_ThinkingStartedEatingWhitespace, thinkingState_Thinking:
+		return acc, ""
+	case thinkingState_LookingForOpening:
+		s.state = thinkingState_ThinkingDone
+		return "", acc
+	default:
+		return "", acc
+	}
+}
+
 // AddContent returns the thinking content and the non-thinking content that
 // should be immediately sent to the user. It will internally buffer if it needs
 // to see more raw content to disambiguate
@@ -285,3 +285,65 @@ func TestThinkingStreaming(t *testing.T) {
 		}
 	}
 }
+
+func TestThinkingStartedAndFlush(t *testing.T) {
+	cases := []struct {
+		desc         string
+		started      bool
+		inputs       []string
+		wantThinking string
+		wantContent  string
+	}{
+		{
+			desc:         "opening tag in the prompt",
+			started:      true,
+			inputs:       []string{"\nsome ", "thoughts</th", "ink>\n\nanswer"},
+			wantThinking: "some thoughts",
+			wantContent:  "answer",
+		},
+		{
+			desc:         "unclosed thinking with a partial closing tag",
+			started:      true,
+			inputs:       []string{"thoughts </thi"},
+			wantThinking: "thoughts </thi",
+		},
+		{
+			desc:        "partial opening tag",
+			inputs:      []string{"  <thi"},
+			wantContent: "  <thi",
+		},
+		{
+			desc:        "whitespace only",
+			inputs:      []string{" \n"},
+			wantContent: " \n",
+		},
+		{
+			desc:         "nothing buffered",
+			inputs:       []string{"<think>a</think>b"},
+			wantThinking: "a",
+			wantContent:  "b",
+		},
+	}
+
+	for _, c := range cases {
+		parser := Parser{
+			OpeningTag: "<think>",
+			ClosingTag: "</think>",
+		}
+		if c.started {
+			parser.StartThinking()
+		}
+		var gotThinking, gotContent string
+		for _, input := range c.inputs {
+			thinking, content := parser.AddContent(input)
+			gotThinking += thinking
+			gotContent += content
+		}
+		thinking, content := parser.Flush()
+		gotThinking += thinking
+		gotContent += content
+		if gotThinking != c.wantThinking || gotContent != c.wantContent {
+			t.Errorf("case %q: got (%q,%q), want (%q,%q)", c.desc, gotThinking, gotContent, c.wantThinking, c.wantContent)
+		}
+	}
+}
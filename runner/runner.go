@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Qitmeer/llama.go/config"
@@ -12,16 +15,28 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+var (
+	// ErrEmbeddingsDisabled is returned by Embed when the core was started
+	// without the embedding mode.
+	ErrEmbeddingsDisabled = errors.New("embeddings are disabled, start the server with --embeddings")
+	// ErrTokenEmbeddings is returned by Embed when the core returns an
+	// embedding per token, as with --pooling none.
+	ErrTokenEmbeddings = errors.New("the model returns an embedding per token, set --pooling to pool them")
+)
+
 type Service struct {
-	ctx     *cli.Context
-	cfg     *config.Config
-	running bool
+	ctx *cli.Context
+	// cfg is the config the core is started with, replaced on reloads
+	cfg     *atomic.Pointer[config.Config]
+	running atomic.Bool
+	mu      sync.Mutex
+	// stopped is closed once the core started last has returned
+	stopped chan struct{}
 }
 
-func New(ctx *cli.Context, cfg *config.Config) *Service {
+func New(ctx *cli.Context, cfg *atomic.Pointer[config.Config]) *Service {
 	log.Info("New Runner ...")
-	ser := Service{ctx: ctx, cfg: cfg, running: false}
-	return &ser
+	return &Service{ctx: ctx, cfg: cfg}
 }
 
 func (s *Service) Start() error {
@@ -32,7 +47,7 @@ func (s *Service) Start() error {
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- wrapper.LlamaStart(s.cfg)
+		errCh <- wrapper.LlamaStart(s.cfg.Load())
 	}()
 
 	timeout := time.After(15 * time.Minute)
@@ -49,8 +64,12 @@ func (s *Service) Start() error {
 			return errors.New("llama core stopped before becoming ready")
 		case <-tick.C:
 			if wrapper.IsLlamaRunning() {
-				s.running = true
 				log.Info("Started llama core (inference loop ready)")
+				stopped := make(chan struct{})
+				s.mu.Lock()
+				s.stopped = stopped
+				s.running.Store(true)
+				s.mu.Unlock()
 				go func() {
 					err := <-errCh
 					if err != nil {
@@ -58,7 +77,14 @@ func (s *Service) Start() error {
 					} else {
 						log.Info("llama core stopped")
 					}
-					s.running = false
+					// a core that returns after Stop gave up on it must not
+					// mark the core started since as stopped
+					s.mu.Lock()
+					if s.stopped == stopped {
+						s.running.Store(false)
+					}
+					s.mu.Unlock()
+					close(stopped)
 				}()
 				return nil
 			}
@@ -77,27 +103,60 @@ func (s *Service) Stop() error {
 	if err != nil {
 		log.Error(err.Error())
 	}
-	s.running = false
+	// wait for the core to return so that it can be started again
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	select {
+	case <-stopped:
+	case <-time.After(time.Minute):
+		log.Warn("Timeout waiting for llama core to stop")
+	}
+	s.running.Store(false)
 	return nil
 }
 
 func (s *Service) IsRunning() bool {
-	return s.running
+	return s.running.Load()
 }
 
-func (s *Service) Generate(id int, model string, prompt string, stream bool) error {
-	type body struct {
-		Model  string `json:"model,omitempty"`
-		Prompt string `json:"prompt"`
-		Stream bool   `json:"stream"`
+// Generate runs a completion of prompt, a string or the ids of its tokens.
+// params holds additional fields of the completion request such as the
+// grammar constraining the output.
+func (s *Service) Generate(id int, model string, prompt any, stream bool, params map[string]any) error {
+	body := make(map[string]any, len(params)+3)
+	for k, v := range params {
+		body[k] = v
 	}
-	b, err := json.Marshal(body{Model: model, Prompt: prompt, Stream: stream})
+	if model != "" {
+		body["model"] = model
+	}
+	body["prompt"] = prompt
+	body["stream"] = stream
+	b, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
 	return wrapper.LlamaGenerate(id, string(b))
 }
 
+// Infill runs a fill-in-the-middle completion. body is a llama.cpp /infill
+// request with input_prefix and input_suffix.
+func (s *Service) Infill(id int, model string, body map[string]any) error {
+	payload := make(map[string]any, len(body)+1)
+	for k, v := range body {
+		payload[k] = v
+	}
+	if model != "" {
+		payload["model"] = model
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return wrapper.LlamaInfill(id, string(b))
+}
+
 func (s *Service) Chat(id int, model string, jsStr string) error {
 	payload := jsStr
 	if model != "" {
@@ -114,3 +173,46 @@ func (s *Service) Chat(id int, model string, jsStr string) error {
 	}
 	return wrapper.LlamaChat(id, payload)
 }
+
+// Embed returns the embeddings of the inputs, each a string or the ids of its
+// tokens, normalized as by --embd-normalize. The core batches them with the
+// inputs of the concurrent callers.
+func (s *Service) Embed(input []any, normalize int) ([][]float32, error) {
+	b, err := json.Marshal(map[string]any{"input": input, "embd_normalize": normalize})
+	if err != nil {
+		return nil, err
+	}
+	status, jsonStr := wrapper.LlamaEmbeddingsHTTP(string(b))
+	if wrapper.NotRunning(status, jsonStr) {
+		return nil, errors.New("llama core is not running")
+	}
+	if status == http.StatusNotImplemented {
+		return nil, ErrEmbeddingsDisabled
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("llama core: %s", jsonStr)
+	}
+	var resp []struct {
+		Index     int         `json:"index"`
+		Embedding [][]float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		return nil, err
+	}
+	ret := make([][]float32, len(input))
+	for _, r := range resp {
+		if r.Index < 0 || r.Index >= len(ret) || len(r.Embedding) == 0 {
+			return nil, fmt.Errorf("llama core: unexpected embedding %d", r.Index)
+		}
+		if len(r.Embedding) > 1 {
+			return nil, ErrTokenEmbeddings
+		}
+		ret[r.Index] = r.Embedding[0]
+	}
+	for i, e := range ret {
+		if e == nil {
+			return nil, fmt.Errorf("llama core: missing embedding %d", i)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admission keeps track of the requests in flight so that they can be drained,
+// and bounds their number. Health checks and admin requests are not counted,
+// nor the audio streams, which are only rejected once admission is closed.
+type Admission struct {
+	mu       sync.Mutex
+	inflight int
+	limit    int
+	closed   bool
+	// resume is closed when a pause ends, nil when admission is not paused
+	resume chan struct{}
+}
+
+func NewAdmission(limit int) *Admission {
+	return &Admission{limit: limit}
+}
+
+// SetLimit bounds the number of requests in flight, 0 for no limit.
+func (a *Admission) SetLimit(limit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limit = limit
+}
+
+// Pause holds the new requests until Resume is called.
+func (a *Admission) Pause() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.resume == nil {
+		a.resume = make(chan struct{})
+	}
+}
+
+func (a *Admission) Resume() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.resume != nil {
+		close(a.resume)
+		a.resume = nil
+	}
+}
+
+// Close rejects the new requests, including those held by a pause, with 503.
+func (a *Admission) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	if a.resume != nil {
+		close(a.resume)
+		a.resume = nil
+	}
+}
+
+// InFlight returns the number of requests being handled.
+func (a *Admission) InFlight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight
+}
+
+// Drain waits for the requests in flight to complete, or ctx to be done.
+func (a *Admission) Drain(ctx context.Context) error {
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for a.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+	return nil
+}
+
+// isStream tells whether path is that of the audio streams, whose WebSocket
+// lasts a whole session. Counted, each would hold a slot of the limit for
+// as long, and block the drain of the reloads restarting the runner, which
+// they do not use. They end with the base context of the server instead.
+func isStream(path string) bool {
+	return path == "/api/audio/stream"
+}
+
+func (a *Admission) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path == "/health" || strings.HasPrefix(path, "/admin/") {
+			c.Next()
+			return
+		}
+		if isStream(path) {
+			a.mu.Lock()
+			closed := a.closed
+			a.mu.Unlock()
+			if closed {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		a.mu.Lock()
+		for a.resume != nil && !a.closed {
+			resume := a.resume
+			a.mu.Unlock()
+			select {
+			case <-resume:
+			case <-c.Request.Context().Done():
+				c.AbortWithStatus(http.StatusServiceUnavailable)
+				return
+			}
+			a.mu.Lock()
+		}
+		if a.closed {
+			a.mu.Unlock()
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if a.limit > 0 && a.inflight >= a.limit {
+			a.mu.Unlock()
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "too many requests in flight, try again later"})
+			return
+		}
+		a.inflight++
+		a.mu.Unlock()
+
+		defer func() {
+			a.mu.Lock()
+			a.inflight--
+			a.mu.Unlock()
+		}()
+		c.Next()
+	}
+}
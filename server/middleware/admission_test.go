@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdmissionStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAdmission(1)
+	r := gin.New()
+	r.Use(a.Handler())
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	r.GET("/api/audio/stream", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/v1/models", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve("/api/audio/stream")
+		close(done)
+	}()
+	<-entered
+
+	// the stream holds neither a slot nor the drain
+	if a.InFlight() != 0 {
+		t.Errorf("got %d requests in flight, want 0", a.InFlight())
+	}
+	if code := serve("/v1/models"); code != http.StatusOK {
+		t.Errorf("got status %d beside a stream, want %d", code, http.StatusOK)
+	}
+	if err := a.Drain(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	a.Close()
+	if code := serve("/api/audio/stream"); code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d for a stream after Close, want %d", code, http.StatusServiceUnavailable)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeys rejects the requests without one of the keys returned by keys as
+// their bearer token, except for the health checks and CORS preflights. Any
+// request is allowed when there are no keys.
+func APIKeys(keys func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := keys()
+		if len(keys) <= 0 || c.Request.Method == http.MethodOptions || c.Request.URL.Path == "/health" {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok {
+			for _, key := range keys {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API key"})
+	}
+}
+
+// LoopbackWithoutKeys restricts a route to the clients connecting from the
+// loopback interface when there are no keys. With keys, APIKeys already
+// requires one of them.
+func LoopbackWithoutKeys(keys func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(keys()) > 0 {
+			c.Next()
+			return
+		}
+		// the remote address of the connection, which headers cannot forge
+		if addr, err := netip.ParseAddrPort(c.Request.RemoteAddr); err == nil && addr.Addr().Unmap().IsLoopback() {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only local clients are allowed without an API key"})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoopbackWithoutKeys(t *testing.T) {
+	cases := []struct {
+		keys       []string
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		{nil, "127.0.0.1:50000", "", http.StatusOK},
+		{nil, "[::1]:50000", "", http.StatusOK},
+		{nil, "[::ffff:127.0.0.1]:50000", "", http.StatusOK},
+		{nil, "192.168.1.20:50000", "", http.StatusForbidden},
+		{nil, "192.168.1.20:50000", "127.0.0.1", http.StatusForbidden},
+		{[]string{"secret"}, "192.168.1.20:50000", "", http.StatusOK},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, c := range cases {
+		r := gin.New()
+		r.POST("/admin/reload", LoopbackWithoutKeys(func() []string { return c.keys }), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		req.RemoteAddr = c.remoteAddr
+		if c.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("keys %v, remote %s, forwarded %q: got status %d, want %d", c.keys, c.remoteAddr, c.forwarded, w.Code, c.want)
+		}
+	}
+}
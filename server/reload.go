@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/server/middleware"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gin-gonic/gin"
+)
+
+// OnReload registers fn to be called with the config once it is reloaded.
+func (s *Service) OnReload(fn func(cfg *config.Config)) {
+	s.onReload = append(s.onReload, fn)
+}
+
+// Reload reads the config file again and applies it without dropping the
+// connections. The runner is restarted only when the options the model is
+// loaded with changed, once the requests in flight are drained.
+func (s *Service) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	cur := s.cfg.Load()
+	log.Info("Reloading config", "file", cur.ConfigFile)
+	next, err := cur.Reload()
+	if err != nil {
+		return err
+	}
+	if next.Host != cur.Host {
+		log.Warn("The host cannot change while serving, restart to apply it", "host", next.Host)
+		next.Host = cur.Host
+	}
+
+	if slices.Equal(next.Args(), cur.Args()) {
+		s.cfg.Store(next)
+	} else if err := s.restartRunner(cur, next); err != nil {
+		return err
+	}
+
+	cfg := s.cfg.Load()
+	cors := middleware.CORS(cfg.AllowedOrigins())
+	s.cors.Store(&cors)
+	s.admission.SetLimit(cfg.MaxRequests)
+	s.api.Reload()
+	for _, fn := range s.onReload {
+		fn(cfg)
+	}
+	log.Info("Reloaded config", "file", cfg.ConfigFile)
+	return nil
+}
+
+// restartRunner restarts the runner with the config next in place of prev,
+// holding the new requests until it is ready. The runner is started again
+// with prev if next fails to load.
+func (s *Service) restartRunner(prev, next *config.Config) error {
+	if err := next.Load(); err != nil {
+		return err
+	}
+	log.Info("Load options changed, restarting the runner once the requests in flight are drained")
+	s.admission.Pause()
+	defer s.admission.Resume()
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout())
+	defer cancel()
+	if err := s.admission.Drain(ctx); err != nil {
+		return fmt.Errorf("requests in flight did not complete within the drain timeout, the runner was not restarted")
+	}
+
+	if s.runnerSer.IsRunning() {
+		if err := s.runnerSer.Stop(); err != nil {
+			return err
+		}
+	}
+	s.cfg.Store(next)
+	err := s.runnerSer.Start()
+	if err == nil {
+		return nil
+	}
+	log.Error("Failed to restart the runner, starting it again with the previous config", "error", err)
+	s.cfg.Store(prev)
+	if err := s.runnerSer.Start(); err != nil {
+		log.Error(err.Error())
+	}
+	return fmt.Errorf("restart the runner: %w", err)
+}
+
+func (s *Service) ReloadHandler(c *gin.Context) {
+	if err := s.Reload(); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "reloaded"})
+}
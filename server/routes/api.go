@@ -1,25 +1,48 @@
 package routes
 
 import (
+	"sync/atomic"
+
 	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model/store"
 	"github.com/Qitmeer/llama.go/runner"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/gin-gonic/gin"
 )
 
 type API struct {
-	cfg       *config.Config
+	// cfg is replaced as a whole when the config is reloaded, each request
+	// loads it once and reads that snapshot throughout
+	cfg       *atomic.Pointer[config.Config]
 	runnerSer *runner.Service
+	models    *store.Store
+	draft     draftStats
+	draining  atomic.Bool
 }
 
-func New(cfg *config.Config, runnerSer *runner.Service) *API {
+func New(cfg *atomic.Pointer[config.Config], runnerSer *runner.Service) *API {
 	log.Info("New API ...")
-	ser := API{cfg: cfg, runnerSer: runnerSer}
+	ser := API{cfg: cfg, runnerSer: runnerSer, models: cfg.Load().Models()}
 	return &ser
 }
 
 func (s *API) Start() error {
-	return nil
+	if s.cfg.Load().NoPrune {
+		return nil
+	}
+	log.Info("Pruning unused model blobs")
+	return s.models.Prune()
+}
+
+// Drain makes the health checks report that the server is draining, so that
+// load balancers stop sending it requests.
+func (s *API) Drain() {
+	s.draining.Store(true)
+}
+
+// Reload applies the settings of the config which can change while serving.
+func (s *API) Reload() {
+	s.models.WithAliases(s.cfg.Load().Aliases)
 }
 
 func (s *API) Setup(r *gin.Engine) {
@@ -30,6 +53,9 @@ func (s *API) Setup(r *gin.Engine) {
 	r.GET("/api/version", s.VersionHandler)
 
 	r.POST("/api/pull", s.PullHandler)
+	r.POST("/api/create", s.CreateHandler)
+	r.POST("/api/copy", s.CopyHandler)
+	r.DELETE("/api/delete", s.DeleteHandler)
 	r.HEAD("/api/tags", s.ListHandler)
 	r.GET("/api/tags", s.ListHandler)
 	r.HEAD("/api/models", s.ListHandler)
@@ -39,17 +65,25 @@ func (s *API) Setup(r *gin.Engine) {
 	r.GET("/props", s.PropsHandler)
 	r.POST("/props", s.PropsChangeHandler)
 	r.GET("/slots", s.SlotsHandler)
+	r.GET("/metrics", s.MetricsHandler)
+	r.GET("/lora-adapters", s.LoraAdaptersHandler)
+	r.POST("/lora-adapters", s.LoraAdaptersChangeHandler)
 
 	r.POST("/api/generate", s.GenerateHandler)
 	r.POST("/api/chat", s.ChatHandler)
+	r.POST("/infill", s.InfillHandler)
 	r.POST("/api/embed", s.EmbedHandler)
 	r.POST("/api/embeddings", s.EmbeddingsHandler)
+	r.POST("/api/rerank", s.RerankHandler)
+	r.GET("/api/audio/stream", s.AudioStreamHandler)
 
 	// Inference (OpenAI compatibility)
 	r.POST("/v1/completions", s.GenerateHandler)
 	r.POST("/v1/chat/completions", s.ChatHandler)
 
 	r.POST("/v1/embeddings", EmbeddingsMiddleware(), s.EmbedHandler)
+	r.POST("/v1/rerank", s.RerankHandler)
+	r.POST("/v1/audio/transcriptions", s.TranscriptionHandler)
 	r.GET("/v1/models", s.V1ModelsWebUIHandler)
 	r.GET("/v1/models/:model", RetrieveMiddleware(), s.ShowHandler)
 
@@ -63,3 +97,9 @@ func (s *API) Setup(r *gin.Engine) {
 	r.GET("/index.html", s.IndexHandler)
 	r.HEAD("/index.html", s.IndexHandler)
 }
+
+// modelPath returns the GGUF file of the model called name, resolved through
+// the store of the API so that a request does not read the store again.
+func (s *API) modelPath(cfg *config.Config, name string) string {
+	return cfg.ResolveModelPath(s.models, name)
+}
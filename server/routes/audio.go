@@ -0,0 +1,290 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/common/audio"
+	"github.com/Qitmeer/llama.go/common/transcript"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/wrapper"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+var transcriptionFormats = []string{"json", "text", "srt", "vtt", "verbose_json"}
+
+// maxTranscriptionSize bounds the size of a transcription request, as the
+// 25 MB limit of the OpenAI files.
+const maxTranscriptionSize = 25 << 20
+
+// maxTranscriptionDuration bounds the length of the audio of a transcription
+// request, which a compressed file could otherwise decode to gigabytes of
+// samples.
+const maxTranscriptionDuration = 30 * time.Minute
+
+// whisperSlots caps the transcriptions the whisper core runs at once, each
+// with a state of its own and up to 4 threads, which the partials of the
+// audio streams would otherwise pile up.
+var whisperSlots = make(chan struct{}, max(1, runtime.NumCPU()/4))
+
+// transcribe runs the whisper transcription of the JSON request jsonStr once
+// a slot is free, returning HTTP status and JSON body as the core.
+func transcribe(ctx context.Context, jsonStr string, samples []float32) (int, string, error) {
+	select {
+	case whisperSlots <- struct{}{}:
+	case <-ctx.Done():
+		return 0, "", context.Cause(ctx)
+	}
+	defer func() { <-whisperSlots }()
+	status, body := wrapper.WhisperTranscribeHTTP(jsonStr, samples)
+	return status, body, nil
+}
+
+// TranscriptionHandler transcribes the audio file of an OpenAI style
+// multipart request with whisper.
+func (s *API) TranscriptionHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTranscriptionSize)
+	fh, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("the request must be at most %d bytes", tooLarge.Limit)})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	format := c.DefaultPostForm("response_format", "json")
+	if !slices.Contains(transcriptionFormats, format) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "response_format must be one of " + strings.Join(transcriptionFormats, ", ")})
+		return
+	}
+	var temperature float64
+	if t := c.PostForm("temperature"); len(t) > 0 {
+		temperature, err = strconv.ParseFloat(t, 32)
+		if err != nil || temperature < 0 || temperature > 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "temperature must be between 0 and 1"})
+			return
+		}
+	}
+	modelPath, err := s.whisperModel(cfg, c.PostForm("model"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	samples, err := audio.Decode(f, maxTranscriptionDuration)
+	f.Close()
+	if errors.Is(err, audio.ErrTooLong) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("the audio must be at most %v long", maxTranscriptionDuration)})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	words := slices.Contains(c.PostFormArray("timestamp_granularities[]"), "word")
+	bts, err := json.Marshal(map[string]any{
+		"model":           modelPath,
+		"language":        c.PostForm("language"),
+		"prompt":          c.PostForm("prompt"),
+		"temperature":     temperature,
+		"word_timestamps": words && format == "verbose_json",
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status, jsonStr, err := transcribe(c.Request.Context(), string(bts), samples)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if status != http.StatusOK {
+		c.AbortWithStatusJSON(status, gin.H{"error": transcript.ErrorMessage(jsonStr)})
+		return
+	}
+	var resp api.TranscriptionResponse
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp.Text = strings.TrimSpace(resp.Text)
+
+	var buf bytes.Buffer
+	switch format {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"text": resp.Text})
+	case "verbose_json":
+		resp.Task = "transcribe"
+		if resp.Segments == nil {
+			resp.Segments = []api.TranscriptionSegment{}
+		}
+		c.JSON(http.StatusOK, resp)
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(resp.Text+"\n"))
+	case "srt":
+		transcript.WriteSRT(&buf, resp.Segments)
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+	case "vtt":
+		transcript.WriteVTT(&buf, resp.Segments)
+		c.Data(http.StatusOK, "text/vtt; charset=utf-8", buf.Bytes())
+	}
+}
+
+// whisperModel returns the whisper model file of the model of a request.
+// Only the --whisper-model of the server is served, which the names other
+// than a model file, such as whisper-1, stand for.
+func (s *API) whisperModel(cfg *config.Config, name string) (string, error) {
+	path := cfg.WhisperModelPath()
+	if len(path) <= 0 {
+		return "", errors.New("no whisper model, start the server with --whisper-model")
+	}
+	if len(name) > 0 && !common.IsFilePath(name) {
+		other := filepath.Join(cfg.ModelDir, name)
+		if info, err := os.Stat(other); err == nil && !info.IsDir() && !sameFile(other, path) {
+			return "", fmt.Errorf("model %s is not served, the server transcribes with %s", name, cfg.WhisperModel)
+		}
+	}
+	return path, nil
+}
+
+// wsFrame is a WebSocket message with its type, websocket.BinaryFrame or
+// websocket.TextFrame.
+type wsFrame struct {
+	typ  byte
+	data []byte
+}
+
+var wsFrameCodec = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		f := v.(*wsFrame)
+		f.typ, f.data = payloadType, data
+		return nil
+	},
+}
+
+// AudioStreamHandler transcribes the audio of a WebSocket as it is spoken.
+// The client sends binary messages of 16 bits little-endian mono PCM at the
+// sample_rate of the query, 16 kHz by default, and a text message to end the
+// stream. The segments of the speech are sent back as JSON, partial while
+// they are spoken and final once they ended.
+func (s *API) AudioStreamHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
+	modelPath, err := s.whisperModel(cfg, c.Query("model"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate := audio.SampleRate
+	if r := c.Query("sample_rate"); len(r) > 0 {
+		rate, err = strconv.Atoi(r)
+		if err != nil || rate < 8000 || rate > 192000 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "sample_rate must be between 8000 and 192000"})
+			return
+		}
+	}
+	if err := wrapper.WhisperLoad(modelPath); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	language := c.Query("language")
+	ctx := c.Request.Context()
+
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		// the server cuts the stream off when it shuts down
+		go func() {
+			<-ctx.Done()
+			ws.Close()
+		}()
+
+		// the last final prompts the transcription of the next segment
+		var prompt string
+		stream := transcript.NewStream(transcript.DefaultStreamOptions(), func(samples []float32) (string, error) {
+			bts, err := json.Marshal(map[string]any{
+				"model":          modelPath,
+				"language":       language,
+				"prompt":         prompt,
+				"single_segment": true,
+			})
+			if err != nil {
+				return "", err
+			}
+			status, jsonStr, err := transcribe(ctx, string(bts), samples)
+			if err != nil {
+				return "", err
+			}
+			if status != http.StatusOK {
+				return "", errors.New(transcript.ErrorMessage(jsonStr))
+			}
+			var resp api.TranscriptionResponse
+			if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+				return "", err
+			}
+			return resp.Text, nil
+		}, func(resp api.TranscriptionStreamResponse) error {
+			if resp.Type == "final" {
+				prompt = resp.Text
+			}
+			return websocket.JSON.Send(ws, resp)
+		})
+
+		// the resampler carries the filter across the messages
+		resampler := audio.NewResampler(rate, audio.SampleRate)
+		end := func() error {
+			if err := stream.Write(resampler.Flush()); err != nil {
+				return err
+			}
+			return stream.Flush()
+		}
+		err := func() error {
+			// an odd byte waiting for the rest of its sample
+			var odd []byte
+			for {
+				var f wsFrame
+				if err := wsFrameCodec.Receive(ws, &f); errors.Is(err, io.EOF) {
+					return end()
+				} else if err != nil {
+					return err
+				}
+				if f.typ != websocket.BinaryFrame {
+					return end()
+				}
+				pcm := append(odd, f.data...)
+				odd = slices.Clone(pcm[len(pcm)&^1:])
+				samples := make([]float32, len(pcm)/2)
+				for i := range samples {
+					samples[i] = float32(int16(binary.LittleEndian.Uint16(pcm[2*i:]))) / (1 << 15)
+				}
+				if err := stream.Write(resampler.Write(samples)); err != nil {
+					return err
+				}
+			}
+		}()
+		if err != nil && ctx.Err() == nil {
+			websocket.JSON.Send(ws, api.TranscriptionStreamResponse{Type: "error", Error: err.Error()})
+		}
+	}}.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+	"github.com/gin-gonic/gin"
+)
+
+func TestWhisperModel(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ggml-base.bin", "ggml-large.bin"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{ModelDir: dir, WhisperModel: "ggml-base.bin"}
+	want := cfg.WhisperModelPath()
+
+	var s API
+	for _, name := range []string{"", "whisper-1", "ggml-base.bin"} {
+		if got, err := s.whisperModel(cfg, name); err != nil || got != want {
+			t.Errorf("whisperModel(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := s.whisperModel(cfg, "ggml-large.bin"); err == nil || !strings.Contains(err.Error(), "is not served") {
+		t.Errorf("expected another model file to be rejected, got %v", err)
+	}
+	if _, err := s.whisperModel(&config.Config{ModelDir: dir}, "ggml-base.bin"); err == nil {
+		t.Error("expected an error without --whisper-model")
+	}
+}
+
+func TestTranscriptionHandlerTooLarge(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "speech.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(make([]byte, maxTranscriptionSize))
+	mw.Close()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &body)
+	c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	testAPI(t, ggml.KV{}).TranscriptionHandler(c)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body)
+	}
+}
+
+func TestTranscribeSlots(t *testing.T) {
+	for range cap(whisperSlots) {
+		whisperSlots <- struct{}{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := transcribe(ctx, "{}", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation while the slots are taken, got %v", err)
+	}
+	for range cap(whisperSlots) {
+		<-whisperSlots
+	}
+
+	if _, _, err := transcribe(context.Background(), "{}", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(whisperSlots); n != 0 {
+		t.Errorf("%d slots still taken after the transcription", n)
+	}
+}
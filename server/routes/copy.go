@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model/store"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gin-gonic/gin"
+)
+
+// CopyHandler copies a named model. Other models, i.e. GGUF files and aliases
+// of them, get the destination as an alias instead since their weights are
+// not kept by the store.
+func (s *API) CopyHandler(c *gin.Context) {
+	var req api.CopyRequest
+	err := c.ShouldBindJSON(&req)
+	switch {
+	case errors.Is(err, io.EOF):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := store.ParseName(req.Destination); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	src := s.models.Resolve(req.Source)
+	if _, err := s.models.Get(src); err == nil {
+		if err := s.models.Copy(src, req.Destination); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	} else if !errors.Is(err, store.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	path := s.modelPath(s.cfg.Load(), src)
+	if path == "" || !common.IsExist(path) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model '%s' not found", req.Source)})
+		return
+	}
+	// files of the model directory are kept by name so that the alias
+	// follows the directory
+	target := src
+	if common.IsFilePath(src) {
+		if target, err = filepath.Abs(src); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := s.models.SetAlias(req.Destination, target); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// listAliases lists the aliases along with the size of the models they stand
+// for. Aliases of missing models are left out.
+func (s *API) listAliases() []api.ListModelResponse {
+	cfg := s.cfg.Load()
+	aliases, err := s.models.Aliases()
+	if err != nil {
+		log.Error(err.Error())
+		return nil
+	}
+
+	var ret []api.ListModelResponse
+	for alias, target := range aliases {
+		resp := api.ListModelResponse{
+			Model: alias,
+			Name:  alias,
+			Details: api.ModelDetails{
+				ParentModel: target,
+				Format:      config.EXT[1:],
+			},
+		}
+		if m, err := s.models.Get(s.models.Resolve(alias)); err == nil {
+			resp.Size = m.Size
+			resp.Digest = strings.TrimPrefix(m.Digest, "sha256:")
+			resp.ModifiedAt = m.ModifiedAt
+		} else if info, err := os.Stat(s.modelPath(cfg, alias)); err == nil {
+			resp.Size = info.Size()
+			resp.ModifiedAt = info.ModTime()
+		} else {
+			continue
+		}
+		ret = append(ret, resp)
+	}
+	return ret
+}
@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/common"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/model/store"
+	"github.com/Qitmeer/llama.go/model/template"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateHandler registers a named model made of a base model and the
+// settings of a Modelfile.
+func (s *API) CreateHandler(c *gin.Context) {
+	var req api.CreateRequest
+	err := c.ShouldBindJSON(&req)
+	switch {
+	case errors.Is(err, io.EOF):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, err := store.ParseName(req.Model)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := s.newModel(name, &req)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ch := make(chan any)
+	go func() {
+		defer close(ch)
+		ch <- api.ProgressResponse{Status: fmt.Sprintf("using base model %s", m.Path)}
+		ch <- api.ProgressResponse{Status: fmt.Sprintf("writing model %s", m.Name)}
+		if err := s.models.Create(m); err != nil {
+			ch <- gin.H{"error": err.Error()}
+			return
+		}
+		ch <- api.ProgressResponse{Status: "success"}
+	}()
+
+	if req.Stream != nil && !*req.Stream {
+		waitForStream(c, ch)
+		return
+	}
+	streamHandler(c, ch)
+}
+
+// DeleteHandler removes a named model along with the blobs no other model
+// uses, or an alias. GGUF files of the model directory are never removed.
+func (s *API) DeleteHandler(c *gin.Context) {
+	var req api.DeleteRequest
+	err := c.ShouldBindJSON(&req)
+	switch {
+	case errors.Is(err, io.EOF):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.models.Delete(req.Model); errors.Is(err, store.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model '%s' not found", req.Model)})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// newModel builds the named model described by req. A model created from
+// another named model inherits its settings.
+func (s *API) newModel(name store.Name, req *api.CreateRequest) (*store.Model, error) {
+	if req.From == "" {
+		return nil, errors.New("from is required")
+	}
+
+	m := &store.Model{}
+	base, err := s.models.Get(s.models.Resolve(req.From))
+	switch {
+	case err == nil:
+		*m = *base
+		m.Parameters = maps.Clone(base.Parameters)
+	case errors.Is(err, store.ErrNotFound):
+		path := req.From
+		if !common.IsFilePath(path) {
+			path = s.modelPath(s.cfg.Load(), path)
+		}
+		if path == "" || !common.IsExist(path) {
+			return nil, fmt.Errorf("base model %q not found", req.From)
+		}
+		if _, err := model.LoadGGML(path); err != nil {
+			return nil, fmt.Errorf("base model %q is not a GGUF model: %w", req.From, err)
+		}
+		if m.Path, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	m.Name = name
+	m.From = req.From
+
+	if len(req.Adapters) > 0 {
+		m.Adapters = req.Adapters
+	}
+	m.Template = cmp.Or(req.Template, m.Template)
+	m.System = cmp.Or(req.System, m.System)
+	m.License = append(m.License, req.License...)
+	m.Renderer = cmp.Or(req.Renderer, m.Renderer)
+	m.Parser = cmp.Or(req.Parser, m.Parser)
+	if len(req.Parameters) > 0 {
+		if m.Parameters == nil {
+			m.Parameters = make(map[string]any, len(req.Parameters))
+		}
+		maps.Copy(m.Parameters, req.Parameters)
+	}
+	if len(req.Messages) > 0 {
+		m.Messages = make([]store.Message, 0, len(req.Messages))
+		for _, msg := range req.Messages {
+			m.Messages = append(m.Messages, store.Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+
+	for _, adapter := range m.Adapters {
+		if _, err := os.Stat(adapter); err != nil {
+			return nil, fmt.Errorf("adapter %q: %w", adapter, err)
+		}
+	}
+	if m.Template != "" {
+		if _, err := template.Parse(m.Template); err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	opts := api.DefaultOptions()
+	if err := opts.FromMap(m.Parameters); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	for key := range m.Parameters {
+		if _, ok := coreParams[key]; !ok {
+			log.Warn("Parameter is only applied when the model is loaded", "model", m.Name, "parameter", key)
+		}
+	}
+	for _, msg := range m.Messages {
+		if !slices.Contains([]string{"system", "user", "assistant"}, msg.Role) {
+			return nil, fmt.Errorf("invalid message role %q", msg.Role)
+		}
+	}
+	return m, nil
+}
+
+// resolveModel returns the model called name, which must stand for the GGUF
+// the server is started with, as the core generates with it whatever the
+// request names. GGUF files and unknown names, which fall back to that
+// model, carry no settings.
+func (s *API) resolveModel(cfg *config.Config, name string) (*store.Model, error) {
+	served := s.modelPath(cfg, cfg.Model)
+	m := &store.Model{From: name}
+	if name != "" {
+		var err error
+		m, err = s.models.Get(s.models.Resolve(name))
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			m = &store.Model{From: name}
+			if path := s.modelPath(cfg, name); len(path) > 0 && !sameFile(path, served) {
+				return nil, fmt.Errorf("model %s is not served, the server runs %s", name, cfg.Model)
+			}
+		case err != nil:
+			log.Warn("Failed to read model", "model", name, "error", err)
+			m = &store.Model{From: name}
+		case !sameFile(m.Path, served):
+			return nil, fmt.Errorf("model %s is not served, the server runs %s", name, cfg.Model)
+		}
+	}
+	// the metadata checks are run against the loaded GGUF
+	m.Path = served
+	return m, nil
+}
+
+// formatParameters lists the parameters of a model one per line, as shown by
+// /api/show.
+func formatParameters(params map[string]any) string {
+	var lines []string
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		switch v := params[key].(type) {
+		case []any:
+			for _, s := range v {
+				lines = append(lines, fmt.Sprintf("%-30s %#v", key, s))
+			}
+		default:
+			lines = append(lines, fmt.Sprintf("%-30s %#v", key, v))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// storeMessages converts the seed messages of a model.
+func storeMessages(msgs []store.Message) []api.Message {
+	ret := make([]api.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		ret = append(ret, api.Message{Role: msg.Role, Content: msg.Content})
+	}
+	return ret
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+func TestResolveModel(t *testing.T) {
+	s := testAPI(t, ggml.KV{})
+	cfg := s.cfg.Load()
+	if err := os.WriteFile(filepath.Join(cfg.ModelDir, "other.gguf"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown names fall back to the served model, as OpenAI model names
+	for _, name := range []string{"", "model.gguf", cfg.Model, "gpt-4o"} {
+		m, err := s.resolveModel(cfg, name)
+		if err != nil {
+			t.Errorf("resolveModel(%q): %v", name, err)
+			continue
+		}
+		if m.Path != cfg.ModelPath() {
+			t.Errorf("resolveModel(%q) = %s, want %s", name, m.Path, cfg.ModelPath())
+		}
+	}
+	for _, name := range []string{"other.gguf", "missing.gguf"} {
+		if _, err := s.resolveModel(cfg, name); err == nil || !strings.Contains(err.Error(), "is not served") {
+			t.Errorf("resolveModel(%q): got %v, want a model not served", name, err)
+		}
+	}
+}
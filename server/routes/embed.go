@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/runner"
+)
+
+// errInputTooLong is returned for an input longer than the context of the
+// embedding when it must not be truncated.
+var errInputTooLong = errors.New("input length exceeds the context length")
+
+// embedInput is an input to embed, either a text or the ids of its tokens
+// when it was sent pre-tokenized.
+type embedInput struct {
+	text   string
+	tokens []int
+}
+
+// embedInputs returns the inputs of an embedding request: a string, an array
+// of token ids, or an array of strings and arrays of token ids.
+func embedInputs(v any) ([]embedInput, error) {
+	switch i := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if len(i) <= 0 {
+			return nil, nil
+		}
+		return []embedInput{{text: i}}, nil
+	case []any:
+		if tokens, ok := tokenIDs(i); ok {
+			return []embedInput{{tokens: tokens}}, nil
+		}
+		ret := make([]embedInput, 0, len(i))
+		for _, v := range i {
+			if s, ok := v.(string); ok {
+				ret = append(ret, embedInput{text: s})
+				continue
+			}
+			tokens, ok := tokenIDs(v)
+			if !ok {
+				return nil, errors.New("invalid input type")
+			}
+			ret = append(ret, embedInput{tokens: tokens})
+		}
+		return ret, nil
+	}
+	return nil, errors.New("invalid input type")
+}
+
+// embedLimit returns the max number of tokens of an input, which is embedded
+// within a single physical batch and the context of a single slot. The
+// context is split among the slots when --parallel is set, and shared by
+// them otherwise.
+func (s *API) embedLimit(cfg *config.Config) int {
+	nCtx := cfg.CtxSize
+	if nCtx <= 0 {
+		if g, err := model.LoadGGML(s.modelPath(cfg, cfg.Model)); err == nil {
+			nCtx = int(g.KV().ContextLength())
+		}
+	}
+	if cfg.Parallel > 1 {
+		nCtx /= cfg.Parallel
+	}
+
+	limit := cfg.UBatchSize
+	if nCtx > 0 && nCtx < limit {
+		limit = nCtx
+	}
+	return limit
+}
+
+// embeddingLength returns the number of dimensions of the embeddings of the
+// model at path.
+func embeddingLength(path string) (int, error) {
+	g, err := model.LoadGGML(path)
+	if err != nil {
+		return 0, err
+	}
+	n := int(g.KV().EmbeddingLength())
+	if n <= 0 {
+		return 0, fmt.Errorf("%s has no embedding length", path)
+	}
+	return n, nil
+}
+
+// fitInputs returns the inputs fitting within limit tokens along with the
+// number of their tokens. The inputs longer than limit are truncated when
+// truncate is set, otherwise errInputTooLong is returned.
+func fitInputs(input []embedInput, limit int, truncate bool) ([]embedInput, int, error) {
+	ret := make([]embedInput, 0, len(input))
+	count := 0
+	for i, in := range input {
+		if in.tokens != nil {
+			if len(in.tokens) > limit {
+				if !truncate {
+					return nil, 0, fmt.Errorf("input %d: %w (%d > %d tokens)", i, errInputTooLong, len(in.tokens), limit)
+				}
+				in.tokens = in.tokens[:limit]
+			}
+			ret = append(ret, in)
+			count += len(in.tokens)
+			continue
+		}
+
+		tokens, err := tokenize(in.text, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(tokens) <= limit {
+			ret = append(ret, in)
+			count += len(tokens)
+			continue
+		}
+		if !truncate {
+			return nil, 0, fmt.Errorf("input %d: %w (%d > %d tokens)", i, errInputTooLong, len(tokens), limit)
+		}
+		// the special tokens are added again when embedding
+		text, err := tokenize(in.text, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		keep := max(limit-(len(tokens)-len(text)), 0)
+		for {
+			truncated, err := detokenize(text[:keep])
+			if err != nil {
+				return nil, 0, err
+			}
+			// the truncated text may not tokenize as it was cut, e.g. when
+			// it was cut within a word, so it is counted again
+			if tokens, err = tokenize(truncated, true); err != nil {
+				return nil, 0, err
+			}
+			if len(tokens) <= limit || keep == 0 {
+				ret = append(ret, embedInput{text: truncated})
+				count += len(tokens)
+				break
+			}
+			keep = max(keep-(len(tokens)-limit), 0)
+		}
+	}
+	return ret, count, nil
+}
+
+// embed returns the embeddings of the inputs, each a text or the ids of its
+// tokens.
+func (s *API) embed(input []embedInput) ([][]float32, error) {
+	prompts := make([]any, 0, len(input))
+	for _, in := range input {
+		if in.tokens != nil {
+			prompts = append(prompts, in.tokens)
+		} else {
+			prompts = append(prompts, in.text)
+		}
+	}
+	// euclidean norm, as the OpenAI embeddings
+	return s.runnerSer.Embed(prompts, 2)
+}
+
+// embedStatus returns the HTTP status of the error err of embed, a client
+// error when the server is not set up for the embeddings.
+func embedStatus(err error) int {
+	switch {
+	case errors.Is(err, runner.ErrEmbeddingsDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, runner.ErrTokenEmbeddings):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// truncateDimensions keeps the first dims dimensions of the embedding e,
+// renormalized to unit length as Matryoshka embeddings expect.
+func truncateDimensions(e []float32, dims int) []float32 {
+	if dims <= 0 || dims >= len(e) {
+		return e
+	}
+	return normalize(e[:dims])
+}
+
+func normalize(e []float32) []float32 {
+	var sum float64
+	for _, v := range e {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return e
+	}
+	norm := float32(1 / math.Sqrt(sum))
+	for i := range e {
+		e[i] *= norm
+	}
+	return e
+}
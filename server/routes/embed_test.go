@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+func TestEmbedLimit(t *testing.T) {
+	s := testAPI(t, ggml.KV{"test.context_length": uint32(2048)})
+	cases := []struct {
+		ctx, parallel, ubatch int
+		want                  int
+	}{
+		{ctx: 8192, ubatch: 512, want: 512},
+		{ctx: 256, ubatch: 512, want: 256},
+		{ctx: 1024, parallel: 4, ubatch: 512, want: 256},
+		{ctx: 4096, parallel: 4, ubatch: 512, want: 512},
+		{parallel: 8, ubatch: 512, want: 256},
+		{ubatch: 4096, want: 2048},
+	}
+
+	for _, c := range cases {
+		cfg := *s.cfg.Load()
+		cfg.CtxSize, cfg.Parallel, cfg.UBatchSize = c.ctx, c.parallel, c.ubatch
+		if got := s.embedLimit(&cfg); got != c.want {
+			t.Errorf("ctx %d, parallel %d, ubatch %d: got %d, want %d", c.ctx, c.parallel, c.ubatch, got, c.want)
+		}
+	}
+}
+
+func TestEmbedHandlerDimensions(t *testing.T) {
+	s := testAPI(t, ggml.KV{"test.embedding_length": uint32(384)})
+	cases := []struct {
+		body string
+		want string
+	}{
+		{`{"input":"hello","dimensions":-1}`, "dimensions must be positive"},
+		{`{"input":"hello","dimensions":385}`, "dimensions must be at most 384, got 385"},
+	}
+
+	for _, c := range cases {
+		w := serve(t, s.EmbedHandler, c.body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), c.want) {
+			t.Errorf("%s: got %d %s, want %d %q", c.body, w.Code, w.Body, http.StatusBadRequest, c.want)
+		}
+	}
+}
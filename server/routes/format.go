@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Qitmeer/llama.go/model/grammar"
+)
+
+// responseFormat is the OpenAI structured output request field.
+type responseFormat struct {
+	Type       string `json:"type"`
+	JsonSchema *struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// formatGrammar returns the GBNF grammar enforcing either the llama.go format
+// field or the OpenAI response_format of the raw request body. Schemas are
+// read from the raw body so that the order of their properties is kept.
+func formatGrammar(raw []byte, format json.RawMessage) (string, error) {
+	if g, err := grammar.FromFormat(format); err != nil || g != "" {
+		return g, err
+	}
+
+	var req struct {
+		ResponseFormat *responseFormat `json:"response_format"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return "", err
+	}
+
+	rf := req.ResponseFormat
+	if rf == nil {
+		return "", nil
+	}
+	switch rf.Type {
+	case "", "text":
+		return "", nil
+	case "json_object":
+		if len(rf.Schema) > 0 {
+			return grammar.FromSchema(rf.Schema)
+		}
+		return grammar.JSON, nil
+	case "json_schema":
+		if rf.JsonSchema == nil || len(rf.JsonSchema.Schema) == 0 {
+			return "", errors.New("response_format json_schema requires a schema")
+		}
+		return grammar.FromSchema(rf.JsonSchema.Schema)
+	}
+	return "", fmt.Errorf("unsupported response_format type %q", rf.Type)
+}
+
+// applyGrammar replaces the structured output fields of a chat body with the
+// grammar enforcing them, since the core rejects a grammar along with them.
+func applyGrammar(body map[string]any, g string) error {
+	if g == "" {
+		return nil
+	}
+	if _, ok := body["grammar"]; ok {
+		return errors.New("cannot use both a format and a grammar")
+	}
+	if tools, ok := body["tools"].([]any); ok && len(tools) > 0 && body["tool_choice"] != "none" {
+		return errors.New("format cannot be used together with tools")
+	}
+
+	delete(body, "format")
+	delete(body, "response_format")
+	delete(body, "json_schema")
+	body["grammar"] = g
+	return nil
+}
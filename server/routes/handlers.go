@@ -8,16 +8,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/Qitmeer/llama.go/api"
-	config2 "github.com/Qitmeer/llama.go/app/embedding/config"
 	"github.com/Qitmeer/llama.go/config"
 	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/model/parser"
+	"github.com/Qitmeer/llama.go/model/store"
 	"github.com/Qitmeer/llama.go/version"
 	"github.com/Qitmeer/llama.go/wrapper"
 	"github.com/ethereum/go-ethereum/log"
@@ -33,6 +36,10 @@ func (s *API) VersionHandler(c *gin.Context) {
 }
 
 func (s *API) HealthHandler(c *gin.Context) {
+	if s.draining.Load() {
+		c.String(http.StatusServiceUnavailable, "Llama.go is draining")
+		return
+	}
 	c.String(http.StatusOK, "Llama.go is running")
 }
 
@@ -92,14 +99,36 @@ func (s *API) PsHandler(c *gin.Context) {
 }
 
 func (s *API) GenerateHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
 	bodyBytes, err := c.GetRawData()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	body, err := decodeBody(bodyBytes)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tokens, err := promptTokens(body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	//bodyStr := string(bodyBytes)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	if tokens != nil {
+		// the request binds a text prompt only
+		delete(body, "prompt")
+		bts, err := json.Marshal(body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(bts))
+	}
 
 	var req api.GenerateRequest
 	if err := c.ShouldBindJSON(&req); errors.Is(err, io.EOF) {
@@ -109,8 +138,74 @@ func (s *API) GenerateHandler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	m, err := s.resolveModel(cfg, req.Model)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if tokens != nil {
+		if err := checkTokens(m.Path, tokens); errors.Is(err, errInvalidToken) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	params, err := samplingParams(body, m.Parameters, req.Options)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	delete(body, "adapters")
+	lora, err := requestLora(m, req.Adapters)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if lora != nil {
+		params["lora"] = lora
+	}
+
+	g, err := formatGrammar(bodyBytes, req.Format)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if g != "" {
+		params["grammar"] = g
+	}
+
+	// a suffix turns the request into a fill-in-the-middle completion
+	insert := req.Suffix != ""
+	if insert && !model.SupportsInsert(m.Path) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%q does not support insert", req.Model)})
+		return
+	}
+	if insert && tokens != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insert takes a text prompt, not tokens"})
+		return
+	}
+
+	var prompt any = req.Prompt
+	if tokens != nil {
+		// a pre-tokenized prompt goes to the core as is, without template
+		prompt = tokens
+	} else if tmpl := s.generateTemplate(cfg, &req, m); tmpl != "" && !insert {
+		var msgs []api.Message
+		if system := cmp.Or(req.System, m.System); system != "" {
+			msgs = append(msgs, api.Message{Role: "system", Content: system})
+		}
+		msgs = append(msgs, storeMessages(m.Messages)...)
+		msgs = append(msgs, api.Message{Role: "user", Content: req.Prompt})
+		if prompt, err = renderPrompt(tmpl, msgs, req.Think); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	id, ch := wrapper.NewChan()
+	id, ch := wrapper.NewChan(c.Request.Context())
 	if id == 0 {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "task id error"})
 		return
@@ -120,17 +215,25 @@ func (s *API) GenerateHandler(c *gin.Context) {
 		stream = *req.Stream
 	}
 	go func() {
-		m := s.cfg.GetModelPath(req.Model)
-		if len(m) <= 0 {
-			m = s.cfg.ModelPath()
+		if insert {
+			params["input_prefix"] = req.Prompt
+			params["input_suffix"] = req.Suffix
+			params["stream"] = stream
+			err = s.runnerSer.Infill(id, m.Path, params)
+		} else {
+			err = s.runnerSer.Generate(id, m.Path, prompt, stream, params)
 		}
-		err = s.runnerSer.Generate(id, m, req.Prompt, stream)
 		if err != nil {
 			log.Warn(err.Error())
 			return
 		}
 	}()
 
+	var completion chunkFunc = s.draft.Chunk
+	if insert {
+		completion = chainChunkFuncs(infillCompletion(req.Model), s.draft.Chunk)
+	}
+
 	if !stream {
 		content := ""
 		for rr := range ch {
@@ -149,21 +252,22 @@ func (s *API) GenerateHandler(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid json"})
 			return
 		}
+		completion(ret)
 		c.JSON(http.StatusOK, ret)
 
 		return
 	}
-	streamHandler(c, ch)
+	streamHandler(c, filterStream(ch, completion))
 }
 
 func (s *API) ChatHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
 	bodyBytes, err := c.GetRawData()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	bodyStr := string(bodyBytes)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	var req api.ChatRequest
@@ -175,49 +279,179 @@ func (s *API) ChatHandler(c *gin.Context) {
 		return
 	}
 
-	id, ch := wrapper.NewChan()
+	if !req.Think.IsValid() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid think value: %v", req.Think.Value)})
+		return
+	}
+
+	body, err := decodeBody(bodyBytes)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	g, err := formatGrammar(bodyBytes, req.Format)
+	if err == nil {
+		err = applyGrammar(body, g)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m, err := s.resolveModel(cfg, req.Model)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seedMessages(body, &req, m)
+
+	params, err := samplingParams(body, m.Parameters, req.Options)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	delete(body, "adapters")
+	lora, err := requestLora(m, req.Adapters)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if lora != nil {
+		params["lora"] = lora
+	}
+	if m.Template != "" {
+		if g, ok := body["grammar"]; ok {
+			params["grammar"] = g
+		}
+		s.templateChat(c, cfg, m, &req, params)
+		return
+	}
+	maps.Copy(body, params)
+
+	if err := applyToolChoice(body, &req, cfg.Jinja); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tools := newToolCallValidator(&req)
+
+	chatTemplate := s.chatTemplate(cfg, m.Path)
+	applyThink(body, req.Think, chatTemplate)
+
+	bodyStr, err := json.Marshal(body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reasoning := newReasoningFilter(chatTemplate, renderedPrompt(bodyStr), req.Think, c.FullPath() == "/api/chat")
+
+	if req.Stream == nil || !*req.Stream {
+		messages, _ := body["messages"].([]any)
+		var ret map[string]any
+		for attempt := 0; ; attempt++ {
+			ret, err = s.chatCompletion(c.Request.Context(), m.Path, string(bodyStr))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if err = tools.Validate(ret); err == nil {
+				break
+			}
+			if attempt >= maxToolCallRetries {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			log.Warn("Invalid tool call, retrying", "attempt", attempt+1, "error", err)
+			retryToolCall(body, messages, err)
+			if bodyStr, err = json.Marshal(body); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		reasoning.Chunk(ret)
+		s.draft.Chunk(ret)
+		c.JSON(http.StatusOK, ret)
+
+		return
+	}
+
+	id, ch := wrapper.NewChan(c.Request.Context())
 	if id == 0 {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "task id error"})
 		return
 	}
 	go func() {
-		m := s.cfg.GetModelPath(req.Model)
-		if len(m) <= 0 {
-			m = s.cfg.ModelPath()
-		}
-		err = s.runnerSer.Chat(id, m, bodyStr)
+		err := s.runnerSer.Chat(id, m.Path, string(bodyStr))
 		if err != nil {
 			log.Warn(err.Error())
 			return
 		}
 	}()
+	streamHandler(c, filterStream(ch, chainChunkFuncs(tools.Chunk, reasoning.Chunk, s.draft.Chunk)))
+}
 
-	if req.Stream == nil || !*req.Stream {
-		content := ""
-		for rr := range ch {
-			str, ok := rr.(string)
-			if !ok {
-				continue
-			}
-			content += str
-		}
-		if len(content) <= 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "no content"})
+// templateChat serves a chat with a model whose Modelfile has a TEMPLATE: the
+// messages are formatted in Go and the core completes the resulting prompt.
+func (s *API) templateChat(c *gin.Context, cfg *config.Config, m *store.Model, req *api.ChatRequest, params map[string]any) {
+	if len(req.Tools) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "tools are not supported by models with a Modelfile template"})
+		return
+	}
+	prompt, err := renderPrompt(m.Template, req.Messages, req.Think)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reasoning := newReasoningFilter(s.chatTemplate(cfg, m.Path), prompt, req.Think, c.FullPath() == "/api/chat")
+
+	stream := req.Stream != nil && *req.Stream
+	id, ch := wrapper.NewChan(c.Request.Context())
+	if id == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "task id error"})
+		return
+	}
+	go func() {
+		err := s.runnerSer.Generate(id, m.Path, prompt, stream, params)
+		if err != nil {
+			log.Warn(err.Error())
 			return
 		}
-		var ret map[string]interface{}
-		if err := json.Unmarshal([]byte(content), &ret); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid json"})
+	}()
+
+	if !stream {
+		ret, err := collectResponse(c.Request.Context(), ch)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		completionAsChat(false)(ret)
+		reasoning.Chunk(ret)
+		s.draft.Chunk(ret)
 		c.JSON(http.StatusOK, ret)
-
 		return
 	}
-	streamHandler(c, ch)
+	streamHandler(c, filterStream(ch, chainChunkFuncs(completionAsChat(true), reasoning.Chunk, s.draft.Chunk)))
+}
+
+// chatCompletion runs a non-streamed chat completion and returns its response.
+func (s *API) chatCompletion(ctx context.Context, m string, body string) (map[string]any, error) {
+	id, ch := wrapper.NewChan(ctx)
+	if id == 0 {
+		return nil, errors.New("task id error")
+	}
+	go func() {
+		err := s.runnerSer.Chat(id, m, body)
+		if err != nil {
+			log.Warn(err.Error())
+			return
+		}
+	}()
+
+	return collectResponse(ctx, ch)
 }
 
 func (s *API) EmbedHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
 	checkpointStart := time.Now()
 	var req api.EmbedRequest
 	err := c.ShouldBindJSON(&req)
@@ -230,24 +464,20 @@ func (s *API) EmbedHandler(c *gin.Context) {
 		return
 	}
 
-	var input []string
-
-	switch i := req.Input.(type) {
-	case string:
-		if len(i) > 0 {
-			input = append(input, i)
-		}
-	case []any:
-		for _, v := range i {
-			if _, ok := v.(string); !ok {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid input type"})
-				return
-			}
-			input = append(input, v.(string))
+	input, err := embedInputs(req.Input)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, in := range input {
+		if in.tokens == nil {
+			continue
 		}
-	default:
-		if req.Input != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid input type"})
+		if err := checkTokens(s.modelPath(cfg, cfg.Model), in.tokens); errors.Is(err, errInvalidToken) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 	}
@@ -259,35 +489,50 @@ func (s *API) EmbedHandler(c *gin.Context) {
 		return
 	}
 
-	prompts := ""
-	for k, i := range input {
-		if k > 0 {
-			prompts += config2.Conf.EmbdSeparator
+	if req.Dimensions < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("dimensions must be positive, got %d", req.Dimensions)})
+		return
+	}
+	if req.Dimensions > 0 {
+		n, err := embeddingLength(s.modelPath(cfg, cfg.Model))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Dimensions > n {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("dimensions must be at most %d, got %d", n, req.Dimensions)})
+			return
 		}
-		prompts += i
 	}
 
-	ret, err := wrapper.LlamaEmbedding(s.cfg, prompts, "array")
-	if err != nil {
+	truncate := true
+	if req.Truncate != nil {
+		truncate = *req.Truncate
+	}
+	input, count, err := fitInputs(input, s.embedLimit(cfg), truncate)
+	switch {
+	case errors.Is(err, errInputTooLong):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": strings.TrimSpace(err.Error())})
 		return
 	}
-	var embeddings [][]float32
-	err = json.Unmarshal([]byte(ret), &embeddings)
+
+	embeddings, err := s.embed(input)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": strings.TrimSpace(err.Error())})
+		c.AbortWithStatusJSON(embedStatus(err), gin.H{"error": strings.TrimSpace(err.Error())})
 		return
 	}
-	if len(embeddings) != len(input) {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%d != %d", len(embeddings), len(input))})
-		return
+	for i, e := range embeddings {
+		embeddings[i] = truncateDimensions(e, req.Dimensions)
 	}
 	resp := api.EmbedResponse{
 		Model:           req.Model,
 		Embeddings:      embeddings,
 		TotalDuration:   time.Since(checkpointStart),
 		LoadDuration:    checkpointLoaded.Sub(checkpointStart),
-		PromptEvalCount: len(input),
+		PromptEvalCount: count,
 	}
 	c.JSON(http.StatusOK, resp)
 }
@@ -308,19 +553,17 @@ func (s *API) EmbeddingsHandler(c *gin.Context) {
 		return
 	}
 
-	ret, err := wrapper.LlamaEmbedding(s.cfg, req.Prompt, "array")
+	embeddings, err := s.embed([]embedInput{{text: req.Prompt}})
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": strings.TrimSpace(err.Error())})
+		c.AbortWithStatusJSON(embedStatus(err), gin.H{"error": strings.TrimSpace(err.Error())})
 		return
 	}
-	var embeddings [][]float64
-	err = json.Unmarshal([]byte(ret), &embeddings)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": strings.TrimSpace(err.Error())})
-		return
+	embedding := make([]float64, len(embeddings[0]))
+	for i, v := range embeddings[0] {
+		embedding[i] = float64(v)
 	}
 	resp := api.EmbeddingResponse{
-		Embedding: embeddings[0],
+		Embedding: embedding,
 	}
 	c.JSON(http.StatusOK, resp)
 }
@@ -328,7 +571,7 @@ func (s *API) EmbeddingsHandler(c *gin.Context) {
 func (s *API) ListHandler(c *gin.Context) {
 	models := []api.ListModelResponse{}
 
-	infos := s.cfg.GetModelFileInfos()
+	infos := s.cfg.Load().GetModelFileInfos()
 
 	for _, info := range infos {
 
@@ -343,6 +586,26 @@ func (s *API) ListHandler(c *gin.Context) {
 		})
 	}
 
+	named, err := s.models.List()
+	if err != nil {
+		log.Error(err.Error())
+	}
+	for _, m := range named {
+		models = append(models, api.ListModelResponse{
+			Model:      m.Name.String(),
+			Name:       m.Name.String(),
+			Size:       m.Size,
+			Digest:     strings.TrimPrefix(m.Digest, "sha256:"),
+			ModifiedAt: m.ModifiedAt,
+			Details: api.ModelDetails{
+				ParentModel: m.From,
+				Format:      config.EXT[1:],
+			},
+		})
+	}
+
+	models = append(models, s.listAliases()...)
+
 	slices.SortStableFunc(models, func(i, j api.ListModelResponse) int {
 		// most recently modified first
 		return cmp.Compare(j.ModifiedAt.Unix(), i.ModifiedAt.Unix())
@@ -351,6 +614,7 @@ func (s *API) ListHandler(c *gin.Context) {
 }
 
 func (s *API) ShowHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
 	var req api.ShowRequest
 	err := c.ShouldBindJSON(&req)
 	switch {
@@ -361,26 +625,55 @@ func (s *API) ShowHandler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	showModel := s.cfg.Model
+	showModel := cfg.Model
 	if len(req.Model) > 0 {
 		showModel = req.Model
 	}
-	infos := s.cfg.GetModelFileInfos()
 
-	capabilities := []model.Capability{}
-	capabilities = append(capabilities, model.CapabilityThinking)
+	capabilities := func(path string) []model.Capability {
+		if model.SupportsRerank(path) {
+			return []model.Capability{model.CapabilityRerank}
+		}
+		ret := []model.Capability{model.CapabilityCompletion}
+		if model.SupportsInsert(path) {
+			ret = append(ret, model.CapabilityInsert)
+		}
+		return append(ret, model.CapabilityThinking)
+	}
 
-	for _, info := range infos {
-		if info.Name() != showModel {
-			continue
+	if m, err := s.models.Get(s.models.Resolve(showModel)); err == nil {
+		resp := &api.ShowResponse{
+			License:    strings.Join(m.License, "\n"),
+			Modelfile:  "# Modelfile generated by \"llama.go show\"\n" + parser.FromModel(m).String(),
+			Parameters: formatParameters(m.Parameters),
+			Template:   m.Template,
+			System:     m.System,
+			Renderer:   m.Renderer,
+			Parser:     m.Parser,
+			Details: api.ModelDetails{
+				ParentModel: m.From,
+				Format:      config.EXT[1:],
+			},
+			Messages:     storeMessages(m.Messages),
+			ModifiedAt:   m.ModifiedAt,
+			Capabilities: capabilities(m.Path),
 		}
+		c.JSON(http.StatusOK, resp)
+		return
+	} else if !errors.Is(err, store.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	path := s.modelPath(cfg, showModel)
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
 		resp := &api.ShowResponse{
 			Modelfile: info.Name(),
 			Details: api.ModelDetails{
 				Format: config.EXT[1:],
 			},
 			ModifiedAt:   info.ModTime(),
-			Capabilities: capabilities,
+			Capabilities: capabilities(path),
 		}
 		c.JSON(http.StatusOK, resp)
 		return
@@ -390,6 +683,7 @@ func (s *API) ShowHandler(c *gin.Context) {
 
 // V1ModelsWebUIHandler lists models in the shape expected by llama.cpp tools/server/webui (data[] with path, status, in_cache).
 func (s *API) V1ModelsWebUIHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
 	type statusObj struct {
 		Value string `json:"value"`
 	}
@@ -402,13 +696,13 @@ func (s *API) V1ModelsWebUIHandler(c *gin.Context) {
 		Path    string    `json:"path"`
 		Status  statusObj `json:"status"`
 	}
-	activePath := s.cfg.ModelPath()
-	infos := s.cfg.GetModelFileInfos()
+	activePath := s.modelPath(cfg, cfg.Model)
+	infos := cfg.GetModelFileInfos()
 	entries := make([]dataEntry, 0, len(infos))
 	for _, info := range infos {
-		path := s.cfg.GetModelPath(info.Name())
+		path := s.modelPath(cfg, info.Name())
 		if path == "" {
-			path = filepath.Join(s.cfg.ModelDir, info.Name())
+			path = filepath.Join(cfg.ModelDir, info.Name())
 		}
 		st := "unloaded"
 		if activePath != "" && path == activePath {
@@ -428,6 +722,25 @@ func (s *API) V1ModelsWebUIHandler(c *gin.Context) {
 			Status:  statusObj{Value: st},
 		})
 	}
+	named, err := s.models.List()
+	if err != nil {
+		log.Error(err.Error())
+	}
+	for _, m := range named {
+		st := "unloaded"
+		if activePath != "" && m.Path == activePath {
+			st = "loaded"
+		}
+		entries = append(entries, dataEntry{
+			ID:      m.Name.String(),
+			Object:  "model",
+			Created: m.ModifiedAt.Unix(),
+			OwnedBy: "local",
+			InCache: true,
+			Path:    m.Path,
+			Status:  statusObj{Value: st},
+		})
+	}
 	slices.SortStableFunc(entries, func(i, j dataEntry) int {
 		return cmp.Compare(j.Created, i.Created)
 	})
@@ -447,15 +760,7 @@ func (s *API) ModelsUnloadStubHandler(c *gin.Context) {
 
 func (s *API) PropsHandler(c *gin.Context) {
 	status, jsonStr := wrapper.LlamaPropsHTTP()
-	if status == 0 {
-		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "llama core is not running"})
-		return
-	}
-	if jsonStr == "" {
-		c.AbortWithStatusJSON(status, gin.H{"error": "empty response from llama core"})
-		return
-	}
-	c.Data(status, "application/json; charset=utf-8", []byte(jsonStr))
+	coreResponse(c, status, jsonStr)
 }
 
 func (s *API) PropsChangeHandler(c *gin.Context) {
@@ -468,13 +773,5 @@ func (s *API) PropsChangeHandler(c *gin.Context) {
 
 func (s *API) SlotsHandler(c *gin.Context) {
 	status, jsonStr := wrapper.LlamaSlotsHTTP()
-	if status == 0 {
-		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "llama core is not running"})
-		return
-	}
-	if jsonStr == "" {
-		c.AbortWithStatusJSON(status, gin.H{"error": "empty response from llama core"})
-		return
-	}
-	c.Data(status, "application/json; charset=utf-8", []byte(jsonStr))
+	coreResponse(c, status, jsonStr)
 }
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/wrapper"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gin-gonic/gin"
+)
+
+// InfillHandler serves llama.cpp compatible /infill requests: input_prefix,
+// input_suffix and the optional input_extra and prompt are formatted by the
+// core with the fill-in-the-middle tokens of the model.
+func (s *API) InfillHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
+	bodyBytes, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := decodeBody(bodyBytes)
+	if errors.Is(err, io.EOF) || (err == nil && len(body) == 0) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, field := range []string{"input_prefix", "input_suffix"} {
+		if _, ok := body[field].(string); !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%q is required", field)})
+			return
+		}
+	}
+
+	name, _ := body["model"].(string)
+	m, err := s.resolveModel(cfg, name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !model.SupportsInsert(m.Path) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "infill is not supported by this model"})
+		return
+	}
+
+	params, err := samplingParams(body, m.Parameters, nil)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maps.Copy(body, params)
+
+	id, ch := wrapper.NewChan(c.Request.Context())
+	if id == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "task id error"})
+		return
+	}
+	go func() {
+		err := s.runnerSer.Infill(id, m.Path, body)
+		if err != nil {
+			log.Warn(err.Error())
+			return
+		}
+	}()
+
+	if stream, _ := body["stream"].(bool); stream {
+		streamHandler(c, ch)
+		return
+	}
+
+	ret, err := collectResponse(c.Request.Context(), ch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// infillCompletion converts the llama.cpp style responses of the core's infill
+// endpoint into OpenAI style text completions, as returned for generate
+// requests without a suffix.
+func infillCompletion(name string) chunkFunc {
+	id := fmt.Sprintf("cmpl-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+	return func(chunk map[string]any) {
+		if _, ok := chunk["error"]; ok {
+			return
+		}
+
+		content, _ := chunk["content"].(string)
+		var finishReason any
+		if stop, _ := chunk["stop"].(bool); stop {
+			finishReason = "stop"
+			if chunk["stop_type"] == "limit" {
+				finishReason = "length"
+			}
+		}
+
+		completion := map[string]any{
+			"id":      id,
+			"object":  "text_completion",
+			"created": created,
+			"model":   name,
+			"choices": []any{map[string]any{
+				"index":         0,
+				"text":          content,
+				"logprobs":      nil,
+				"finish_reason": finishReason,
+			}},
+		}
+		if finishReason != nil {
+			predicted, _ := chunk["tokens_predicted"].(float64)
+			evaluated, _ := chunk["tokens_evaluated"].(float64)
+			completion["usage"] = map[string]any{
+				"prompt_tokens":     int(evaluated),
+				"completion_tokens": int(predicted),
+				"total_tokens":      int(evaluated + predicted),
+			}
+			if timings, ok := chunk["timings"]; ok {
+				completion["timings"] = timings
+			}
+		}
+
+		clear(chunk)
+		maps.Copy(chunk, completion)
+	}
+}
@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/internal/testutil"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+	"github.com/gin-gonic/gin"
+)
+
+// testAPI returns an API serving a GGUF model with the metadata kv, without a
+// running core.
+func testAPI(t *testing.T, kv ggml.KV) *API {
+	t.Helper()
+
+	p := testutil.WriteModel(t, kv)
+	dir := filepath.Dir(p)
+	var cfg atomic.Pointer[config.Config]
+	cfg.Store(&config.Config{ModelDir: dir, Model: p})
+	return &API{cfg: &cfg, models: cfg.Load().Models()}
+}
+
+// serve sends body to the handler and returns the response.
+func serve(t *testing.T, handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	handler(c)
+	return w
+}
+
+func TestInfillHandlerErrors(t *testing.T) {
+	fim := ggml.KV{
+		"tokenizer.ggml.fim_pre_token_id": uint32(1),
+		"tokenizer.ggml.fim_suf_token_id": uint32(2),
+		"tokenizer.ggml.fim_mid_token_id": uint32(3),
+	}
+	cases := []struct {
+		desc string
+		kv   ggml.KV
+		body string
+		want string
+	}{
+		{"missing body", fim, "", "missing request body"},
+		{"invalid body", fim, "{", "unexpected EOF"},
+		{"missing prefix", fim, `{"input_suffix":"}"}`, `"input_prefix" is required`},
+		{"missing suffix", fim, `{"input_prefix":"func f() {"}`, `"input_suffix" is required`},
+		{"no fim tokens", ggml.KV{}, `{"input_prefix":"func f() {","input_suffix":"}"}`, "infill is not supported by this model"},
+		{"invalid options", fim, `{"input_prefix":"func f() {","input_suffix":"}","temperature":-1}`, "temperature"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			w := serve(t, testAPI(t, c.kv).InfillHandler, c.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(resp.Error, c.want) {
+				t.Errorf("got error %q, want %q", resp.Error, c.want)
+			}
+		})
+	}
+}
+
+func TestInfillCompletion(t *testing.T) {
+	fn := infillCompletion("coder")
+
+	chunk := map[string]any{"content": "return 1", "stop": false}
+	fn(chunk)
+	choice := chunk["choices"].([]any)[0].(map[string]any)
+	if chunk["object"] != "text_completion" || chunk["model"] != "coder" || choice["text"] != "return 1" || choice["finish_reason"] != nil {
+		t.Errorf("unexpected chunk %v", chunk)
+	}
+	if _, ok := chunk["usage"]; ok {
+		t.Errorf("unexpected usage before the end %v", chunk["usage"])
+	}
+
+	chunk = map[string]any{"content": "", "stop": true, "stop_type": "limit", "tokens_predicted": float64(16), "tokens_evaluated": float64(8)}
+	fn(chunk)
+	choice = chunk["choices"].([]any)[0].(map[string]any)
+	if choice["finish_reason"] != "length" {
+		t.Errorf("got finish reason %v, want length", choice["finish_reason"])
+	}
+	usage := chunk["usage"].(map[string]any)
+	if usage["prompt_tokens"] != 8 || usage["completion_tokens"] != 16 || usage["total_tokens"] != 24 {
+		t.Errorf("unexpected usage %v", usage)
+	}
+
+	chunk = map[string]any{"error": map[string]any{"message": "failed"}}
+	fn(chunk)
+	if _, ok := chunk["choices"]; ok {
+		t.Errorf("errors should be left untouched, got %v", chunk)
+	}
+}
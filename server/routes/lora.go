@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/model/store"
+	"github.com/Qitmeer/llama.go/wrapper"
+	"github.com/gin-gonic/gin"
+)
+
+// loraAdapter is a LoRA adapter loaded by the core, as listed by
+// /lora-adapters.
+type loraAdapter struct {
+	ID    int     `json:"id"`
+	Path  string  `json:"path"`
+	Scale float32 `json:"scale"`
+}
+
+// LoraAdaptersHandler lists the LoRA adapters loaded with --lora along with
+// the scales they apply with by default.
+func (s *API) LoraAdaptersHandler(c *gin.Context) {
+	status, jsonStr := wrapper.LlamaLoraAdaptersHTTP()
+	coreResponse(c, status, jsonStr)
+}
+
+// LoraAdaptersChangeHandler changes the default scales of the LoRA adapters.
+// Adapters left out of the request are disabled.
+func (s *API) LoraAdaptersChangeHandler(c *gin.Context) {
+	var req []api.Adapter
+	err := c.ShouldBindJSON(&req)
+	switch {
+	case errors.Is(err, io.EOF):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loaded, err := loraAdapters()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	lora, err := selectAdapters(loaded, nil, req)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bts, err := json.Marshal(lora)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, jsonStr := wrapper.LlamaSetLoraAdaptersHTTP(string(bts))
+	coreResponse(c, status, jsonStr)
+}
+
+// loraAdapters returns the LoRA adapters loaded by the core.
+func loraAdapters() ([]loraAdapter, error) {
+	status, jsonStr := wrapper.LlamaLoraAdaptersHTTP()
+	if wrapper.NotRunning(status, jsonStr) {
+		return nil, errors.New("llama core is not running")
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("llama core: %s", jsonStr)
+	}
+	var ret []loraAdapter
+	if err := json.Unmarshal([]byte(jsonStr), &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// requestLora returns the lora param of the core for a request with the model
+// m, which applies the adapters of the Modelfile of m and those the request
+// selects. It returns nil when neither has adapters, keeping the default
+// scales.
+func requestLora(m *store.Model, adapters []api.Adapter) ([]map[string]any, error) {
+	if len(m.Adapters) == 0 && len(adapters) == 0 {
+		return nil, nil
+	}
+	loaded, err := loraAdapters()
+	if err != nil {
+		return nil, err
+	}
+	return selectAdapters(loaded, m.Adapters, adapters)
+}
+
+// selectAdapters maps the adapters of a Modelfile, which apply with a scale
+// of 1, and the adapters of a request to the IDs of the loaded adapters.
+func selectAdapters(loaded []loraAdapter, paths []string, adapters []api.Adapter) ([]map[string]any, error) {
+	scales := make(map[int]float32)
+	for _, path := range paths {
+		i := slices.IndexFunc(loaded, func(a loraAdapter) bool { return sameFile(a.Path, path) })
+		if i < 0 {
+			return nil, fmt.Errorf("adapter %s is not loaded, start the server with --lora %s", path, path)
+		}
+		scales[loaded[i].ID] = 1
+	}
+	for _, adapter := range adapters {
+		i := -1
+		switch {
+		case adapter.ID != nil:
+			i = slices.IndexFunc(loaded, func(a loraAdapter) bool { return a.ID == *adapter.ID })
+		case adapter.Path != "":
+			i = slices.IndexFunc(loaded, func(a loraAdapter) bool {
+				return a.Path == adapter.Path || filepath.Base(a.Path) == adapter.Path || sameFile(a.Path, adapter.Path)
+			})
+		default:
+			return nil, errors.New("adapter requires an id or a path")
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("adapter %s is not loaded", adapterName(adapter))
+		}
+		scale := float32(1)
+		if adapter.Scale != nil {
+			scale = *adapter.Scale
+		}
+		scales[loaded[i].ID] = scale
+	}
+
+	ret := make([]map[string]any, 0, len(scales))
+	for _, a := range loaded {
+		if scale, ok := scales[a.ID]; ok {
+			ret = append(ret, map[string]any{"id": a.ID, "scale": scale})
+		}
+	}
+	return ret, nil
+}
+
+func adapterName(a api.Adapter) string {
+	if a.ID != nil {
+		return strconv.Itoa(*a.ID)
+	}
+	return a.Path
+}
+
+// sameFile reports whether a and b are the same file, e.g. an adapter and
+// the blob a Modelfile imported it as.
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
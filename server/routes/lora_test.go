@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSelectAdapters(t *testing.T) {
+	dir := t.TempDir()
+	style := filepath.Join(dir, "style.gguf")
+	code := filepath.Join(dir, "code.gguf")
+	for _, p := range []string{style, code} {
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the blob a Modelfile imported the style adapter as
+	blob := filepath.Join(dir, "sha256-style")
+	if err := os.Link(style, blob); err != nil {
+		t.Fatal(err)
+	}
+	loaded := []loraAdapter{{ID: 0, Path: style, Scale: 1}, {ID: 1, Path: code, Scale: 0.5}}
+
+	id := func(i int) *int { return &i }
+	scale := func(f float32) *float32 { return &f }
+
+	cases := []struct {
+		desc     string
+		paths    []string
+		adapters []api.Adapter
+		want     []map[string]any
+		err      bool
+	}{
+		{
+			desc: "none",
+			want: []map[string]any{},
+		},
+		{
+			desc:  "modelfile adapter",
+			paths: []string{blob},
+			want:  []map[string]any{{"id": 0, "scale": float32(1)}},
+		},
+		{
+			desc:     "by id",
+			adapters: []api.Adapter{{ID: id(1), Scale: scale(0.25)}},
+			want:     []map[string]any{{"id": 1, "scale": float32(0.25)}},
+		},
+		{
+			desc:     "by file name",
+			adapters: []api.Adapter{{Path: "code.gguf"}},
+			want:     []map[string]any{{"id": 1, "scale": float32(1)}},
+		},
+		{
+			desc:     "request overrides the modelfile",
+			paths:    []string{style},
+			adapters: []api.Adapter{{Path: style, Scale: scale(0)}, {ID: id(1)}},
+			want:     []map[string]any{{"id": 0, "scale": float32(0)}, {"id": 1, "scale": float32(1)}},
+		},
+		{
+			desc:  "modelfile adapter not loaded",
+			paths: []string{filepath.Join(dir, "other.gguf")},
+			err:   true,
+		},
+		{
+			desc:     "unknown id",
+			adapters: []api.Adapter{{ID: id(2)}},
+			err:      true,
+		},
+		{
+			desc:     "unknown path",
+			adapters: []api.Adapter{{Path: "other.gguf"}},
+			err:      true,
+		},
+		{
+			desc:     "neither id nor path",
+			adapters: []api.Adapter{{Scale: scale(1)}},
+			err:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := selectAdapters(loaded, c.paths, c.adapters)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoraAdaptersHandlerNotRunning(t *testing.T) {
+	s := testAPI(t, ggml.KV{})
+	for _, handler := range []gin.HandlerFunc{s.LoraAdaptersHandler, s.LoraAdaptersChangeHandler} {
+		w := serve(t, handler, `[{"id":0,"scale":0.5}]`)
+		if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "llama core is not running") {
+			t.Errorf("got %d %s, want the core not running", w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// draftStats totals the tokens drafted during speculative decoding and those
+// the model accepted, across all requests.
+type draftStats struct {
+	drafted  atomic.Int64
+	accepted atomic.Int64
+}
+
+// Chunk reports the draft tokens found in the timings of a response as its
+// draft_count and draft_accepted_count metrics, and adds them to the totals.
+func (d *draftStats) Chunk(chunk map[string]any) {
+	timings, ok := chunk["timings"].(map[string]any)
+	if !ok {
+		return
+	}
+	drafted := timingCount(timings["draft_n"])
+	if drafted <= 0 {
+		return
+	}
+	accepted := timingCount(timings["draft_n_accepted"])
+	chunk["draft_count"] = drafted
+	chunk["draft_accepted_count"] = accepted
+	d.drafted.Add(drafted)
+	d.accepted.Add(accepted)
+}
+
+func timingCount(v any) int64 {
+	switch v := v.(type) {
+	case float64:
+		return int64(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	}
+	return 0
+}
+
+// MetricsHandler serves the server metrics in the Prometheus text format.
+func (s *API) MetricsHandler(c *gin.Context) {
+	drafted, accepted := s.draft.drafted.Load(), s.draft.accepted.Load()
+	rate := 0.0
+	if drafted > 0 {
+		rate = float64(accepted) / float64(drafted)
+	}
+
+	var sb strings.Builder
+	metric := func(name, typ, help string, value any) {
+		fmt.Fprintf(&sb, "# HELP llamago_%s %s\n# TYPE llamago_%s %s\nllamago_%s %v\n", name, help, name, typ, name, value)
+	}
+	metric("draft_tokens_total", "counter", "Tokens drafted during speculative decoding.", drafted)
+	metric("draft_tokens_accepted_total", "counter", "Draft tokens accepted by the model.", accepted)
+	metric("draft_acceptance_rate", "gauge", "Ratio of accepted to drafted tokens.", rate)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(sb.String()))
+}
@@ -2,8 +2,12 @@ package routes
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 
 	"github.com/Qitmeer/llama.go/model"
@@ -26,9 +30,11 @@ type ErrorResponse struct {
 }
 
 type EmbedRequest struct {
-	Input      any    `json:"input"`
-	Model      string `json:"model"`
-	Dimensions int    `json:"dimensions,omitempty"`
+	Input          any    `json:"input"`
+	Model          string `json:"model"`
+	Dimensions     int    `json:"dimensions,omitempty"`
+	EncodingFormat string `json:"encoding_format,omitempty"`
+	Truncate       *bool  `json:"truncate,omitempty"`
 }
 
 type Model struct {
@@ -39,9 +45,11 @@ type Model struct {
 }
 
 type Embedding struct {
-	Object    string    `json:"object"`
-	Embedding []float32 `json:"embedding"`
-	Index     int       `json:"index"`
+	Object string `json:"object"`
+	// Embedding is either a list of floats or, for the base64 encoding
+	// format, a string of little-endian float32 values.
+	Embedding any `json:"embedding"`
+	Index     int `json:"index"`
 }
 
 type ListCompletion struct {
@@ -97,13 +105,17 @@ func toListCompletion(r api.ListResponse) ListCompletion {
 	}
 }
 
-func toEmbeddingList(model string, r api.EmbedResponse) EmbeddingList {
+func toEmbeddingList(model string, r api.EmbedResponse, encodingFormat string) EmbeddingList {
 	if r.Embeddings != nil {
 		var data []Embedding
 		for i, e := range r.Embeddings {
+			var embedding any = e
+			if encodingFormat == "base64" {
+				embedding = encodeEmbedding(e)
+			}
 			data = append(data, Embedding{
 				Object:    "embedding",
-				Embedding: e,
+				Embedding: embedding,
 				Index:     i,
 			})
 		}
@@ -122,6 +134,15 @@ func toEmbeddingList(model string, r api.EmbedResponse) EmbeddingList {
 	return EmbeddingList{}
 }
 
+// encodeEmbedding encodes e as base64 little-endian float32 values.
+func encodeEmbedding(e []float32) string {
+	bts := make([]byte, 4*len(e))
+	for i, v := range e {
+		binary.LittleEndian.PutUint32(bts[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(bts)
+}
+
 func toModel(r api.ShowResponse, m string) Model {
 	ownedby := m
 	hf, err := model.ParseHuggingFaceModel(m)
@@ -153,7 +174,8 @@ type RetrieveWriter struct {
 
 type EmbedWriter struct {
 	BaseWriter
-	model string
+	model          string
+	encodingFormat string
 }
 
 func (w *BaseWriter) writeError(data []byte) (int, error) {
@@ -231,7 +253,7 @@ func (w *EmbedWriter) writeResponse(data []byte) (int, error) {
 	}
 
 	w.ResponseWriter.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w.ResponseWriter).Encode(toEmbeddingList(w.model, embedResponse))
+	err = json.NewEncoder(w.ResponseWriter).Encode(toEmbeddingList(w.model, embedResponse, w.encodingFormat))
 	if err != nil {
 		return 0, err
 	}
@@ -305,8 +327,17 @@ func EmbeddingsMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		switch req.EncodingFormat {
+		case "":
+			req.EncodingFormat = "float"
+		case "float", "base64":
+		default:
+			c.AbortWithStatusJSON(http.StatusBadRequest, NewError(http.StatusBadRequest, fmt.Sprintf("invalid encoding_format %q, expected float or base64", req.EncodingFormat)))
+			return
+		}
+
 		var b bytes.Buffer
-		if err := json.NewEncoder(&b).Encode(api.EmbedRequest{Model: req.Model, Input: req.Input, Dimensions: req.Dimensions}); err != nil {
+		if err := json.NewEncoder(&b).Encode(api.EmbedRequest{Model: req.Model, Input: req.Input, Dimensions: req.Dimensions, Truncate: req.Truncate}); err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, NewError(http.StatusInternalServerError, err.Error()))
 			return
 		}
@@ -314,8 +345,9 @@ func EmbeddingsMiddleware() gin.HandlerFunc {
 		c.Request.Body = io.NopCloser(&b)
 
 		w := &EmbedWriter{
-			BaseWriter: BaseWriter{ResponseWriter: c.Writer},
-			model:      req.Model,
+			BaseWriter:     BaseWriter{ResponseWriter: c.Writer},
+			model:          req.Model,
+			encodingFormat: req.EncodingFormat,
 		}
 
 		c.Writer = w
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"maps"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// topLevelOptions maps the sampling fields that OpenAI and llama.cpp clients
+// send at the top level of a request to their api.Options. Earlier fields take
+// precedence when several map to the same option.
+var topLevelOptions = []struct {
+	field  string
+	option string
+}{
+	{"temperature", "temperature"},
+	{"top_k", "top_k"},
+	{"top_p", "top_p"},
+	{"min_p", "min_p"},
+	{"typical_p", "typical_p"},
+	{"repeat_penalty", "repeat_penalty"},
+	{"repeat_last_n", "repeat_last_n"},
+	{"presence_penalty", "presence_penalty"},
+	{"frequency_penalty", "frequency_penalty"},
+	{"seed", "seed"},
+	{"stop", "stop"},
+	{"max_completion_tokens", "num_predict"},
+	{"max_tokens", "num_predict"},
+	{"n_predict", "num_predict"},
+	{"n_keep", "num_keep"},
+}
+
+// coreParams returns the name and value of the core sampling param for each
+// api.Options applied per request.
+var coreParams = map[string]func(o *api.Options) (string, any){
+	"num_keep":          func(o *api.Options) (string, any) { return "n_keep", o.NumKeep },
+	"seed":              func(o *api.Options) (string, any) { return "seed", o.Seed },
+	"num_predict":       func(o *api.Options) (string, any) { return "n_predict", o.NumPredict },
+	"top_k":             func(o *api.Options) (string, any) { return "top_k", o.TopK },
+	"top_p":             func(o *api.Options) (string, any) { return "top_p", o.TopP },
+	"min_p":             func(o *api.Options) (string, any) { return "min_p", o.MinP },
+	"typical_p":         func(o *api.Options) (string, any) { return "typical_p", o.TypicalP },
+	"repeat_last_n":     func(o *api.Options) (string, any) { return "repeat_last_n", o.RepeatLastN },
+	"temperature":       func(o *api.Options) (string, any) { return "temperature", o.Temperature },
+	"repeat_penalty":    func(o *api.Options) (string, any) { return "repeat_penalty", o.RepeatPenalty },
+	"presence_penalty":  func(o *api.Options) (string, any) { return "presence_penalty", o.PresencePenalty },
+	"frequency_penalty": func(o *api.Options) (string, any) { return "frequency_penalty", o.FrequencyPenalty },
+	"stop":              func(o *api.Options) (string, any) { return "stop", o.Stop },
+}
+
+// samplingParams validates the options of a request, given in its options
+// field or as top level sampling fields of body, and returns them as the
+// sampling params of the core. Top level fields are removed from body and
+// the options field wins over them. defaults, the parameters of a named model,
+// apply to the options the request leaves unset.
+func samplingParams(body map[string]any, defaults, options map[string]any) (map[string]any, error) {
+	top := make(map[string]any)
+	for _, o := range topLevelOptions {
+		v, ok := body[o.field]
+		if !ok {
+			continue
+		}
+		delete(body, o.field)
+		if _, ok := top[o.option]; ok || v == nil {
+			continue
+		}
+		if s, ok := v.(string); ok && o.field == "stop" {
+			v = []any{s}
+		}
+		top[o.option] = v
+	}
+	delete(body, "options")
+
+	m := make(map[string]any, len(defaults)+len(top)+len(options))
+	maps.Copy(m, defaults)
+	maps.Copy(m, top)
+	maps.Copy(m, options)
+
+	opts := api.DefaultOptions()
+	if err := opts.FromMap(m); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	params := make(map[string]any, len(m))
+	for key := range m {
+		param, ok := coreParams[key]
+		if !ok {
+			if _, ok := options[key]; ok {
+				log.Warn("Option is only applied when the model is loaded", "option", key)
+			}
+			continue
+		}
+		name, value := param(&opts)
+		params[name] = value
+	}
+	return params, nil
+}
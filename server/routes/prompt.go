@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model/store"
+	"github.com/Qitmeer/llama.go/model/template"
+)
+
+// seedMessages prepends the seed messages and the system prompt of a named
+// model to a chat, both to the decoded request and to the body forwarded to
+// the core. The system prompt is left out when the chat brings its own.
+func seedMessages(body map[string]any, req *api.ChatRequest, m *store.Model) {
+	seed := storeMessages(m.Messages)
+	hasSystem := slices.ContainsFunc(req.Messages, func(msg api.Message) bool {
+		return msg.Role == "system"
+	})
+	if m.System != "" && !hasSystem {
+		seed = append([]api.Message{{Role: "system", Content: m.System}}, seed...)
+	}
+	if len(seed) == 0 {
+		return
+	}
+
+	req.Messages = append(seed, req.Messages...)
+	raw, _ := body["messages"].([]any)
+	msgs := make([]any, 0, len(seed)+len(raw))
+	for _, msg := range seed {
+		msgs = append(msgs, map[string]any{"role": msg.Role, "content": msg.Content})
+	}
+	body["messages"] = append(msgs, raw...)
+}
+
+// generateTemplate returns the template formatting the prompt of a generate
+// request: the template of the request or of the model, else the chat
+// template of the GGUF when a system prompt or seed messages must be applied.
+// An empty template leaves the prompt as is.
+func (s *API) generateTemplate(cfg *config.Config, req *api.GenerateRequest, m *store.Model) string {
+	if req.Raw {
+		return ""
+	}
+	if req.Template != "" {
+		return req.Template
+	}
+	if m.Template != "" {
+		return m.Template
+	}
+	if req.System == "" && m.System == "" && len(m.Messages) == 0 {
+		return ""
+	}
+	named, err := template.Named(s.chatTemplate(cfg, m.Path))
+	if err != nil {
+		return ""
+	}
+	return string(named.Bytes)
+}
+
+// renderPrompt formats msgs with a Go template such as the TEMPLATE of a
+// Modelfile.
+func renderPrompt(tmpl string, msgs []api.Message, think *api.ThinkValue) (string, error) {
+	t, err := template.Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	v := template.Values{Messages: msgs}
+	if think != nil && think.Value != nil {
+		v.Think = think.Bool()
+		v.IsThinkSet = true
+		if think.IsString() {
+			v.ThinkLevel = think.String()
+		}
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, v); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// completionAsChat rewrites the text completion of a prompt rendered from a
+// chat as a chat completion.
+func completionAsChat(stream bool) chunkFunc {
+	key, object := "message", "chat.completion"
+	if stream {
+		key, object = "delta", "chat.completion.chunk"
+	}
+	return func(chunk map[string]any) {
+		if chunk["object"] != "text_completion" {
+			return
+		}
+		chunk["object"] = object
+		for _, choice := range choices(chunk) {
+			text, _ := choice["text"].(string)
+			delete(choice, "text")
+			choice[key] = map[string]any{"role": "assistant", "content": text}
+		}
+	}
+}
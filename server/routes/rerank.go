@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/wrapper"
+	"github.com/gin-gonic/gin"
+)
+
+// RerankHandler scores the documents of a Jina or Cohere style request by
+// their relevance to the query, with a model pooling into a rank.
+func (s *API) RerankHandler(c *gin.Context) {
+	cfg := s.cfg.Load()
+	var req api.RerankRequest
+	if err := c.ShouldBindJSON(&req); errors.Is(err, io.EOF) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case len(req.Query) <= 0:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	case len(req.Documents) <= 0:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "documents are required"})
+		return
+	case req.TopN < 0:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "top_n must not be negative"})
+		return
+	}
+	// the core is started with --reranking for such a model
+	if !model.SupportsRerank(s.modelPath(cfg, cfg.Model)) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "the model does not support reranking"})
+		return
+	}
+
+	topN := req.TopN
+	if topN == 0 {
+		topN = len(req.Documents)
+	}
+	bts, err := json.Marshal(map[string]any{"query": req.Query, "documents": req.Documents, "top_n": topN})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status, jsonStr := wrapper.LlamaRerankHTTP(string(bts))
+	if wrapper.NotRunning(status, jsonStr) {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "llama core is not running"})
+		return
+	}
+	if status != http.StatusOK {
+		c.AbortWithStatusJSON(status, gin.H{"error": coreError(jsonStr)})
+		return
+	}
+
+	// the core sorts the results by relevance and keeps the top n
+	var resp api.RerankResponse
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp.Model = req.Model
+	if resp.Results == nil {
+		resp.Results = []api.RerankResult{}
+	}
+	for i, r := range resp.Results {
+		if r.Index < 0 || r.Index >= len(req.Documents) {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "llama core: unexpected document index"})
+			return
+		}
+		if req.ReturnDocuments {
+			resp.Results[i].Document = &api.RerankDocument{Text: req.Documents[r.Index]}
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// coreError returns the message of an error response of the core.
+func coreError(jsonStr string) string {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil || len(resp.Error.Message) <= 0 {
+		return "llama core: " + jsonStr
+	}
+	return resp.Error.Message
+}
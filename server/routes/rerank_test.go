@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/model/fs/ggml"
+)
+
+func TestRerankHandler(t *testing.T) {
+	rank := ggml.KV{"test.pooling_type": uint32(4)}
+	documents := `"documents":["Rayleigh scattering","the sea is blue"]`
+	cases := []struct {
+		desc   string
+		kv     ggml.KV
+		body   string
+		status int
+		want   string
+	}{
+		{"missing body", rank, "", http.StatusBadRequest, "missing request body"},
+		{"missing query", rank, `{` + documents + `}`, http.StatusBadRequest, "query is required"},
+		{"missing documents", rank, `{"query":"why is the sky blue"}`, http.StatusBadRequest, "documents are required"},
+		{"negative top_n", rank, `{"query":"why is the sky blue",` + documents + `,"top_n":-1}`, http.StatusBadRequest, "top_n must not be negative"},
+		{"mean pooling", ggml.KV{"test.pooling_type": uint32(1)}, `{"query":"why is the sky blue",` + documents + `}`, http.StatusBadRequest, "does not support reranking"},
+		{"no pooling", ggml.KV{}, `{"query":"why is the sky blue",` + documents + `}`, http.StatusBadRequest, "does not support reranking"},
+		// the model pooling into a rank reaches the core whatever --pooling
+		{"rank pooling", rank, `{"query":"why is the sky blue",` + documents + `}`, http.StatusServiceUnavailable, "llama core is not running"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			w := serve(t, testAPI(t, c.kv).RerankHandler, c.body)
+			if w.Code != c.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, c.status, w.Body)
+			}
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(resp.Error, c.want) {
+				t.Errorf("got error %q, want %q", resp.Error, c.want)
+			}
+		})
+	}
+}
+
+func TestCoreError(t *testing.T) {
+	if got := coreError(`{"error":{"code":501,"message":"not supported"}}`); got != "not supported" {
+		t.Errorf("got %q", got)
+	}
+	if got := coreError("oops"); got != "llama core: oops" {
+		t.Errorf("got %q", got)
+	}
+}
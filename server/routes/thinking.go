@@ -0,0 +1,182 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/config"
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/model/template"
+	"github.com/Qitmeer/llama.go/model/thinking"
+	"github.com/Qitmeer/llama.go/wrapper"
+)
+
+const (
+	defaultThinkingOpeningTag = "<think>"
+	defaultThinkingClosingTag = "</think>"
+)
+
+// chatTemplate returns the chat template applied by the core for the model at
+// path, preferring the --chat-template override over the GGUF metadata.
+func (s *API) chatTemplate(cfg *config.Config, modelPath string) string {
+	if len(cfg.ChatTemplate) > 0 {
+		return cfg.ChatTemplate
+	}
+	g, err := model.LoadGGML(modelPath)
+	if err != nil {
+		return ""
+	}
+	return g.KV().ChatTemplate()
+}
+
+// thinkingTags infers the tags surrounding thinking traces for a chat
+// template, falling back to <think></think> when none can be inferred.
+func thinkingTags(chatTemplate string) (string, string) {
+	if len(chatTemplate) > 0 {
+		if named, err := template.Named(chatTemplate); err == nil {
+			if t, err := template.Parse(string(named.Bytes)); err == nil {
+				if opening, closing := thinking.InferTags(t.Template); opening != "" && closing != "" {
+					return opening, closing
+				}
+			}
+		}
+	}
+	return defaultThinkingOpeningTag, defaultThinkingClosingTag
+}
+
+// renderedPrompt returns the prompt the core renders for a chat completion
+// request, or "" when it cannot be rendered.
+func renderedPrompt(body []byte) string {
+	status, jsonStr := wrapper.LlamaApplyTemplateHTTP(string(body))
+	if status != http.StatusOK {
+		return ""
+	}
+	var resp struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		return ""
+	}
+	return resp.Prompt
+}
+
+// applyThink forwards ChatRequest.Think to the chat template. Reasoning is
+// always returned untouched by the core so that it can be separated in Go.
+func applyThink(body map[string]any, think *api.ThinkValue, chatTemplate string) {
+	body["reasoning_format"] = "none"
+	delete(body, "think")
+	if think == nil || think.Value == nil {
+		return
+	}
+
+	kwargs, ok := body["chat_template_kwargs"].(map[string]any)
+	if !ok {
+		kwargs = make(map[string]any)
+	}
+	kwargs["enable_thinking"] = think.Bool()
+	if think.IsString() && strings.Contains(chatTemplate, "reasoning_effort") {
+		kwargs["reasoning_effort"] = think.String()
+	}
+	body["chat_template_kwargs"] = kwargs
+}
+
+// reasoningFilter splits the content produced by the core into thinking and
+// regular content for each choice of a chat completion.
+type reasoningFilter struct {
+	openingTag string
+	closingTag string
+
+	// started is set when the prompt ends with the opening tag, as rendered by
+	// templates such as Qwen3 or DeepSeek-R1, so the output begins in thinking
+	started bool
+	// strip drops thinking instead of returning it, used when think is false
+	strip bool
+	// native mirrors each choice into a llama.go style "message" field
+	native bool
+
+	mu      sync.Mutex
+	parsers map[int]*thinking.Parser
+}
+
+// newReasoningFilter returns the filter of a chat whose rendered prompt is
+// prompt, which may be "" when it is unknown.
+func newReasoningFilter(chatTemplate, prompt string, think *api.ThinkValue, native bool) *reasoningFilter {
+	opening, closing := thinkingTags(chatTemplate)
+	return &reasoningFilter{
+		openingTag: opening,
+		closingTag: closing,
+		started:    strings.HasSuffix(strings.TrimRightFunc(prompt, unicode.IsSpace), opening),
+		strip:      think != nil && think.IsBool() && !think.Bool(),
+		native:     native,
+		parsers:    make(map[int]*thinking.Parser),
+	}
+}
+
+// split parses the next content of a choice, flushing its parser once done.
+func (f *reasoningFilter) split(index int, content string, done bool) (string, string) {
+	p, ok := f.parsers[index]
+	if !ok {
+		p = &thinking.Parser{OpeningTag: f.openingTag, ClosingTag: f.closingTag}
+		if f.started {
+			p.StartThinking()
+		}
+		f.parsers[index] = p
+	}
+	thinking, content := p.AddContent(content)
+	if done {
+		rest, restContent := p.Flush()
+		thinking += rest
+		content += restContent
+	}
+	if f.strip {
+		thinking = ""
+	}
+	return thinking, content
+}
+
+// Chunk rewrites the content of a streamed delta or a final message. The
+// content still buffered is returned with the finish reason, which the core
+// sends in the last chunk before [DONE].
+func (f *reasoningFilter) Chunk(chunk map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, choice := range choices(chunk) {
+		key := "delta"
+		msg, ok := choice[key].(map[string]any)
+		if !ok {
+			key = "message"
+			if msg, ok = choice[key].(map[string]any); !ok {
+				continue
+			}
+		}
+
+		content, _ := msg["content"].(string)
+		thinking, content := f.split(choiceIndex(choice), content, choice["finish_reason"] != nil)
+		if _, ok := msg["content"]; ok || content != "" {
+			msg["content"] = content
+		}
+		if thinking != "" {
+			msg["reasoning_content"] = thinking
+		} else {
+			delete(msg, "reasoning_content")
+		}
+
+		if f.native {
+			role, _ := msg["role"].(string)
+			if role == "" {
+				role = "assistant"
+			}
+			native := api.Message{Role: role, Content: content, Thinking: thinking, ToolCalls: toolCalls(msg)}
+			chunk["message"] = native
+			chunk["done"] = choice["finish_reason"] != nil
+			if reason, ok := choice["finish_reason"].(string); ok {
+				chunk["done_reason"] = reason
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Qitmeer/llama.go/api"
+)
+
+// deltaEvent returns the server-sent event of a streamed chat chunk.
+func deltaEvent(content string, finish bool) string {
+	choice := map[string]any{"index": 0, "delta": map[string]any{"content": content}}
+	if finish {
+		choice["finish_reason"] = "stop"
+	} else {
+		choice["finish_reason"] = nil
+	}
+	bts, _ := json.Marshal(map[string]any{"choices": []any{choice}})
+	return fmt.Sprintf("data: %s\n\n", bts)
+}
+
+func TestReasoningFilterStream(t *testing.T) {
+	thinkFalse := &api.ThinkValue{Value: false}
+	cases := []struct {
+		desc         string
+		prompt       string
+		think        *api.ThinkValue
+		chunks       []string
+		wantThinking string
+		wantContent  string
+	}{
+		{
+			desc:         "tags in single chunks",
+			chunks:       []string{"<think>", "hmm", "</think>", "answer"},
+			wantThinking: "hmm",
+			wantContent:  "answer",
+		},
+		{
+			desc:         "tags split across chunks",
+			chunks:       []string{"<th", "ink>\nsome ", "thoughts</", "thi", "nk>\n\nthe ", "answer"},
+			wantThinking: "some thoughts",
+			wantContent:  "the answer",
+		},
+		{
+			desc:         "opening tag rendered in the prompt",
+			prompt:       "<|im_start|>assistant\n<think>\n",
+			chunks:       []string{"some ", "thoughts</th", "ink>", "answer"},
+			wantThinking: "some thoughts",
+			wantContent:  "answer",
+		},
+		{
+			desc:         "partial closing tag at the end of the stream",
+			prompt:       "<｜Assistant｜><think>\n",
+			chunks:       []string{"thoughts", " </thi"},
+			wantThinking: "thoughts </thi",
+		},
+		{
+			desc:        "partial opening tag at the end of the stream",
+			chunks:      []string{"<", "thi"},
+			wantContent: "<thi",
+		},
+		{
+			desc:        "no thinking",
+			chunks:      []string{"just ", "an answer"},
+			wantContent: "just an answer",
+		},
+		{
+			desc:        "thinking stripped",
+			think:       thinkFalse,
+			chunks:      []string{"<think>", "hmm</think>", "answer"},
+			wantContent: "answer",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			in := make(chan any, len(c.chunks)+2)
+			for _, chunk := range c.chunks {
+				in <- deltaEvent(chunk, false)
+			}
+			in <- deltaEvent("", true)
+			in <- "data: [DONE]\n\n"
+			close(in)
+
+			f := newReasoningFilter("", c.prompt, c.think, false)
+			var thinking, content strings.Builder
+			var done bool
+			for val := range filterStream(in, f.Chunk) {
+				str := val.(string)
+				if str == "data: [DONE]\n\n" {
+					done = true
+					continue
+				}
+				var chunk struct {
+					Choices []struct {
+						Delta struct {
+							Content          string `json:"content"`
+							ReasoningContent string `json:"reasoning_content"`
+						} `json:"delta"`
+					} `json:"choices"`
+				}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(str, "data: ")), &chunk); err != nil {
+					t.Fatalf("invalid event %q: %v", str, err)
+				}
+				thinking.WriteString(chunk.Choices[0].Delta.ReasoningContent)
+				content.WriteString(chunk.Choices[0].Delta.Content)
+			}
+			if !done {
+				t.Error("[DONE] was not forwarded")
+			}
+			if thinking.String() != c.wantThinking || content.String() != c.wantContent {
+				t.Errorf("got (%q,%q), want (%q,%q)", thinking.String(), content.String(), c.wantThinking, c.wantContent)
+			}
+		})
+	}
+}
+
+func TestReasoningFilterMessage(t *testing.T) {
+	ret := map[string]any{
+		"choices": []any{map[string]any{
+			"index":         float64(0),
+			"finish_reason": "stop",
+			"message":       map[string]any{"role": "assistant", "content": "thoughts</think>answer"},
+		}},
+	}
+	newReasoningFilter("", "<think>", nil, true).Chunk(ret)
+
+	msg := ret["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if msg["reasoning_content"] != "thoughts" || msg["content"] != "answer" {
+		t.Errorf("got (%q,%q), want (%q,%q)", msg["reasoning_content"], msg["content"], "thoughts", "answer")
+	}
+	native, ok := ret["message"].(api.Message)
+	if !ok || native.Thinking != "thoughts" || native.Content != "answer" || ret["done"] != true {
+		t.Errorf("unexpected native message %+v, done %v", ret["message"], ret["done"])
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	in := "data: {\"a\":1}\n\ndata: [DONE]\n\n"
+	got := filterEvents(in, func(chunk map[string]any) { chunk["b"] = 2 })
+	want := "data: {\"a\":1,\"b\":2}\n\ndata: [DONE]\n\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
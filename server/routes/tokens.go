@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Qitmeer/llama.go/model"
+	"github.com/Qitmeer/llama.go/wrapper"
+)
+
+// errInvalidToken is returned for a token id outside the vocabulary.
+var errInvalidToken = errors.New("invalid token")
+
+// tokenIDs returns the token ids of v when it is a pre-tokenized input, an
+// array of integers.
+func tokenIDs(v any) ([]int, bool) {
+	vs, ok := v.([]any)
+	if !ok || len(vs) == 0 {
+		return nil, false
+	}
+	ret := make([]int, 0, len(vs))
+	for _, v := range vs {
+		var id int64
+		switch n := v.(type) {
+		case float64:
+			if n != float64(int64(n)) {
+				return nil, false
+			}
+			id = int64(n)
+		case json.Number:
+			var err error
+			if id, err = n.Int64(); err != nil {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+		ret = append(ret, int(id))
+	}
+	return ret, true
+}
+
+// promptTokens returns the token ids of the prompt of a completion request
+// when it is pre-tokenized, either an array of ids or an array holding a
+// single one, and nil for a text prompt.
+func promptTokens(body map[string]any) ([]int, error) {
+	p, ok := body["prompt"].([]any)
+	if !ok {
+		return nil, nil
+	}
+	if tokens, ok := tokenIDs(p); ok {
+		return tokens, nil
+	}
+	if len(p) == 1 {
+		if tokens, ok := tokenIDs(p[0]); ok {
+			return tokens, nil
+		}
+	}
+	if len(p) > 1 {
+		return nil, errors.New("a single prompt is supported per request")
+	}
+	return nil, errors.New("prompt must be a string or an array of token ids")
+}
+
+// checkTokens returns errInvalidToken unless the tokens are in the vocabulary
+// of the model at path.
+func checkTokens(path string, tokens []int) error {
+	n, err := model.VocabSize(path)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t < 0 || t >= n {
+			return fmt.Errorf("%w %d, the vocabulary has %d tokens", errInvalidToken, t, n)
+		}
+	}
+	return nil
+}
+
+// tokenize returns the tokens of content, adding the special tokens of the
+// model such as BOS when addSpecial is set.
+func tokenize(content string, addSpecial bool) ([]int, error) {
+	bts, err := json.Marshal(map[string]any{"content": content, "add_special": addSpecial})
+	if err != nil {
+		return nil, err
+	}
+	status, jsonStr := wrapper.LlamaTokenizeHTTP(string(bts))
+	if wrapper.NotRunning(status, jsonStr) {
+		return nil, errors.New("llama core is not running")
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("llama core: %s", jsonStr)
+	}
+	var resp struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tokens, nil
+}
+
+func detokenize(tokens []int) (string, error) {
+	bts, err := json.Marshal(map[string]any{"tokens": tokens})
+	if err != nil {
+		return "", err
+	}
+	status, jsonStr := wrapper.LlamaDetokenizeHTTP(string(bts))
+	if wrapper.NotRunning(status, jsonStr) {
+		return "", errors.New("llama core is not running")
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("llama core: %s", jsonStr)
+	}
+	var resp struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
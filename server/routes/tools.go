@@ -0,0 +1,274 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/Qitmeer/llama.go/api"
+)
+
+// maxToolCallRetries bounds the non-streamed completions retried after the
+// model produced an invalid tool call.
+const maxToolCallRetries = 2
+
+// applyToolChoice adapts tool_choice for the core, which only knows the
+// "auto", "none" and "required" modes: a specific function restricts the tools
+// to that function and requires a call to it. The core then constrains the
+// generation with a grammar built from the parameters of the tools.
+func applyToolChoice(body map[string]any, req *api.ChatRequest, jinja bool) error {
+	if !req.ToolChoice.Required() {
+		return nil
+	}
+	if len(req.Tools) == 0 {
+		return errors.New("tool_choice requires tools")
+	}
+	if !jinja {
+		return errors.New("tool_choice requires the --jinja flag")
+	}
+
+	name := req.ToolChoice.Function
+	if name == "" {
+		return nil
+	}
+
+	tools, _ := body["tools"].([]any)
+	var selected []any
+	for i, tool := range req.Tools {
+		if tool.Function.Name == name && i < len(tools) {
+			selected = append(selected, tools[i])
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("tool_choice function %q is not one of the tools", name)
+	}
+	body["tools"] = selected
+	body["tool_choice"] = "required"
+	return nil
+}
+
+// retryToolCall adjusts body for another attempt at a completion whose tool
+// call was invalid. The error follows messages, the messages of the request,
+// so that the model can correct the call, and a fixed seed is moved on so that
+// the sampling differs from the previous attempt.
+func retryToolCall(body map[string]any, messages []any, err error) {
+	body["messages"] = append(slices.Clip(messages), map[string]any{
+		"role":    "user",
+		"content": fmt.Sprintf("The previous tool call was invalid: %v. Call the tool again with valid arguments.", err),
+	})
+	if seed, ok := body["seed"].(int); ok && seed >= 0 {
+		body["seed"] = seed + 1
+	}
+}
+
+type pendingToolCall struct {
+	id        string
+	typ       string
+	name      string
+	arguments string
+}
+
+// toolCallValidator checks the tool calls of a chat completion against the
+// parameters of the requested tools. Streamed tool calls are held back until
+// the choice finishes so that invalid arguments are never forwarded.
+type toolCallValidator struct {
+	params   map[string]api.ToolFunctionParameters
+	required bool
+	single   bool
+
+	mu      sync.Mutex
+	pending map[int][]*pendingToolCall
+}
+
+func newToolCallValidator(req *api.ChatRequest) *toolCallValidator {
+	v := &toolCallValidator{
+		params:   make(map[string]api.ToolFunctionParameters),
+		required: req.ToolChoice.Required(),
+		single:   req.ParallelToolCalls != nil && !*req.ParallelToolCalls,
+		pending:  make(map[int][]*pendingToolCall),
+	}
+	if req.ToolChoice != nil && req.ToolChoice.Mode == "none" {
+		return v
+	}
+	for _, tool := range req.Tools {
+		if req.ToolChoice != nil && req.ToolChoice.Function != "" && tool.Function.Name != req.ToolChoice.Function {
+			continue
+		}
+		v.params[tool.Function.Name] = tool.Function.Parameters
+	}
+	return v
+}
+
+func (v *toolCallValidator) enabled() bool {
+	return len(v.params) > 0
+}
+
+// check validates the tool calls of a single choice.
+func (v *toolCallValidator) check(calls []any) error {
+	if v.required && len(calls) == 0 {
+		return errors.New("the model did not call a tool")
+	}
+	if v.single && len(calls) > 1 {
+		return fmt.Errorf("the model called %d tools but parallel_tool_calls is false", len(calls))
+	}
+
+	for _, call := range calls {
+		fn, _ := call.(map[string]any)["function"].(map[string]any)
+		name, _ := fn["name"].(string)
+		params, ok := v.params[name]
+		if !ok {
+			return fmt.Errorf("the model called unknown tool %q", name)
+		}
+		args, err := toolCallArguments(fn["arguments"])
+		if err != nil {
+			return fmt.Errorf("invalid arguments for tool %q: %w", name, err)
+		}
+		if err := params.Validate(args); err != nil {
+			return fmt.Errorf("invalid arguments for tool %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the tool calls of a non-streamed chat completion.
+func (v *toolCallValidator) Validate(resp map[string]any) error {
+	if !v.enabled() {
+		return nil
+	}
+	for _, choice := range choices(resp) {
+		msg, _ := choice["message"].(map[string]any)
+		calls, _ := msg["tool_calls"].([]any)
+		if err := v.check(calls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Chunk buffers the tool call deltas of a streamed chat completion and
+// releases them, once validated, with the chunk finishing the choice. An
+// invalid call replaces that chunk with an error.
+func (v *toolCallValidator) Chunk(chunk map[string]any) {
+	if !v.enabled() {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, choice := range choices(chunk) {
+		index := choiceIndex(choice)
+		delta, _ := choice["delta"].(map[string]any)
+		if deltas, ok := delta["tool_calls"].([]any); ok {
+			for _, d := range deltas {
+				v.accumulate(index, d)
+			}
+			delete(delta, "tool_calls")
+		}
+
+		if choice["finish_reason"] == nil {
+			continue
+		}
+
+		calls := make([]any, 0, len(v.pending[index]))
+		for i, call := range v.pending[index] {
+			calls = append(calls, map[string]any{
+				"index": i,
+				"id":    call.id,
+				"type":  call.typ,
+				"function": map[string]any{
+					"name":      call.name,
+					"arguments": call.arguments,
+				},
+			})
+		}
+		delete(v.pending, index)
+
+		if err := v.check(calls); err != nil {
+			clear(chunk)
+			chunk["error"] = map[string]any{
+				"code":    http.StatusInternalServerError,
+				"message": err.Error(),
+				"type":    "server_error",
+			}
+			return
+		}
+		if len(calls) > 0 {
+			if delta == nil {
+				delta = make(map[string]any)
+				choice["delta"] = delta
+			}
+			delta["tool_calls"] = calls
+		}
+	}
+}
+
+func (v *toolCallValidator) accumulate(choice int, d any) {
+	delta, ok := d.(map[string]any)
+	if !ok {
+		return
+	}
+	i := choiceIndex(delta)
+	for len(v.pending[choice]) <= i {
+		v.pending[choice] = append(v.pending[choice], &pendingToolCall{typ: "function"})
+	}
+	call := v.pending[choice][i]
+
+	if id, ok := delta["id"].(string); ok && id != "" {
+		call.id = id
+	}
+	if typ, ok := delta["type"].(string); ok && typ != "" {
+		call.typ = typ
+	}
+	fn, _ := delta["function"].(map[string]any)
+	if name, ok := fn["name"].(string); ok && name != "" {
+		call.name = name
+	}
+	if args, ok := fn["arguments"].(string); ok {
+		call.arguments += args
+	}
+}
+
+// toolCallArguments decodes the arguments of an OpenAI style tool call, which
+// are sent as a JSON encoded string.
+func toolCallArguments(v any) (map[string]any, error) {
+	switch args := v.(type) {
+	case map[string]any:
+		return args, nil
+	case string:
+		var m map[string]any
+		if args == "" {
+			return map[string]any{}, nil
+		}
+		if err := json.Unmarshal([]byte(args), &m); err != nil {
+			return nil, err
+		}
+		if m == nil {
+			return nil, errors.New("arguments must be a JSON object")
+		}
+		return m, nil
+	case nil:
+		return map[string]any{}, nil
+	}
+	return nil, fmt.Errorf("unexpected arguments %v", v)
+}
+
+// toolCalls converts the OpenAI style tool calls of a message.
+func toolCalls(msg map[string]any) []api.ToolCall {
+	raw, _ := msg["tool_calls"].([]any)
+	var calls []api.ToolCall
+	for i, r := range raw {
+		call, _ := r.(map[string]any)
+		fn, _ := call["function"].(map[string]any)
+		name, _ := fn["name"].(string)
+		args, err := toolCallArguments(fn["arguments"])
+		if err != nil {
+			continue
+		}
+		calls = append(calls, api.ToolCall{Function: api.ToolCallFunction{Index: i, Name: name, Arguments: args}})
+	}
+	return calls
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRetryToolCall(t *testing.T) {
+	messages := []any{map[string]any{"role": "user", "content": "weather in Paris?"}}
+	body := map[string]any{"messages": messages, "seed": 42}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		retryToolCall(body, messages, errors.New("missing required argument \"location\""))
+
+		got := body["messages"].([]any)
+		if len(got) != 2 {
+			t.Fatalf("attempt %d: got %d messages, want 2", attempt, len(got))
+		}
+		if msg := got[1].(map[string]any); msg["role"] != "user" || msg["content"] == "" {
+			t.Errorf("attempt %d: unexpected message %v", attempt, msg)
+		}
+		if body["seed"] != 42+attempt {
+			t.Errorf("attempt %d: got seed %v, want %d", attempt, body["seed"], 42+attempt)
+		}
+	}
+	if len(messages) != 1 {
+		t.Errorf("the messages of the request were modified: %v", messages)
+	}
+
+	body = map[string]any{"messages": messages}
+	retryToolCall(body, messages, errors.New("invalid"))
+	if _, ok := body["seed"]; ok {
+		t.Errorf("unexpected seed %v without a fixed seed", body["seed"])
+	}
+}
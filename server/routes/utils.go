@@ -1,12 +1,17 @@
 package routes
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/Qitmeer/llama.go/api"
+	"github.com/Qitmeer/llama.go/wrapper"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/gin-gonic/gin"
 )
@@ -43,15 +48,46 @@ func waitForStream(c *gin.Context, ch chan any) {
 	c.JSON(http.StatusOK, latest)
 }
 
+// ErrShuttingDown is the cause of the cancellation of the requests which the
+// server cuts off when they outlast the drain timeout of its shutdown.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// receive returns the next value of ch, or false once ch is closed or the
+// request is cancelled. The cancellation, including the cut off of the
+// shutdown, cancels the task in the core and the values it pushed meanwhile
+// are discarded so that it is never blocked.
+func receive(c *gin.Context, ch chan any) (any, bool) {
+	select {
+	case val, ok := <-ch:
+		return val, ok
+	case <-c.Request.Context().Done():
+		go func() {
+			for range ch {
+			}
+		}()
+		return nil, false
+	}
+}
+
+// cutOff reports whether the request was cancelled by the shutdown.
+func cutOff(c *gin.Context) bool {
+	return errors.Is(context.Cause(c.Request.Context()), ErrShuttingDown)
+}
+
 func streamHandler(c *gin.Context, ch chan any) {
+	final, _ := json.Marshal(gin.H{"error": ErrShuttingDown.Error()})
+
 	accept := c.GetHeader("Accept")
 	if accept == "application/x-ndjson" {
 		// NDJSON
 		c.Header("Content-Type", "application/x-ndjson")
 
 		c.Stream(func(w io.Writer) bool {
-			val, ok := <-ch
+			val, ok := receive(c, ch)
 			if !ok {
+				if cutOff(c) {
+					fmt.Fprintf(w, "%s\n", final)
+				}
 				return false
 			}
 
@@ -81,8 +117,11 @@ func streamHandler(c *gin.Context, ch chan any) {
 		c.Header("Transfer-Encoding", "chunked")
 
 		c.Stream(func(w io.Writer) bool {
-			val, ok := <-ch
+			val, ok := receive(c, ch)
 			if !ok {
+				if cutOff(c) {
+					fmt.Fprintf(w, "data: %s\n\n", final)
+				}
 				return false
 			}
 			bts, ok := val.(string)
@@ -98,8 +137,12 @@ func streamHandler(c *gin.Context, ch chan any) {
 		})
 	} else {
 		c.Stream(func(w io.Writer) bool {
-			val, ok := <-ch
+			val, ok := receive(c, ch)
 			if !ok {
+				if cutOff(c) {
+					// the core streams server-sent events by default
+					fmt.Fprintf(w, "data: %s\n\n", final)
+				}
 				return false
 			}
 			bts, ok := val.(string)
@@ -115,3 +158,147 @@ func streamHandler(c *gin.Context, ch chan any) {
 		})
 	}
 }
+
+// chunkFunc rewrites a single JSON object produced by the core, either one
+// streamed event or a whole non-streamed response.
+type chunkFunc func(chunk map[string]any)
+
+// chainChunkFuncs applies fns in order to each chunk.
+func chainChunkFuncs(fns ...chunkFunc) chunkFunc {
+	return func(chunk map[string]any) {
+		for _, fn := range fns {
+			if _, ok := chunk["error"]; ok {
+				return
+			}
+			fn(chunk)
+		}
+	}
+}
+
+// filterStream applies fn to every server-sent event the core pushes to in.
+// Events that are not JSON objects (e.g. "[DONE]") are forwarded untouched.
+func filterStream(in chan any, fn chunkFunc) chan any {
+	out := make(chan any)
+	go func() {
+		defer close(out)
+		for val := range in {
+			if str, ok := val.(string); ok {
+				val = filterEvents(str, fn)
+			}
+			out <- val
+		}
+	}()
+	return out
+}
+
+func filterEvents(str string, fn chunkFunc) string {
+	var sb strings.Builder
+	for _, event := range strings.SplitAfter(str, "\n\n") {
+		data, ok := strings.CutPrefix(event, "data: ")
+		if !ok {
+			sb.WriteString(event)
+			continue
+		}
+		data = strings.TrimRight(data, "\n")
+
+		var chunk map[string]any
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			sb.WriteString(event)
+			continue
+		}
+		fn(chunk)
+
+		bts, err := json.Marshal(chunk)
+		if err != nil {
+			log.Warn("chunk marshal error", "error", err)
+			sb.WriteString(event)
+			continue
+		}
+		sb.WriteString("data: ")
+		sb.Write(bts)
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// choices returns the OpenAI style choices of a chunk along with their index.
+func choices(chunk map[string]any) []map[string]any {
+	raw, ok := chunk["choices"].([]any)
+	if !ok {
+		return nil
+	}
+	ret := make([]map[string]any, 0, len(raw))
+	for _, c := range raw {
+		if m, ok := c.(map[string]any); ok {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+func choiceIndex(choice map[string]any) int {
+	if f, ok := choice["index"].(float64); ok {
+		return int(f)
+	}
+	return 0
+}
+
+// collectResponse reads the non-streamed JSON response the core pushes to ch.
+// It returns the cause of the cancellation of ctx, which cancels the task in
+// the core, without waiting for the response.
+func collectResponse(ctx context.Context, ch chan any) (map[string]any, error) {
+	content := ""
+	for done := false; !done; {
+		select {
+		case rr, ok := <-ch:
+			if !ok {
+				done = true
+				break
+			}
+			if str, ok := rr.(string); ok {
+				content += str
+			}
+		case <-ctx.Done():
+			go func() {
+				for range ch {
+				}
+			}()
+			return nil, context.Cause(ctx)
+		}
+	}
+	if len(content) <= 0 {
+		return nil, errors.New("no content")
+	}
+	var ret map[string]any
+	if err := json.Unmarshal([]byte(content), &ret); err != nil {
+		return nil, errors.New("invalid json")
+	}
+	return ret, nil
+}
+
+// decodeBody decodes a raw JSON request so that handlers can adjust it before
+// it is forwarded to the core. Numbers are preserved as json.Number.
+func decodeBody(data []byte) (map[string]any, error) {
+	body := make(map[string]any)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return body, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// coreResponse writes the JSON response of the core with its status.
+func coreResponse(c *gin.Context, status int, jsonStr string) {
+	switch {
+	case wrapper.NotRunning(status, jsonStr):
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "llama core is not running"})
+	case jsonStr == "":
+		c.AbortWithStatusJSON(status, gin.H{"error": "empty response from llama core"})
+	default:
+		c.Data(status, "application/json; charset=utf-8", []byte(jsonStr))
+	}
+}
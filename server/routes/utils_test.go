@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCollectResponse(t *testing.T) {
+	ch := make(chan any, 2)
+	ch <- `{"content":`
+	ch <- `"hi"}`
+	close(ch)
+	ret, err := collectResponse(context.Background(), ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret["content"] != "hi" {
+		t.Errorf("got %v", ret)
+	}
+
+	ch = make(chan any)
+	close(ch)
+	if _, err := collectResponse(context.Background(), ch); err == nil {
+		t.Error("expected an error without content")
+	}
+}
+
+func TestCollectResponseCancel(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	ch := make(chan any)
+	done := make(chan error)
+	go func() {
+		_, err := collectResponse(ctx, ch)
+		done <- err
+	}()
+
+	ch <- `{"content":`
+	cancel(ErrShuttingDown)
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("got error %v, want %v", err, ErrShuttingDown)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("collectResponse did not return once cancelled")
+	}
+
+	// the output the core still pushes is discarded without blocking it
+	select {
+	case ch <- `"hi"}`:
+	case <-time.After(time.Second):
+		t.Fatal("the channel was not drained")
+	}
+	close(ch)
+}
@@ -1,11 +1,14 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Qitmeer/llama.go/config"
 	"github.com/Qitmeer/llama.go/runner"
@@ -17,9 +20,15 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+// cutOffTimeout bounds the time the requests which were cut off have to end
+// before their connections are closed.
+const cutOffTimeout = 5 * time.Second
+
 type Service struct {
 	ctx *cli.Context
-	cfg *config.Config
+	// cfg is replaced as a whole on reloads, the requests in flight keep
+	// reading the config they loaded
+	cfg atomic.Pointer[config.Config]
 
 	addr net.Addr
 	srvr *http.Server
@@ -29,13 +38,27 @@ type Service struct {
 	api *routes.API
 
 	runnerSer *runner.Service
+
+	admission *middleware.Admission
+	// cancelRequests cuts off the requests outlasting the drain timeout and
+	// the audio streams
+	cancelRequests context.CancelCauseFunc
+	// cors is replaced when the allowed origins are reloaded
+	cors     atomic.Pointer[gin.HandlerFunc]
+	reloadMu sync.Mutex
+	onReload []func(cfg *config.Config)
 }
 
 func New(ctx *cli.Context, cfg *config.Config) *Service {
 	log.Info("New Server ...")
-	runnerSer := runner.New(ctx, cfg)
-	ser := Service{ctx: ctx, cfg: cfg, api: routes.New(cfg, runnerSer), runnerSer: runnerSer}
-	return &ser
+	ser := &Service{
+		ctx:       ctx,
+		admission: middleware.NewAdmission(cfg.MaxRequests),
+	}
+	ser.cfg.Store(cfg)
+	ser.runnerSer = runner.New(ctx, &ser.cfg)
+	ser.api = routes.New(&ser.cfg, ser.runnerSer)
+	return ser
 }
 
 func (s *Service) Start() error {
@@ -49,7 +72,7 @@ func (s *Service) Start() error {
 		return err
 	}
 
-	ln, err := net.Listen("tcp", s.cfg.HostURL().Host)
+	ln, err := net.Listen("tcp", s.cfg.Load().HostURL().Host)
 	if err != nil {
 		return err
 	}
@@ -60,8 +83,13 @@ func (s *Service) Start() error {
 		return err
 	}
 	log.Info(fmt.Sprintf("Listening on %s (version %s)", ln.Addr(), version.String()))
+	base, cancel := context.WithCancelCause(context.Background())
+	s.cancelRequests = cancel
 	s.srvr = &http.Server{
 		Handler: nil,
+		BaseContext: func(net.Listener) context.Context {
+			return base
+		},
 	}
 
 	s.wg.Add(1)
@@ -80,29 +108,67 @@ func (s *Service) Start() error {
 func (s *Service) GenerateRoutes() error {
 	r := gin.Default()
 
+	cors := middleware.CORS(s.cfg.Load().AllowedOrigins())
+	s.cors.Store(&cors)
+
 	r.Use(middleware.Security())
-	r.Use(middleware.CORS(s.cfg.AllowedOrigins()))
+	r.Use(func(c *gin.Context) {
+		(*s.cors.Load())(c)
+	})
 	r.Use(middleware.AllowedHosts(s.addr))
+	apiKeys := func() []string {
+		return s.cfg.Load().APIKeys
+	}
+	r.Use(middleware.APIKeys(apiKeys))
+	r.Use(s.admission.Handler())
 
 	r.HandleMethodNotAllowed = true
 
 	s.api.Setup(r)
+	r.POST("/admin/reload", middleware.LoopbackWithoutKeys(apiKeys), s.ReloadHandler)
 
 	http.Handle("/", r)
 	return nil
 }
 
+// Stop drains the requests in flight before stopping the runner. New requests
+// get 503 meanwhile, and the requests outlasting the drain timeout are cut
+// off, their streams ending with an error chunk. The audio streams are not
+// drained and are closed once the drain ends.
 func (s *Service) Stop() error {
 	log.Info("Stop Server...")
 
 	var err error
 	if s.srvr != nil {
-		err = s.srvr.Close()
+		s.admission.Close()
+		s.api.Drain()
+
+		log.Info("Draining requests in flight", "count", s.admission.InFlight(), "timeout", s.drainTimeout())
+		ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout())
+		if err := s.admission.Drain(ctx); err != nil {
+			log.Warn("Cutting off the requests in flight", "count", s.admission.InFlight())
+		}
+		cancel()
+		// cuts off the requests outlasting the drain along with the audio
+		// streams, which are not drained
+		s.cancelRequests(routes.ErrShuttingDown)
+
+		ctx, cancel = context.WithTimeout(context.Background(), cutOffTimeout)
+		err = s.srvr.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			log.Warn("Closing the connections left", "error", err)
+			err = s.srvr.Close()
+		}
 	}
 	s.wg.Wait()
 
-	if s.runnerSer != nil {
+	if s.runnerSer != nil && s.runnerSer.IsRunning() {
 		err = s.runnerSer.Stop()
 	}
 	return err
 }
+
+func (s *Service) drainTimeout() time.Duration {
+	return time.Duration(s.cfg.Load().DrainTimeout) * time.Second
+}
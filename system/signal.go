@@ -54,6 +54,27 @@ func InterruptListener() <-chan struct{} {
 	return c
 }
 
+// reloadSignals are the signals requesting the configuration to be reloaded.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
+// ReloadListener returns a channel receiving a value each time a reload
+// signal such as SIGHUP is received.
+func ReloadListener() <-chan struct{} {
+	c := make(chan struct{}, 1)
+	go func() {
+		reloadChannel := make(chan os.Signal, 1)
+		signal.Notify(reloadChannel, reloadSignals...)
+		for sig := range reloadChannel {
+			log.Info(fmt.Sprintf("Received signal (%s).  Reloading...", sig))
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return c
+}
+
 // interruptRequested returns true when the channel returned by
 // interruptListener was closed.  This simplifies early shutdown slightly since
 // the caller can just use an if statement instead of a select.
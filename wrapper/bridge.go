@@ -7,18 +7,27 @@ package wrapper
 import "C"
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"net/http"
 	"sync"
 	"unsafe"
 
-	econfig "github.com/Qitmeer/llama.go/app/embedding/config"
 	"github.com/Qitmeer/llama.go/config"
 )
 
+// taskChan receives the output of a core task.
+type taskChan struct {
+	ch chan any
+	// cancelled is set once the context of the task is done, the core then
+	// stops the task and its output is no longer pushed
+	cancelled bool
+	stop      func() bool
+}
+
 var (
 	mu         sync.Mutex
-	channels   = make(map[int]chan any)
+	channels   = make(map[int]*taskChan)
 	nextChanID = 1
 )
 
@@ -29,11 +38,10 @@ func LlamaStartInteractive(cfg *config.Config) error {
 	ip := C.CString(cfg.Prompt)
 	defer C.free(unsafe.Pointer(ip))
 
-	cfgArgs := assemblyArgs(cfg)
-	ca := C.CString(cfgArgs)
-	defer C.free(unsafe.Pointer(ca))
+	argc, argv := cArgs(cfg.Args())
+	defer freeArgs(argc, argv)
 
-	ret := C.llama_interactive_start(ca, ip)
+	ret := C.llama_interactive_start(argc, argv, ip)
 	if !bool(ret) {
 		return fmt.Errorf("Llama interactive error")
 	}
@@ -77,15 +85,30 @@ func LlamaChat(id int, jsStr string) error {
 	return nil
 }
 
+// LlamaInfill runs a fill-in-the-middle completion, pushing its output to the
+// channel id like LlamaGenerate.
+func LlamaInfill(id int, jsStr string) error {
+	if len(jsStr) <= 0 {
+		return fmt.Errorf("json string")
+	}
+	js := C.CString(jsStr)
+	defer C.free(unsafe.Pointer(js))
+
+	ret := C.llama_infill(C.int(id), js)
+	if !bool(ret.ret) {
+		return fmt.Errorf("Llama infill error")
+	}
+	return nil
+}
+
 func LlamaStart(cfg *config.Config) error {
 	if !cfg.HasModel() {
 		return fmt.Errorf("No model")
 	}
-	cfgArgs := assemblyArgs(cfg)
-	ca := C.CString(cfgArgs)
-	defer C.free(unsafe.Pointer(ca))
+	argc, argv := cArgs(cfg.Args())
+	defer freeArgs(argc, argv)
 
-	ret := C.llama_start(ca)
+	ret := C.llama_start(argc, argv)
 	if !bool(ret) {
 		return fmt.Errorf("Llama start error")
 	}
@@ -100,59 +123,26 @@ func LlamaStop() error {
 	return nil
 }
 
-func LlamaEmbedding(cfg *config.Config, prompts string, embdOutputFormat string) (string, error) {
-	if len(prompts) <= 0 {
-		return "", fmt.Errorf("No prompt")
-	}
-	ip := C.CString(prompts)
-	defer C.free(unsafe.Pointer(ip))
-
-	cfgArgs := assemblyArgs(cfg)
-	cfgArgs = fmt.Sprintf("%s --embd-normalize %d", cfgArgs, econfig.Conf.EmbdNormalize)
-	if len(embdOutputFormat) > 0 {
-		cfgArgs = fmt.Sprintf("%s --embd-output-format %s", cfgArgs, embdOutputFormat)
-	} else {
-		cfgArgs = fmt.Sprintf("%s --embd-output-format %s", cfgArgs, econfig.Conf.EmbdOutputFormat)
-	}
-	cfgArgs = fmt.Sprintf("%s --embd-separator %s", cfgArgs, econfig.Conf.EmbdSeparator)
-
-	ca := C.CString(cfgArgs)
-	defer C.free(unsafe.Pointer(ca))
-
-	ret := C.llama_embedding(ca, ip)
-	if !bool(ret.ret) {
-		return "", fmt.Errorf("Llama run error")
+// cArgs copies args to a C argv array, which freeArgs releases.
+func cArgs(args []string) (C.int, **C.char) {
+	argv := (**C.char)(C.malloc(C.size_t(len(args)) * C.size_t(unsafe.Sizeof(uintptr(0)))))
+	for i, arg := range args {
+		unsafe.Slice(argv, len(args))[i] = C.CString(arg)
 	}
-
-	content := C.GoString(ret.content)
-	C.free(unsafe.Pointer(ret.content))
-	return content, nil
+	return C.int(len(args)), argv
 }
 
-func WhisperGenerate(cfg *config.Config, input string) (string, error) {
-	if !cfg.HasModel() {
-		return "", fmt.Errorf("No model")
-	}
-	if len(input) <= 0 {
-		return "", fmt.Errorf("No input")
+func freeArgs(argc C.int, argv **C.char) {
+	for _, arg := range unsafe.Slice(argv, int(argc)) {
+		C.free(unsafe.Pointer(arg))
 	}
-	model := C.CString(cfg.ModelPath())
-	defer C.free(unsafe.Pointer(model))
-
-	ip := C.CString(input)
-	defer C.free(unsafe.Pointer(ip))
-
-	ret := C.whisper_gen(model, ip)
-	if !bool(ret.ret) {
-		return "", fmt.Errorf("Llama run error")
-	}
-
-	content := C.GoString(ret.content)
-	C.free(unsafe.Pointer(ret.content))
-	return content, nil
+	C.free(unsafe.Pointer(argv))
 }
 
-func NewChan() (int, chan any) {
+// NewChan returns the id of a channel receiving the output of a core task.
+// The task is cancelled in the core once ctx is done, the output it already
+// produced still has to be received until the channel is closed.
+func NewChan(ctx context.Context) (int, chan any) {
 	mu.Lock()
 	defer mu.Unlock()
 	id := nextChanID
@@ -160,29 +150,49 @@ func NewChan() (int, chan any) {
 	if ok {
 		return 0, nil
 	}
-	ch := make(chan any)
-	channels[id] = ch
+	t := &taskChan{ch: make(chan any)}
+	t.stop = context.AfterFunc(ctx, func() {
+		mu.Lock()
+		t.cancelled = true
+		mu.Unlock()
+	})
+	channels[id] = t
 	nextChanID++
-	return id, ch
+	return id, t.ch
 }
 
 //export PushToChan
-func PushToChan(id C.int, val *C.char) {
+func PushToChan(id C.int, val *C.char) C.bool {
 	str := C.GoString(val)
 	mu.Lock()
-	ch, ok := channels[int(id)]
+	t, ok := channels[int(id)]
+	ok = ok && !t.cancelled
 	mu.Unlock()
-	if ok {
-		ch <- str
+	if !ok {
+		return false
 	}
+	t.ch <- str
+	return true
+}
+
+// ChanCancelled reports to the core whether the task pushing to the channel
+// id was cancelled.
+//
+//export ChanCancelled
+func ChanCancelled(id C.int) C.bool {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := channels[int(id)]
+	return C.bool(!ok || t.cancelled)
 }
 
 //export CloseChan
 func CloseChan(id C.int) {
 	mu.Lock()
-	ch, ok := channels[int(id)]
+	t, ok := channels[int(id)]
 	if ok {
-		close(ch)
+		t.stop()
+		close(t.ch)
 		delete(channels, int(id))
 	}
 	mu.Unlock()
@@ -198,6 +208,12 @@ func IsLlamaRunning() bool {
 	return bool(C.llama_is_running())
 }
 
+// NotRunning reports whether the HTTP status and body returned by the core
+// mean that it is not running, which it answers with 503 and no body.
+func NotRunning(status int, body string) bool {
+	return status == 0 || (status == http.StatusServiceUnavailable && body == "")
+}
+
 // LlamaPropsHTTP returns HTTP status and JSON body from llama_core /props.
 func LlamaPropsHTTP() (status int, body string) {
 	r := C.llama_props_http()
@@ -218,40 +234,119 @@ func LlamaSlotsHTTP() (status int, body string) {
 	return int(r.status), body
 }
 
-func assemblyArgs(cfg *config.Config) string {
-	cfgArgs := "llama"
-	if len(cfg.ModelPath()) > 0 {
-		cfgArgs = fmt.Sprintf("%s --model %s", cfgArgs, cfg.ModelPath())
-	}
-	if cfg.CtxSize != config.DefaultContextSize {
-		cfgArgs = fmt.Sprintf("%s --ctx-size %d", cfgArgs, cfg.CtxSize)
+// LlamaLoraAdaptersHTTP returns HTTP status and JSON body from llama_core
+// GET /lora-adapters.
+func LlamaLoraAdaptersHTTP() (status int, body string) {
+	r := C.llama_lora_adapters_http()
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if cfg.NGpuLayers != config.DefaultNGpuLayers {
-		cfgArgs = fmt.Sprintf("%s --n-gpu-layers %d", cfgArgs, cfg.NGpuLayers)
+	return int(r.status), body
+}
+
+// LlamaSetLoraAdaptersHTTP changes the scales of the LoRA adapters and
+// returns HTTP status and JSON body from llama_core POST /lora-adapters.
+func LlamaSetLoraAdaptersHTTP(jsonStr string) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	r := C.llama_lora_adapters_set_http(js)
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if cfg.Seed != math.MaxUint32 {
-		cfgArgs = fmt.Sprintf("%s --seed %d", cfgArgs, cfg.Seed)
+	return int(r.status), body
+}
+
+// LlamaTokenizeHTTP returns HTTP status and JSON body from llama_core
+// POST /tokenize.
+func LlamaTokenizeHTTP(jsonStr string) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	r := C.llama_tokenize_http(js)
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if cfg.BatchSize != 2048 {
-		cfgArgs = fmt.Sprintf("%s --batch-size %d", cfgArgs, cfg.BatchSize)
+	return int(r.status), body
+}
+
+// LlamaDetokenizeHTTP returns HTTP status and JSON body from llama_core
+// POST /detokenize.
+func LlamaDetokenizeHTTP(jsonStr string) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	r := C.llama_detokenize_http(js)
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if cfg.UBatchSize != 512 {
-		cfgArgs = fmt.Sprintf("%s --ubatch-size %d", cfgArgs, cfg.UBatchSize)
+	return int(r.status), body
+}
+
+// LlamaEmbeddingsHTTP returns HTTP status and JSON body from llama_core
+// POST /embeddings.
+func LlamaEmbeddingsHTTP(jsonStr string) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	r := C.llama_embeddings_http(js)
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if cfg.Jinja {
-		cfgArgs = fmt.Sprintf("%s --jinja", cfgArgs)
+	return int(r.status), body
+}
+
+// LlamaRerankHTTP returns HTTP status and JSON body from llama_core
+// POST /rerank.
+func LlamaRerankHTTP(jsonStr string) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	r := C.llama_rerank_http(js)
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if len(cfg.ChatTemplate) > 0 {
-		cfgArgs = fmt.Sprintf("%s --chat-template %s", cfgArgs, cfg.ChatTemplate)
+	return int(r.status), body
+}
+
+// LlamaApplyTemplateHTTP returns HTTP status and JSON body from llama_core
+// POST /apply-template.
+func LlamaApplyTemplateHTTP(jsonStr string) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	r := C.llama_apply_template_http(js)
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if len(cfg.ChatTemplateFile) > 0 {
-		cfgArgs = fmt.Sprintf("%s --chat-template-file %s", cfgArgs, cfg.ChatTemplateFile)
+	return int(r.status), body
+}
+
+// WhisperTranscribeHTTP transcribes the 16 kHz mono samples with the whisper
+// model the JSON request names, kept loaded between the calls, and returns
+// HTTP status and JSON body.
+func WhisperTranscribeHTTP(jsonStr string, samples []float32) (status int, body string) {
+	js := C.CString(jsonStr)
+	defer C.free(unsafe.Pointer(js))
+	var pcm *C.float
+	if len(samples) > 0 {
+		pcm = (*C.float)(unsafe.Pointer(&samples[0]))
 	}
-	if len(cfg.ChatTemplateKwargs) > 0 {
-		cfgArgs = fmt.Sprintf("%s --chat-template-kwargs %s", cfgArgs, cfg.ChatTemplateKwargs)
+	r := C.whisper_transcribe_http(js, pcm, C.int(len(samples)))
+	if r.body != nil {
+		body = C.GoString(r.body)
+		C.free(unsafe.Pointer(r.body))
 	}
-	if len(cfg.Pooling) > 0 {
-		cfgArgs = fmt.Sprintf("%s --pooling %s", cfgArgs, cfg.Pooling)
+	return int(r.status), body
+}
+
+// WhisperLoad loads the whisper model, which the transcriptions then share.
+func WhisperLoad(model string) error {
+	m := C.CString(model)
+	defer C.free(unsafe.Pointer(m))
+	if !bool(C.whisper_load(m)) {
+		return fmt.Errorf("failed to load the whisper model %s", model)
 	}
-	return cfgArgs
+	return nil
 }